@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+)
+
+// Endpoints override the default AWS endpoints, e.g. for S3 compatible stores
+// or VPC endpoints
+type Endpoints struct {
+	// S3 endpoint URL
+	S3 string `json:"s3,omitempty"`
+	// STS endpoint URL used to validate credentials
+	STS string `json:"sts,omitempty"`
+}
+
+// RetryPolicy paces and retries the calls made to object stores
+type RetryPolicy struct {
+	// requests per second allowed to a bucket with the same credentials,
+	// unlimited if zero
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	// requests allowed above the rate in bursts
+	Burst int `json:"burst,omitempty"`
+	// attempts of every call, including retries
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// retry mode: standard / adaptive
+	Mode string `json:"mode,omitempty"`
+}
+
+// Scope restricts the objects handled by the controller
+type Scope struct {
+	// namespaces watched, all namespaces if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+	// namespaces never watched
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	// label selector of the handled objects, all objects if empty
+	ObjectSelector string `json:"objectSelector,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ControllerConfig is the Schema for the configuration file of the controller
+type ControllerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerManagerConfigurationSpec returns the configurations for controllers
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// region of targets without a region
+	DefaultRegion string `json:"defaultRegion,omitempty"`
+	// deletion policy of objects without a deletion policy:
+	// Delete / Retain / Orphan-On-Mismatch
+	DefaultDeletionPolicy string `json:"defaultDeletionPolicy,omitempty"`
+	// endpoints of the object stores
+	Endpoints Endpoints `json:"endpoints,omitempty"`
+	// pacing and retries of the calls to object stores
+	Retry RetryPolicy `json:"retry,omitempty"`
+	// number of objects reconciled in parallel
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+	// objects handled by the controller
+	Scope Scope `json:"scope,omitempty"`
+	// report the planned operations without modifying object stores
+	DryRun bool `json:"dryRun,omitempty"`
+	// how long deletes are retried before the finalizer is removed
+	// regardless, retried forever if zero
+	DeletionTimeout metav1.Duration `json:"deletionTimeout,omitempty"`
+	// features enabled or disabled by name
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+	// image of the upload jobs of PVC sources, the image of the controller
+	// if empty
+	UploaderImage string `json:"uploaderImage,omitempty"`
+	// name of the cluster, available to templates as {{ .Cluster.Name }}
+	ClusterName string `json:"clusterName,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ControllerConfig{})
+}
@@ -0,0 +1,361 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+// SourceAnnotation preserves the v1beta1 source members without a v1alpha1
+// counterpart, so that they survive a round trip through v1alpha1
+const SourceAnnotation = "s3.aws.dev.nimak.link/v1beta1-source"
+
+// OutputAnnotation preserves the v1beta1 output format, which has no v1alpha1
+// counterpart
+const OutputAnnotation = "s3.aws.dev.nimak.link/v1beta1-output"
+
+// ConvertTo converts this Object to the hub version (v1beta1)
+func (src *Object) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.Object)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Annotations = withAnnotation(withAnnotation(src.Annotations, SourceAnnotation, ""), OutputAnnotation, "")
+
+	spec := src.Spec
+	dst.Spec = v1beta1.ObjectSpec{
+		DeletionPolicy: deletionPolicyToHub(spec.DeletionPolicy),
+		Credentials: v1beta1.Credentials{
+			Source: credentialsSourceToHub(spec.Credentials.Source),
+			SecretReference: v1beta1.SecretKeySelector{
+				SecretReference: v1beta1.SecretReference{
+					Name:      spec.Credentials.SecretReference.Name,
+					Namespace: spec.Credentials.SecretReference.Namespace,
+				},
+				Key: spec.Credentials.SecretReference.Key,
+			},
+		},
+		Source:  sourceToHub(spec.Source, src.Annotations[SourceAnnotation]),
+		Target:  targetToHub(spec.Target),
+		DryRun:  spec.DryRun,
+		Suspend: spec.Suspend,
+	}
+	if format := src.Annotations[OutputAnnotation]; format != "" {
+		dst.Spec.Output = &v1beta1.ObjectOutput{Format: v1beta1.OutputFormat(format)}
+	}
+	if spec.History != nil {
+		dst.Spec.History = &v1beta1.ObjectHistory{
+			Mode:  historyModeToHub(spec.History.Mode),
+			Limit: spec.History.Limit,
+		}
+	}
+
+	status := src.Status
+	dst.Status = v1beta1.ObjectStatus{
+		Synced:              status.Synced,
+		Reference:           status.Reference,
+		Checksum:            status.Checksum,
+		ETag:                status.ETag,
+		ObservedGeneration:  status.ObservedGeneration,
+		LastHandledResyncAt: status.LastHandledResyncAt,
+		DeletionAttempts:    status.DeletionAttempts,
+		SourceRevision:      status.SourceRevision,
+		VersionedBucket:     status.VersionedBucket,
+		Conditions:          status.Conditions,
+	}
+	for _, version := range status.Versions {
+		dst.Status.Versions = append(dst.Status.Versions, v1beta1.ObjectVersion(version))
+	}
+	if status.DryRun != nil {
+		dryRun := v1beta1.DryRunStatus(*status.DryRun)
+		dst.Status.DryRun = &dryRun
+	}
+	if status.Upload != nil {
+		dst.Status.Upload = &v1beta1.UploadStatus{
+			JobName:        status.Upload.JobName,
+			Phase:          v1beta1.UploadPhase(status.Upload.Phase),
+			StartTime:      status.Upload.StartTime,
+			CompletionTime: status.Upload.CompletionTime,
+			Message:        status.Upload.Message,
+			Failures:       status.Upload.Failures,
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1beta1) to this version
+func (dst *Object) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.Object)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Annotations = withAnnotation(src.Annotations, SourceAnnotation, hubOnlySource(src.Spec.Source))
+	if src.Spec.Output != nil {
+		dst.Annotations = withAnnotation(dst.Annotations, OutputAnnotation, string(src.Spec.Output.Format))
+	}
+
+	spec := src.Spec
+	dst.Spec = ObjectSpec{
+		DeletionPolicy: deletionPolicyFromHub(spec.DeletionPolicy),
+		Credentials: Credentials{
+			Source: string(spec.Credentials.Source),
+			SecretReference: SecretKeySelector{
+				SecretReference: SecretReference{
+					Name:      spec.Credentials.SecretReference.Name,
+					Namespace: spec.Credentials.SecretReference.Namespace,
+				},
+				Key: spec.Credentials.SecretReference.Key,
+			},
+		},
+		Source:  sourceFromHub(spec.Source),
+		Target:  targetFromHub(spec.Target),
+		DryRun:  spec.DryRun,
+		Suspend: spec.Suspend,
+	}
+	if spec.History != nil {
+		dst.Spec.History = &ObjectHistory{
+			Mode:  historyModeFromHub(spec.History.Mode),
+			Limit: spec.History.Limit,
+		}
+	}
+
+	status := src.Status
+	dst.Status = ObjectStatus{
+		Synced:              status.Synced,
+		Reference:           status.Reference,
+		Checksum:            status.Checksum,
+		ETag:                status.ETag,
+		ObservedGeneration:  status.ObservedGeneration,
+		LastHandledResyncAt: status.LastHandledResyncAt,
+		DeletionAttempts:    status.DeletionAttempts,
+		SourceRevision:      status.SourceRevision,
+		VersionedBucket:     status.VersionedBucket,
+		Conditions:          status.Conditions,
+	}
+	for _, version := range status.Versions {
+		dst.Status.Versions = append(dst.Status.Versions, ObjectVersion(version))
+	}
+	if status.DryRun != nil {
+		dryRun := DryRunStatus(*status.DryRun)
+		dst.Status.DryRun = &dryRun
+	}
+	if status.Upload != nil {
+		dst.Status.Upload = &UploadStatus{
+			JobName:        status.Upload.JobName,
+			Phase:          string(status.Upload.Phase),
+			StartTime:      status.Upload.StartTime,
+			CompletionTime: status.Upload.CompletionTime,
+			Message:        status.Upload.Message,
+			Failures:       status.Upload.Failures,
+		}
+	}
+
+	return nil
+}
+
+// the string fields of v1alpha1 are matched case insensitively, values
+// without a v1beta1 counterpart are passed through for validation to reject
+
+func deletionPolicyToHub(policy string) v1beta1.DeletionPolicy {
+	switch strings.ToLower(policy) {
+	case "delete":
+		return v1beta1.DeletionDelete
+	case "retain":
+		return v1beta1.DeletionRetain
+	case "orphan-on-mismatch":
+		return v1beta1.DeletionOrphanOnMismatch
+	}
+	return v1beta1.DeletionPolicy(policy)
+}
+
+func deletionPolicyFromHub(policy v1beta1.DeletionPolicy) string {
+	if policy == v1beta1.DeletionOrphanOnMismatch {
+		return "Orphan-On-Mismatch"
+	}
+	return string(policy)
+}
+
+func credentialsSourceToHub(source string) v1beta1.CredentialsSource {
+	if strings.EqualFold(source, string(v1beta1.CredentialsSecret)) {
+		return v1beta1.CredentialsSecret
+	}
+	return v1beta1.CredentialsSource(source)
+}
+
+func sourceToHub(src ObjectSource, preserved string) v1beta1.ObjectSource {
+	hubOnly := preservedSource(preserved)
+	switch strings.ToLower(src.Reference) {
+	case "local", "":
+		dst := v1beta1.ObjectSource{Type: v1beta1.SourceInline}
+		if src.Data != "" {
+			dst.Inline = &v1beta1.InlineSource{Data: src.Data}
+			if hubOnly.Type == dst.Type && hubOnly.Inline != nil {
+				dst.Inline.Template = hubOnly.Inline.Template
+			}
+		}
+		return dst
+	case "configmap":
+		return v1beta1.ObjectSource{
+			Type: v1beta1.SourceConfigMap,
+			ConfigMap: &v1beta1.ConfigMapSource{
+				Namespace: src.Namespace,
+				Name:      src.Name,
+				Key:       src.Key,
+			},
+		}
+	}
+	dst := v1beta1.ObjectSource{Type: v1beta1.SourceType(src.Reference)}
+	if hubOnly.Type == dst.Type {
+		dst.HTTP = hubOnly.HTTP
+		dst.OCI = hubOnly.OCI
+		dst.PVC = hubOnly.PVC
+		dst.S3 = hubOnly.S3
+	}
+	return dst
+}
+
+// preservedSource returns the source members preserved as json by
+// hubOnlySource, or an empty source if there are none
+func preservedSource(preserved string) v1beta1.ObjectSource {
+	var hubOnly v1beta1.ObjectSource
+	if preserved == "" {
+		return hubOnly
+	}
+	if err := json.Unmarshal([]byte(preserved), &hubOnly); err != nil {
+		return v1beta1.ObjectSource{}
+	}
+	return hubOnly
+}
+
+// hubOnlySource returns the members of src without a v1alpha1 counterpart as
+// json, or an empty string if src has none. Only the template of an inline
+// source is kept, its data being converted.
+func hubOnlySource(src v1beta1.ObjectSource) string {
+	hubOnly := v1beta1.ObjectSource{Type: src.Type, HTTP: src.HTTP, OCI: src.OCI, PVC: src.PVC, S3: src.S3}
+	if src.Inline != nil && src.Inline.Template != nil {
+		hubOnly.Inline = &v1beta1.InlineSource{Template: src.Inline.Template}
+	}
+	if hubOnly.Inline == nil && hubOnly.HTTP == nil && hubOnly.OCI == nil && hubOnly.PVC == nil && hubOnly.S3 == nil {
+		return ""
+	}
+	data, err := json.Marshal(hubOnly)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// withAnnotation returns a copy of annotations with key set to value, or
+// removed if value is empty
+func withAnnotation(annotations map[string]string, key, value string) map[string]string {
+	if _, ok := annotations[key]; !ok && value == "" {
+		return annotations
+	}
+
+	copied := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		copied[k] = v
+	}
+	if value == "" {
+		delete(copied, key)
+	} else {
+		copied[key] = value
+	}
+	if len(copied) == 0 {
+		return nil
+	}
+	return copied
+}
+
+func sourceFromHub(src v1beta1.ObjectSource) ObjectSource {
+	var dst ObjectSource
+	switch src.Type {
+	case v1beta1.SourceInline, "":
+		dst.Reference = "local"
+	case v1beta1.SourceConfigMap:
+		dst.Reference = "configmap"
+	default:
+		dst.Reference = string(src.Type)
+	}
+	if src.Inline != nil {
+		dst.Data = src.Inline.Data
+	}
+	if src.ConfigMap != nil {
+		dst.Namespace = src.ConfigMap.Namespace
+		dst.Name = src.ConfigMap.Name
+		dst.Key = src.ConfigMap.Key
+	}
+	return dst
+}
+
+func targetToHub(src ObjectTarget) v1beta1.ObjectTarget {
+	dst := v1beta1.ObjectTarget{
+		Bucket:                src.Bucket,
+		Region:                src.Region,
+		Key:                   src.Key,
+		CreateBucketIfMissing: src.CreateBucketIfMissing,
+	}
+	if src.BucketSettings != nil {
+		settings := v1beta1.BucketSettings(*src.BucketSettings)
+		dst.BucketSettings = &settings
+	}
+	if src.RateLimit != nil {
+		limit := v1beta1.RateLimit(*src.RateLimit)
+		dst.RateLimit = &limit
+	}
+	return dst
+}
+
+func targetFromHub(src v1beta1.ObjectTarget) ObjectTarget {
+	dst := ObjectTarget{
+		Bucket:                src.Bucket,
+		Region:                src.Region,
+		Key:                   src.Key,
+		CreateBucketIfMissing: src.CreateBucketIfMissing,
+	}
+	if src.BucketSettings != nil {
+		settings := BucketSettings(*src.BucketSettings)
+		dst.BucketSettings = &settings
+	}
+	if src.RateLimit != nil {
+		limit := RateLimit(*src.RateLimit)
+		dst.RateLimit = &limit
+	}
+	return dst
+}
+
+func historyModeToHub(mode string) v1beta1.HistoryMode {
+	switch strings.ToLower(mode) {
+	case "key":
+		return v1beta1.HistoryKey
+	case "versionid":
+		return v1beta1.HistoryVersionID
+	}
+	return v1beta1.HistoryMode(mode)
+}
+
+func historyModeFromHub(mode v1beta1.HistoryMode) string {
+	switch mode {
+	case v1beta1.HistoryKey, v1beta1.HistoryVersionID:
+		return strings.ToLower(string(mode))
+	}
+	return string(mode)
+}
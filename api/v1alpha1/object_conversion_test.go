@@ -0,0 +1,238 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+var _ = Describe("Object conversion", func() {
+	var now = metav1.Unix(1640000000, 0)
+
+	spoke := func() *Object {
+		return &Object{
+			ObjectMeta: metav1.ObjectMeta{Name: "object", Namespace: "default", Generation: 3},
+			Spec: ObjectSpec{
+				DeletionPolicy: "Orphan-On-Mismatch",
+				Credentials: Credentials{
+					Source: "Secret",
+					SecretReference: SecretKeySelector{
+						SecretReference: SecretReference{Name: "creds", Namespace: "default"},
+						Key:             "aws.creds",
+					},
+				},
+				Source: ObjectSource{Reference: "configmap", Namespace: "default", Name: "scripts", Key: "run.sh"},
+				Target: ObjectTarget{
+					Bucket:                "bucket",
+					Region:                "us-west-2",
+					Key:                   "scripts/run.sh",
+					CreateBucketIfMissing: true,
+					BucketSettings:        &BucketSettings{Encryption: "aws:kms", KMSKeyID: "key", Versioning: true},
+					RateLimit:             &RateLimit{RequestsPerSecond: 5, Burst: 10, MaxAttempts: 3, RetryMode: "adaptive"},
+				},
+				History: &ObjectHistory{Mode: "versionid", Limit: 3},
+				DryRun:  true,
+				Suspend: true,
+			},
+			Status: ObjectStatus{
+				Synced:             true,
+				Reference:          "s3://bucket/scripts/run.sh",
+				Checksum:           "sum",
+				ETag:               "etag",
+				ObservedGeneration: 3,
+				Versions:           []ObjectVersion{{Key: "scripts/run.sh", VersionID: "v1", Checksum: "sum", Timestamp: now}},
+				DryRun:             &DryRunStatus{Action: "store", Reference: "s3://bucket/scripts/run.sh", Size: 4, Checksum: "sum"},
+				Upload:             &UploadStatus{JobName: "object-upload", Phase: "Failed", StartTime: &now, CompletionTime: &now, Message: "deadline exceeded", Failures: 2},
+				VersionedBucket:    "bucket",
+				Conditions: []metav1.Condition{{
+					Type: "Synced", Status: metav1.ConditionTrue, Reason: "Succeeded", LastTransitionTime: now,
+				}},
+			},
+		}
+	}
+
+	It("round trips from v1alpha1 through the hub", func() {
+		original := spoke()
+
+		var hub v1beta1.Object
+		Expect(original.ConvertTo(&hub)).To(Succeed())
+		var converted Object
+		Expect(converted.ConvertFrom(&hub)).To(Succeed())
+
+		Expect(converted).To(Equal(*original))
+	})
+
+	It("round trips from the hub through v1alpha1", func() {
+		var hub v1beta1.Object
+		Expect(spoke().ConvertTo(&hub)).To(Succeed())
+		hub.Spec.Source = v1beta1.ObjectSource{
+			Type:   v1beta1.SourceInline,
+			Inline: &v1beta1.InlineSource{Data: "content"},
+		}
+		hub.Spec.History.Mode = v1beta1.HistoryKey
+
+		var converted Object
+		Expect(converted.ConvertFrom(&hub)).To(Succeed())
+		var roundTripped v1beta1.Object
+		Expect(converted.ConvertTo(&roundTripped)).To(Succeed())
+
+		Expect(roundTripped).To(Equal(hub))
+	})
+
+	It("preserves remote sources through v1alpha1", func() {
+		var hub v1beta1.Object
+		Expect(spoke().ConvertTo(&hub)).To(Succeed())
+		hub.Spec.Source = v1beta1.ObjectSource{
+			Type: v1beta1.SourceOCI,
+			OCI: &v1beta1.OCISource{
+				Reference:     "ghcr.io/org/config:v1",
+				File:          "app.yaml",
+				PullSecretRef: &v1beta1.SourceSecretKeySelector{Name: "registry", Key: ".dockerconfigjson"},
+				Interval:      &metav1.Duration{Duration: 10 * time.Minute},
+			},
+		}
+		hub.Status.SourceRevision = "sha256:digest"
+
+		var converted Object
+		Expect(converted.ConvertFrom(&hub)).To(Succeed())
+		Expect(converted.Spec.Source.Reference).To(Equal("OCI"))
+		Expect(converted.Annotations).To(HaveKey(SourceAnnotation))
+		Expect(hub.Annotations).NotTo(HaveKey(SourceAnnotation))
+
+		var roundTripped v1beta1.Object
+		Expect(converted.ConvertTo(&roundTripped)).To(Succeed())
+		Expect(roundTripped).To(Equal(hub))
+	})
+
+	It("preserves PVC sources through v1alpha1", func() {
+		fsGroup := int64(2000)
+		var hub v1beta1.Object
+		Expect(spoke().ConvertTo(&hub)).To(Succeed())
+		hub.Annotations = map[string]string{"team": "reports"}
+		hub.Spec.Source = v1beta1.ObjectSource{
+			Type: v1beta1.SourcePVC,
+			PVC: &v1beta1.PVCSource{
+				ClaimName: "reports",
+				Path:      "out/summary.csv",
+				FSGroup:   &fsGroup,
+				Timeout:   &metav1.Duration{Duration: time.Hour},
+			},
+		}
+		hub.Spec.History = nil
+		hub.Status.Upload = &v1beta1.UploadStatus{JobName: "object-upload", Phase: v1beta1.UploadRunning, StartTime: &now}
+
+		var converted Object
+		Expect(converted.ConvertFrom(&hub)).To(Succeed())
+		var roundTripped v1beta1.Object
+		Expect(converted.ConvertTo(&roundTripped)).To(Succeed())
+		Expect(roundTripped).To(Equal(hub))
+	})
+
+	It("preserves the template of local sources through v1alpha1", func() {
+		var hub v1beta1.Object
+		Expect(spoke().ConvertTo(&hub)).To(Succeed())
+		hub.Spec.Source = v1beta1.ObjectSource{
+			Type: v1beta1.SourceInline,
+			Inline: &v1beta1.InlineSource{
+				Data: "endpoint: {{ .Values.api }}",
+				Template: &v1beta1.InlineTemplate{
+					Inputs: []v1beta1.TemplateInput{{
+						Name:            "api",
+						ConfigMapKeyRef: &v1beta1.ConfigMapSource{Name: "endpoints", Key: "api"},
+					}},
+				},
+			},
+		}
+
+		var converted Object
+		Expect(converted.ConvertFrom(&hub)).To(Succeed())
+		Expect(converted.Spec.Source.Data).To(Equal("endpoint: {{ .Values.api }}"))
+		var roundTripped v1beta1.Object
+		Expect(converted.ConvertTo(&roundTripped)).To(Succeed())
+		Expect(roundTripped).To(Equal(hub))
+	})
+
+	It("preserves S3 sources through v1alpha1", func() {
+		var hub v1beta1.Object
+		Expect(spoke().ConvertTo(&hub)).To(Succeed())
+		hub.Spec.Source = v1beta1.ObjectSource{
+			Type: v1beta1.SourceS3,
+			S3: &v1beta1.S3Source{
+				Bucket:   "source-bucket",
+				Key:      "reports/summary.csv",
+				Region:   "us-east-1",
+				Endpoint: "https://minio.example.com",
+			},
+		}
+
+		var converted Object
+		Expect(converted.ConvertFrom(&hub)).To(Succeed())
+		var roundTripped v1beta1.Object
+		Expect(converted.ConvertTo(&roundTripped)).To(Succeed())
+		Expect(roundTripped).To(Equal(hub))
+	})
+
+	It("preserves the output format through v1alpha1", func() {
+		var hub v1beta1.Object
+		Expect(spoke().ConvertTo(&hub)).To(Succeed())
+		hub.Spec.Output = &v1beta1.ObjectOutput{Format: v1beta1.OutputJSON}
+
+		var converted Object
+		Expect(converted.ConvertFrom(&hub)).To(Succeed())
+		Expect(converted.Annotations).To(HaveKeyWithValue(OutputAnnotation, "JSON"))
+		var roundTripped v1beta1.Object
+		Expect(converted.ConvertTo(&roundTripped)).To(Succeed())
+		Expect(roundTripped).To(Equal(hub))
+	})
+
+	It("maps the loosely typed fields onto the v1beta1 enums", func() {
+		obj := spoke()
+		obj.Spec.DeletionPolicy = "delete"
+		obj.Spec.Credentials.Source = "secret"
+		obj.Spec.Source = ObjectSource{Data: "content"}
+		obj.Spec.History.Mode = "Key"
+
+		var hub v1beta1.Object
+		Expect(obj.ConvertTo(&hub)).To(Succeed())
+
+		Expect(hub.Spec.DeletionPolicy).To(Equal(v1beta1.DeletionDelete))
+		Expect(hub.Spec.Credentials.Source).To(Equal(v1beta1.CredentialsSecret))
+		Expect(hub.Spec.Source).To(Equal(v1beta1.ObjectSource{
+			Type:   v1beta1.SourceInline,
+			Inline: &v1beta1.InlineSource{Data: "content"},
+		}))
+		Expect(hub.Spec.History.Mode).To(Equal(v1beta1.HistoryKey))
+	})
+
+	It("passes unknown values through for validation to reject", func() {
+		obj := spoke()
+		obj.Spec.DeletionPolicy = "Keep"
+		obj.Spec.Source = ObjectSource{Reference: "http"}
+
+		var hub v1beta1.Object
+		Expect(obj.ConvertTo(&hub)).To(Succeed())
+
+		Expect(hub.Spec.DeletionPolicy).To(Equal(v1beta1.DeletionPolicy("Keep")))
+		Expect(hub.Spec.Source.Type).To(Equal(v1beta1.SourceType("http")))
+	})
+})
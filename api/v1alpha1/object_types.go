@@ -43,9 +43,12 @@ type Credentials struct {
 }
 
 // An ObjectSource refers to the location to get the object from
+// +kubebuilder:validation:XValidation:rule="!self.reference.matches('^(?i)local$') || (has(self.data) && self.data != '')",message="data is required for a local reference"
+// +kubebuilder:validation:XValidation:rule="!self.reference.matches('^(?i)configmap$') || (has(self.name) && self.name != '' && has(self.key) && self.key != '')",message="name and key are required for a configmap reference"
 type ObjectSource struct {
 	// sourcetype: local / configmap
 	// +kubebuilder:default:=local
+	// +kubebuilder:validation:MaxLength:=16
 	Reference string `json:"reference,omitempty"`
 	// namespace for configmap
 	Namespace string `json:"namespace,omitempty"`
@@ -61,18 +64,119 @@ type ObjectSource struct {
 type ObjectTarget struct {
 	// reference to where the object will be stored
 	Bucket string `json:"bucket,required"`
-	// region to be used for creds
-	Region string `json:"region,required"`
+	// region to be used for creds, the default region of the controller
+	// if empty
+	Region string `json:"region,omitempty"`
 	// object key
 	Key string `json:"key,required"`
+	// create the bucket in the target region if it does not exist
+	CreateBucketIfMissing bool `json:"createBucketIfMissing,omitempty"`
+	// settings applied to a bucket created by the controller
+	BucketSettings *BucketSettings `json:"bucketSettings,omitempty"`
+	// pacing and retries of the calls to the bucket, overriding the
+	// controller defaults
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// A RateLimit paces the calls made to a bucket, shared by all objects storing
+// into the bucket with the same credentials
+type RateLimit struct {
+	// requests per second allowed
+	// +kubebuilder:validation:Minimum:=1
+	RequestsPerSecond int `json:"requestsPerSecond,omitempty"`
+	// requests allowed above the rate in bursts
+	// +kubebuilder:validation:Minimum:=1
+	Burst int `json:"burst,omitempty"`
+	// attempts of every call, including retries
+	// +kubebuilder:validation:Minimum:=1
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// retry mode: standard / adaptive
+	// adaptive lowers the request rate while calls are throttled
+	// +kubebuilder:validation:Enum:=standard;adaptive
+	RetryMode string `json:"retryMode,omitempty"`
+}
+
+// BucketSettings configure a bucket created by the controller
+type BucketSettings struct {
+	// allow public access to the bucket, all public access is blocked otherwise
+	AllowPublicAccess bool `json:"allowPublicAccess,omitempty"`
+	// default server side encryption: AES256 / aws:kms
+	// +kubebuilder:default:=AES256
+	// +kubebuilder:validation:Enum:=AES256;"aws:kms"
+	Encryption string `json:"encryption,omitempty"`
+	// KMS key used for aws:kms encryption, the AWS managed key if empty
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
+	// enable versioning on the bucket
+	Versioning bool `json:"versioning,omitempty"`
+}
+
+// An ObjectHistory configures how previous versions of the object are retained
+type ObjectHistory struct {
+	// versioning mode: key / versionid
+	// key stores each change under `<key>.<timestamp>`, versionid relies on
+	// the version ids of a bucket with versioning enabled
+	// +kubebuilder:default:=key
+	Mode string `json:"mode,omitempty"`
+	// number of versions to keep, older versions are pruned
+	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum:=1
+	Limit int `json:"limit,omitempty"`
 }
 
 // ObjectSpec defines the desired state of Object
 type ObjectSpec struct {
-	DeletionPolicy string       `json:"deletionPolicy"`
-	Credentials    Credentials  `json:"credentials,required"`
-	Source         ObjectSource `json:"source,required"`
-	Target         ObjectTarget `json:"target,required"`
+	// what happens to the stored object on deletion:
+	// Delete / Retain / Orphan-On-Mismatch
+	// the default deletion policy of the controller if empty
+	DeletionPolicy string         `json:"deletionPolicy,omitempty"`
+	Credentials    Credentials    `json:"credentials,required"`
+	Source         ObjectSource   `json:"source,required"`
+	Target         ObjectTarget   `json:"target,required"`
+	History        *ObjectHistory `json:"history,omitempty"`
+	// resolve the source and credentials without modifying the object store
+	DryRun bool `json:"dryRun,omitempty"`
+	// halt store and delete operations against the object store
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// An ObjectVersion refers to a version of the object kept in the object store
+type ObjectVersion struct {
+	// object key the version is stored under
+	Key string `json:"key"`
+	// version id assigned by the object store
+	VersionID string `json:"versionId,omitempty"`
+	// sha256 checksum of the version content
+	Checksum string `json:"checksum"`
+	// time the version was stored
+	Timestamp metav1.Time `json:"timestamp"`
+}
+
+// A DryRunStatus reports the operation the controller would have performed
+type DryRunStatus struct {
+	// operation that would have been performed: store / delete
+	Action string `json:"action"`
+	// object store reference the operation applies to
+	Reference string `json:"reference"`
+	// size in bytes of the content that would have been stored
+	Size int `json:"size,omitempty"`
+	// sha256 checksum of the content that would have been stored
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// An UploadStatus reports the job uploading a PVC source
+type UploadStatus struct {
+	// name of the job
+	JobName string `json:"jobName"`
+	// phase of the job: Running / Succeeded / Failed
+	Phase string `json:"phase"`
+	// time the job started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// time the job succeeded or failed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// failure reported by the job
+	Message string `json:"message,omitempty"`
+	// failed jobs of the current upload
+	Failures int `json:"failures,omitempty"`
 }
 
 // ObjectStatus defines the observed state of Object
@@ -80,11 +184,39 @@ type ObjectStatus struct {
 	// +kubebuilder:default:=false
 	Synced    bool   `json:"synced"`
 	Reference string `json:"reference"`
+	// sha256 checksum of the last synced content
+	Checksum string `json:"checksum,omitempty"`
+	// etag returned by the object store for the last synced content
+	ETag string `json:"etag,omitempty"`
+	// generation of the spec the last synced content was based on
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// versions kept in the object store, newest first
+	Versions []ObjectVersion `json:"versions,omitempty"`
+	// operation planned by the last dry run
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+	// value of the last handled resync-at annotation
+	LastHandledResyncAt string `json:"lastHandledResyncAt,omitempty"`
+	// number of failed attempts to delete the object from the object store
+	DeletionAttempts int `json:"deletionAttempts,omitempty"`
+	// revision of the last synced remote source, remote sources are only
+	// available in v1beta1
+	SourceRevision string `json:"sourceRevision,omitempty"`
+	// job uploading the PVC source, PVC sources are only available in
+	// v1beta1
+	Upload *UploadStatus `json:"upload,omitempty"`
+	// target bucket the object was last stored into, if versioning is
+	// enabled on it
+	VersionedBucket string `json:"versionedBucket,omitempty"`
+	// conditions of the object
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.synced",description="Whether or not the sync succeeded"
+//+kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend",description="Whether or not syncing is suspended"
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 //+kubebuilder:printcolumn:name="Reference",type="string",JSONPath=".status.reference",description="Object reference in the target object store"
 
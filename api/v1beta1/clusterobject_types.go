@@ -0,0 +1,89 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Storable is implemented by the kinds whose source is stored into an object
+// store, which share the spec and status of Object
+// +kubebuilder:object:generate=false
+type Storable interface {
+	client.Object
+	GetSpec() *ObjectSpec
+	GetStatus() *ObjectStatus
+}
+
+// ClusterObjectSpec defines the desired state of ClusterObject
+// +kubebuilder:validation:XValidation:rule="self.source.type != 'ConfigMap' || !has(self.source.configMap) || (has(self.source.configMap.__namespace__) && has(self.allowedNamespaces) && self.source.configMap.__namespace__ in self.allowedNamespaces)",message="a ConfigMap source must be in one of the allowed namespaces"
+// +kubebuilder:validation:XValidation:rule="self.source.type != 'Resource' || !has(self.source.resource) || !has(self.source.resource.__namespace__) || (has(self.allowedNamespaces) && self.source.resource.__namespace__ in self.allowedNamespaces)",message="a namespaced Resource source must be in one of the allowed namespaces"
+// +kubebuilder:validation:XValidation:rule="self.source.type != 'PVC'",message="PVC sources are only supported by Object"
+type ClusterObjectSpec struct {
+	ObjectSpec `json:",inline"`
+
+	// namespaces sources may be read from, only cluster-scoped resources
+	// if empty
+	// +kubebuilder:validation:MaxItems:=64
+	// +kubebuilder:validation:items:MaxLength:=63
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.synced",description="Whether or not the sync succeeded"
+//+kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend",description="Whether or not syncing is suspended"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:printcolumn:name="Reference",type="string",JSONPath=".status.reference",description="Object reference in the target object store"
+
+// ClusterObject is the Schema for the clusterobjects API, storing cluster-level
+// resources without belonging to a namespace
+// +kubebuilder:validation:XValidation:rule="self.spec.target.bucket == oldSelf.spec.target.bucket || (has(oldSelf.status) && has(oldSelf.status.versionedBucket) && oldSelf.status.versionedBucket == oldSelf.spec.target.bucket)",message="target.bucket is immutable unless versioning is enabled on the bucket"
+type ClusterObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterObjectSpec `json:"spec,omitempty"`
+	Status ObjectStatus      `json:"status,omitempty"`
+}
+
+// GetSpec returns the spec shared with Object
+func (o *ClusterObject) GetSpec() *ObjectSpec {
+	return &o.Spec.ObjectSpec
+}
+
+// GetStatus returns the status of the object
+func (o *ClusterObject) GetStatus() *ObjectStatus {
+	return &o.Status
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterObjectList contains a list of ClusterObject
+type ClusterObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterObject `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterObject{}, &ClusterObjectList{})
+}
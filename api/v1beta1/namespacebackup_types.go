@@ -0,0 +1,129 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A BackupResource selects the resources of a kind gathered into a backup
+type BackupResource struct {
+	// api version of the resources, e.g. apps/v1
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=253
+	APIVersion string `json:"apiVersion"`
+	// kind of the resources, e.g. Deployment
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=63
+	Kind string `json:"kind"`
+	// label selector of the resources, all resources of the kind if empty
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// BackupEncryption configures the encryption of the Secrets of a backup
+type BackupEncryption struct {
+	// secret key holding the 32 bytes AES-256 key the Secrets are encrypted
+	// with
+	KeyReference SecretKeySelector `json:"keyRef"`
+}
+
+// NamespaceBackupSpec defines the desired state of NamespaceBackup
+// +kubebuilder:validation:XValidation:rule="self.resources.all(r, r.kind != 'Secret')",message="Secrets are gathered with includeSecrets"
+// +kubebuilder:validation:XValidation:rule="!has(self.encryption) || (has(self.includeSecrets) && self.includeSecrets)",message="encryption requires includeSecrets"
+type NamespaceBackupSpec struct {
+	// kinds of the resources gathered from the namespace of the backup
+	// +kubebuilder:validation:MinItems:=1
+	// +kubebuilder:validation:MaxItems:=32
+	Resources []BackupResource `json:"resources"`
+	// gather the Secrets of the namespace, stored in plain text unless
+	// encryption is set
+	// +optional
+	IncludeSecrets bool `json:"includeSecrets,omitempty"`
+	// encryption of the Secrets in the archive
+	// +optional
+	Encryption *BackupEncryption `json:"encryption,omitempty"`
+	// what happens to the archive on deletion, the default deletion policy
+	// of the controller if empty. Retain keeps the archive, while the other
+	// policies delete it.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	Credentials    Credentials    `json:"credentials"`
+	// target of the archive, a tar.gz of YAML manifests and an index
+	Target ObjectTarget `json:"target"`
+	// halt backups and deletes against the object store
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// NamespaceBackupStatus defines the observed state of NamespaceBackup
+type NamespaceBackupStatus struct {
+	// object store reference of the archive
+	// +optional
+	Reference string `json:"reference,omitempty"`
+	// time of the last backup
+	// +optional
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+	// number of resources in the last backup
+	// +optional
+	ResourceCount int `json:"resourceCount,omitempty"`
+	// sha256 checksum of the last archive
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+	// generation of the spec the last backup was based on
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// value of the last handled resync-at annotation
+	// +optional
+	LastHandledResyncAt string `json:"lastHandledResyncAt,omitempty"`
+	// conditions of the backup
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Resources",type="integer",JSONPath=".status.resourceCount",description="Number of resources in the last backup"
+//+kubebuilder:printcolumn:name="Last Backup",type="date",JSONPath=".status.lastBackupTime",description="Time of the last backup"
+//+kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend",description="Whether or not backups are suspended"
+//+kubebuilder:printcolumn:name="Reference",type="string",JSONPath=".status.reference",description="Archive reference in the target object store"
+
+// NamespaceBackup is the Schema for the namespacebackups API, archiving the
+// resources of its namespace into a single object
+type NamespaceBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceBackupSpec   `json:"spec,omitempty"`
+	Status NamespaceBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NamespaceBackupList contains a list of NamespaceBackup
+type NamespaceBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceBackup{}, &NamespaceBackupList{})
+}
@@ -0,0 +1,612 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A SecretReference is a reference to a secret in an arbitrary namespace.
+type SecretReference struct {
+	// Name of the secret.
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+
+	// Namespace of the secret.
+	// +kubebuilder:validation:MinLength:=1
+	Namespace string `json:"namespace"`
+}
+
+// A SecretKeySelector is a reference to a secret key in an arbitrary namespace.
+type SecretKeySelector struct {
+	SecretReference `json:",inline"`
+
+	// The key to select.
+	// +kubebuilder:validation:MinLength:=1
+	Key string `json:"key"`
+}
+
+// CredentialsSource is where the credentials of the object store are read from
+// +kubebuilder:validation:Enum:=Secret
+type CredentialsSource string
+
+const (
+	// CredentialsSecret reads the credentials from a key of a secret
+	CredentialsSecret CredentialsSource = "Secret"
+)
+
+type Credentials struct {
+	// source of the credentials
+	// +kubebuilder:default:=Secret
+	// +optional
+	Source CredentialsSource `json:"source,omitempty"`
+	// secret key holding the credentials
+	SecretReference SecretKeySelector `json:"secretRef"`
+}
+
+// SourceType is the kind of location the object is read from
+// +kubebuilder:validation:Enum:=Inline;ConfigMap;Resource;HTTP;OCI;PVC;S3
+type SourceType string
+
+const (
+	// SourceInline reads the object from the spec itself
+	SourceInline SourceType = "Inline"
+	// SourceConfigMap reads the object from a key of a configmap
+	SourceConfigMap SourceType = "ConfigMap"
+	// SourceResource reads the manifest of a Kubernetes resource, only
+	// supported by ClusterObject
+	SourceResource SourceType = "Resource"
+	// SourceHTTP fetches the object from an HTTP(S) URL
+	SourceHTTP SourceType = "HTTP"
+	// SourceOCI pulls the object from a layer of an OCI artifact
+	SourceOCI SourceType = "OCI"
+	// SourcePVC uploads a file or directory of a persistent volume claim
+	// through a job, only supported by Object
+	SourcePVC SourceType = "PVC"
+	// SourceS3 copies the object from another bucket
+	SourceS3 SourceType = "S3"
+)
+
+// An ObjectSource refers to the location to get the object from, exactly one
+// of the members matching the type is read
+// +union
+// +kubebuilder:validation:XValidation:rule="self.type != 'Inline' || (has(self.inline) && !has(self.configMap) && !has(self.resource) && !has(self.http) && !has(self.oci) && !has(self.pvc) && !has(self.s3))",message="an Inline source requires inline and no other member"
+// +kubebuilder:validation:XValidation:rule="self.type != 'ConfigMap' || (has(self.configMap) && !has(self.inline) && !has(self.resource) && !has(self.http) && !has(self.oci) && !has(self.pvc) && !has(self.s3))",message="a ConfigMap source requires configMap and no other member"
+// +kubebuilder:validation:XValidation:rule="self.type != 'Resource' || (has(self.resource) && !has(self.inline) && !has(self.configMap) && !has(self.http) && !has(self.oci) && !has(self.pvc) && !has(self.s3))",message="a Resource source requires resource and no other member"
+// +kubebuilder:validation:XValidation:rule="self.type != 'HTTP' || (has(self.http) && !has(self.inline) && !has(self.configMap) && !has(self.resource) && !has(self.oci) && !has(self.pvc) && !has(self.s3))",message="an HTTP source requires http and no other member"
+// +kubebuilder:validation:XValidation:rule="self.type != 'OCI' || (has(self.oci) && !has(self.inline) && !has(self.configMap) && !has(self.resource) && !has(self.http) && !has(self.pvc) && !has(self.s3))",message="an OCI source requires oci and no other member"
+// +kubebuilder:validation:XValidation:rule="self.type != 'PVC' || (has(self.pvc) && !has(self.inline) && !has(self.configMap) && !has(self.resource) && !has(self.http) && !has(self.oci) && !has(self.s3))",message="a PVC source requires pvc and no other member"
+// +kubebuilder:validation:XValidation:rule="self.type != 'S3' || (has(self.s3) && !has(self.inline) && !has(self.configMap) && !has(self.resource) && !has(self.http) && !has(self.oci) && !has(self.pvc))",message="an S3 source requires s3 and no other member"
+type ObjectSource struct {
+	// type of the source
+	// +unionDiscriminator
+	// +kubebuilder:default:=Inline
+	// +optional
+	Type SourceType `json:"type,omitempty"`
+	// content held in the spec
+	// +optional
+	Inline *InlineSource `json:"inline,omitempty"`
+	// content read from a configmap
+	// +optional
+	ConfigMap *ConfigMapSource `json:"configMap,omitempty"`
+	// manifest of a Kubernetes resource
+	// +optional
+	Resource *ResourceSource `json:"resource,omitempty"`
+	// content served over HTTP(S)
+	// +optional
+	HTTP *HTTPSource `json:"http,omitempty"`
+	// layer of an OCI artifact
+	// +optional
+	OCI *OCISource `json:"oci,omitempty"`
+	// file or directory of a persistent volume claim
+	// +optional
+	PVC *PVCSource `json:"pvc,omitempty"`
+	// object of another bucket
+	// +optional
+	S3 *S3Source `json:"s3,omitempty"`
+}
+
+// An InlineSource holds the raw content of the object, or a template of it
+type InlineSource struct {
+	// raw content for the object
+	// +kubebuilder:validation:MinLength:=1
+	Data string `json:"data"`
+	// render data as a Go template before storing it, rendered again
+	// whenever one of its inputs changes
+	// +optional
+	Template *InlineTemplate `json:"template,omitempty"`
+}
+
+// An InlineTemplate renders the data of an inline source with the metadata of
+// the object, the cluster info and the values of its inputs
+type InlineTemplate struct {
+	// values read from configmaps and secrets, referenced as
+	// {{ .Values.<name> }}
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems:=32
+	// +optional
+	Inputs []TemplateInput `json:"inputs,omitempty"`
+}
+
+// A TemplateInput is a value of a template read from a key of a configmap or
+// a secret
+// +kubebuilder:validation:XValidation:rule="has(self.configMapKeyRef) != has(self.secretKeyRef)",message="an input requires exactly one of configMapKeyRef and secretKeyRef"
+type TemplateInput struct {
+	// name of the value in the template
+	// +kubebuilder:validation:Pattern:=`^[A-Za-z_][A-Za-z0-9_]*$`
+	// +kubebuilder:validation:MaxLength:=63
+	Name string `json:"name"`
+	// key of a configmap holding the value, objects may only read
+	// configmaps in their own namespace and cluster objects in their
+	// allowed namespaces
+	// +optional
+	ConfigMapKeyRef *ConfigMapSource `json:"configMapKeyRef,omitempty"`
+	// key of a secret holding the value
+	// +optional
+	SecretKeyRef *SourceSecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// A ConfigMapSource refers to a key of a configmap
+type ConfigMapSource struct {
+	// namespace of the configmap, the namespace of the object if empty
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// name of the configmap
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// key of the configmap holding the content
+	// +kubebuilder:validation:MinLength:=1
+	Key string `json:"key"`
+}
+
+// A ResourceSource refers to a Kubernetes resource, stored as a YAML manifest
+// without its status and server populated metadata
+type ResourceSource struct {
+	// api version of the resource, e.g. apiextensions.k8s.io/v1
+	// +kubebuilder:validation:MinLength:=1
+	APIVersion string `json:"apiVersion"`
+	// kind of the resource, e.g. CustomResourceDefinition
+	// +kubebuilder:validation:MinLength:=1
+	Kind string `json:"kind"`
+	// name of the resource
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// namespace of a namespaced resource, empty for a cluster-scoped resource
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// A SourceSecretKeySelector refers to a key of a secret holding the
+// credentials of a remote source
+type SourceSecretKeySelector struct {
+	// namespace of the secret, the namespace of the object if empty,
+	// objects may only read secrets in their own namespace and cluster
+	// objects in their allowed namespaces
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// name of the secret
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// key of the secret holding the credentials
+	// +kubebuilder:validation:MinLength:=1
+	Key string `json:"key"`
+}
+
+// An HTTPSource refers to content served over HTTP(S), fetched again every
+// interval and only stored when its ETag changes
+type HTTPSource struct {
+	// URL of the content
+	// +kubebuilder:validation:Pattern:=`^https?://`
+	// +kubebuilder:validation:MaxLength:=2048
+	URL string `json:"url"`
+	// secret key holding the value of the Authorization header, e.g.
+	// `Bearer <token>`
+	// +optional
+	AuthSecretRef *SourceSecretKeySelector `json:"authSecretRef,omitempty"`
+	// sha256 checksum the content must match, pinning the content
+	// +kubebuilder:validation:Pattern:=`^[a-f0-9]{64}$`
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+	// interval between two fetches of the content
+	// +kubebuilder:default:="10m"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// An OCISource refers to a layer of an OCI artifact, pulled again every
+// interval and only stored when its manifest digest changes
+type OCISource struct {
+	// reference of the artifact, e.g. ghcr.io/org/artifact:v1 or
+	// ghcr.io/org/artifact@sha256:<digest>
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=512
+	Reference string `json:"reference"`
+	// media type of the layer, the layer must be unique if empty
+	// +optional
+	MediaType string `json:"mediaType,omitempty"`
+	// file name of the layer, as set by the org.opencontainers.image.title
+	// annotation
+	// +optional
+	File string `json:"file,omitempty"`
+	// secret key holding a docker config json with the credentials of the
+	// registry, anonymous pulls if empty
+	// +optional
+	PullSecretRef *SourceSecretKeySelector `json:"pullSecretRef,omitempty"`
+	// pull over plain HTTP, for registries without TLS, sending the
+	// credentials to token realms over plain HTTP as well
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+	// interval between two pulls of the artifact
+	// +kubebuilder:default:="10m"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// A PVCSource refers to a file or directory of a persistent volume claim in
+// the namespace of the object, uploaded by a job mounting the claim
+type PVCSource struct {
+	// name of the persistent volume claim
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=253
+	ClaimName string `json:"claimName"`
+	// path of a file or directory relative to the root of the volume, a
+	// directory is stored as a gzipped tarball
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=1024
+	// +kubebuilder:validation:XValidation:rule="!self.startsWith('/') && !self.split('/').exists(e, e == '..')",message="path must be relative to the root of the volume"
+	Path string `json:"path"`
+	// group of the uploader, typically the fsGroup of the workload writing
+	// the volume, so that files only readable by their group are uploaded
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	FSGroup *int64 `json:"fsGroup,omitempty"`
+	// how long the upload job may run, including waiting for the claim to be
+	// mounted, before it fails
+	// +kubebuilder:default:="1h"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// An S3Source refers to an object of another bucket, possibly in another
+// account, region or S3 compatible provider. The object is copied server side
+// when the target credentials can read it and downloaded then uploaded
+// otherwise, and is only copied again when its ETag changes.
+type S3Source struct {
+	// bucket of the source object
+	// +kubebuilder:validation:MinLength:=3
+	// +kubebuilder:validation:MaxLength:=63
+	Bucket string `json:"bucket"`
+	// key of the source object
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=1024
+	Key string `json:"key"`
+	// region of the source bucket, the region of the target if empty
+	// +optional
+	Region string `json:"region,omitempty"`
+	// credentials reading the source object, the credentials of the object
+	// if empty
+	// +optional
+	Credentials *Credentials `json:"credentials,omitempty"`
+	// URL of an S3 compatible provider serving the source bucket, the S3
+	// endpoint of the controller if empty
+	// +kubebuilder:validation:Pattern:=`^https?://`
+	// +kubebuilder:validation:MaxLength:=2048
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// interval between two checks of the source ETag
+	// +kubebuilder:default:="10m"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// An ObjectTarget refers to the object store reference to store the object into
+// +kubebuilder:validation:XValidation:rule="!has(self.bucketSettings) || (has(self.createBucketIfMissing) && self.createBucketIfMissing)",message="bucketSettings only apply with createBucketIfMissing"
+type ObjectTarget struct {
+	// reference to where the object will be stored
+	// +kubebuilder:validation:MinLength:=3
+	// +kubebuilder:validation:MaxLength:=63
+	Bucket string `json:"bucket"`
+	// region to be used for creds, the default region of the controller
+	// if empty
+	// +optional
+	Region string `json:"region,omitempty"`
+	// object key
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=1024
+	Key string `json:"key"`
+	// create the bucket in the target region if it does not exist
+	// +optional
+	CreateBucketIfMissing bool `json:"createBucketIfMissing,omitempty"`
+	// settings applied to a bucket created by the controller
+	// +optional
+	BucketSettings *BucketSettings `json:"bucketSettings,omitempty"`
+	// pacing and retries of the calls to the bucket, overriding the
+	// controller defaults
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// A RateLimit paces the calls made to a bucket, shared by all objects storing
+// into the bucket with the same credentials
+type RateLimit struct {
+	// requests per second allowed
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	RequestsPerSecond int `json:"requestsPerSecond,omitempty"`
+	// requests allowed above the rate in bursts
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	Burst int `json:"burst,omitempty"`
+	// attempts of every call, including retries
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// retry mode: standard / adaptive
+	// adaptive lowers the request rate while calls are throttled
+	// +kubebuilder:validation:Enum:=standard;adaptive
+	// +optional
+	RetryMode string `json:"retryMode,omitempty"`
+}
+
+// BucketSettings configure a bucket created by the controller
+// +kubebuilder:validation:XValidation:rule="!has(self.kmsKeyId) || self.encryption == 'aws:kms'",message="kmsKeyId requires aws:kms encryption"
+type BucketSettings struct {
+	// allow public access to the bucket, all public access is blocked otherwise
+	// +optional
+	AllowPublicAccess bool `json:"allowPublicAccess,omitempty"`
+	// default server side encryption: AES256 / aws:kms
+	// +kubebuilder:default:=AES256
+	// +kubebuilder:validation:Enum:=AES256;"aws:kms"
+	// +optional
+	Encryption string `json:"encryption,omitempty"`
+	// KMS key used for aws:kms encryption, the AWS managed key if empty
+	// +optional
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
+	// enable versioning on the bucket
+	// +optional
+	Versioning bool `json:"versioning,omitempty"`
+}
+
+// HistoryMode is how previous versions of the object are kept
+// +kubebuilder:validation:Enum:=Key;VersionID
+type HistoryMode string
+
+const (
+	// HistoryKey stores each change under `<key>.<timestamp>`
+	HistoryKey HistoryMode = "Key"
+	// HistoryVersionID relies on the version ids of a bucket with
+	// versioning enabled
+	HistoryVersionID HistoryMode = "VersionID"
+)
+
+// OutputFormat is the format the content of the source is stored in
+// +kubebuilder:validation:Enum:=Raw;JSON;YAML;TOML;Env;Properties
+type OutputFormat string
+
+const (
+	// OutputRaw stores the content as read from the source
+	OutputRaw OutputFormat = "Raw"
+	// OutputJSON stores the content as indented JSON
+	OutputJSON OutputFormat = "JSON"
+	// OutputYAML stores the content as YAML
+	OutputYAML OutputFormat = "YAML"
+	// OutputTOML stores a mapping as a TOML document
+	OutputTOML OutputFormat = "TOML"
+	// OutputEnv stores the leaves of a mapping as environment variables
+	OutputEnv OutputFormat = "Env"
+	// OutputProperties stores the leaves of a mapping as Java properties
+	OutputProperties OutputFormat = "Properties"
+)
+
+// An ObjectOutput converts the content of the source before it is stored
+type ObjectOutput struct {
+	// format of the stored object, the source is parsed as YAML or JSON
+	// unless the format is Raw
+	// +kubebuilder:default:=Raw
+	// +optional
+	Format OutputFormat `json:"format,omitempty"`
+}
+
+// An ObjectHistory configures how previous versions of the object are retained
+type ObjectHistory struct {
+	// versioning mode: Key / VersionID
+	// +kubebuilder:default:=Key
+	// +optional
+	Mode HistoryMode `json:"mode,omitempty"`
+	// number of versions to keep, older versions are pruned
+	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	Limit int `json:"limit,omitempty"`
+}
+
+// DeletionPolicy is what happens to the stored object on deletion
+// +kubebuilder:validation:Enum:=Delete;Retain;OrphanOnMismatch
+type DeletionPolicy string
+
+const (
+	// DeletionDelete removes the stored object and its previous versions,
+	// the object is kept while the stored object was modified or is owned
+	// by another object
+	DeletionDelete DeletionPolicy = "Delete"
+	// DeletionRetain leaves the stored object in place
+	DeletionRetain DeletionPolicy = "Retain"
+	// DeletionOrphanOnMismatch removes the stored object and its previous
+	// versions unless it was modified or is owned by another object, in
+	// which case they are all left in place
+	DeletionOrphanOnMismatch DeletionPolicy = "OrphanOnMismatch"
+)
+
+// ObjectSpec defines the desired state of Object
+type ObjectSpec struct {
+	// what happens to the stored object on deletion, the default deletion
+	// policy of the controller if empty
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	Credentials    Credentials    `json:"credentials"`
+	Source         ObjectSource   `json:"source"`
+	Target         ObjectTarget   `json:"target"`
+	// +optional
+	History *ObjectHistory `json:"history,omitempty"`
+	// conversion of the content before it is stored
+	// +optional
+	Output *ObjectOutput `json:"output,omitempty"`
+	// resolve the source and credentials without modifying the object store
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// halt store and delete operations against the object store
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// An ObjectVersion refers to a version of the object kept in the object store
+type ObjectVersion struct {
+	// object key the version is stored under
+	Key string `json:"key"`
+	// version id assigned by the object store
+	VersionID string `json:"versionId,omitempty"`
+	// sha256 checksum of the version content
+	Checksum string `json:"checksum"`
+	// time the version was stored
+	Timestamp metav1.Time `json:"timestamp"`
+}
+
+// A DryRunStatus reports the operation the controller would have performed
+type DryRunStatus struct {
+	// operation that would have been performed: store / delete
+	Action string `json:"action"`
+	// object store reference the operation applies to
+	Reference string `json:"reference"`
+	// size in bytes of the content that would have been stored
+	Size int `json:"size,omitempty"`
+	// sha256 checksum of the content that would have been stored
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// UploadPhase is the progress of an upload job
+type UploadPhase string
+
+const (
+	// UploadRunning jobs have not finished yet
+	UploadRunning UploadPhase = "Running"
+	// UploadSucceeded jobs stored the content
+	UploadSucceeded UploadPhase = "Succeeded"
+	// UploadFailed jobs exhausted their retries
+	UploadFailed UploadPhase = "Failed"
+)
+
+// An UploadStatus reports the job uploading the content of a PVC source
+type UploadStatus struct {
+	// name of the job
+	JobName string `json:"jobName"`
+	// phase of the job: Running / Succeeded / Failed
+	Phase UploadPhase `json:"phase"`
+	// time the job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// time the job succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// failure reported by the job
+	// +optional
+	Message string `json:"message,omitempty"`
+	// failed jobs of the current upload, a failed job is replaced after a
+	// delay doubling with every failure
+	// +optional
+	Failures int `json:"failures,omitempty"`
+}
+
+// ObjectStatus defines the observed state of Object
+type ObjectStatus struct {
+	// +kubebuilder:default:=false
+	Synced    bool   `json:"synced"`
+	Reference string `json:"reference"`
+	// sha256 checksum of the last synced content
+	Checksum string `json:"checksum,omitempty"`
+	// etag returned by the object store for the last synced content
+	ETag string `json:"etag,omitempty"`
+	// generation of the spec the last synced content was based on
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// versions kept in the object store, newest first
+	Versions []ObjectVersion `json:"versions,omitempty"`
+	// operation planned by the last dry run
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+	// value of the last handled resync-at annotation
+	LastHandledResyncAt string `json:"lastHandledResyncAt,omitempty"`
+	// number of failed attempts to delete the object from the object store
+	DeletionAttempts int `json:"deletionAttempts,omitempty"`
+	// revision of the last synced remote source: the ETag of an HTTP source
+	// or the manifest digest of an OCI artifact
+	SourceRevision string `json:"sourceRevision,omitempty"`
+	// job uploading the content of a PVC source
+	Upload *UploadStatus `json:"upload,omitempty"`
+	// target bucket the object was last stored into, if the object store
+	// returned a version ID, showing that versioning is enabled on it
+	VersionedBucket string `json:"versionedBucket,omitempty"`
+	// conditions of the object
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.synced",description="Whether or not the sync succeeded"
+//+kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend",description="Whether or not syncing is suspended"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:printcolumn:name="Reference",type="string",JSONPath=".status.reference",description="Object reference in the target object store"
+
+// Object is the Schema for the objects API
+// the target bucket is immutable unless versioning was observed on it, so
+// that the stored object and its history are not left behind in the old
+// bucket
+// +kubebuilder:validation:XValidation:rule="self.spec.target.bucket == oldSelf.spec.target.bucket || (has(oldSelf.status) && has(oldSelf.status.versionedBucket) && oldSelf.status.versionedBucket == oldSelf.spec.target.bucket)",message="target.bucket is immutable unless versioning is enabled on the bucket"
+type Object struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:XValidation:rule="self.source.type != 'Resource'",message="Resource sources are only supported by ClusterObject"
+	// +kubebuilder:validation:XValidation:rule="self.source.type != 'PVC' || !has(self.history)",message="history is not supported with PVC sources"
+	// +kubebuilder:validation:XValidation:rule="self.source.type != 'PVC' || !has(self.output) || !has(self.output.format) || self.output.format == 'Raw'",message="output conversion is not supported with PVC sources"
+	Spec   ObjectSpec   `json:"spec,omitempty"`
+	Status ObjectStatus `json:"status,omitempty"`
+}
+
+// GetSpec returns the spec of the object
+func (o *Object) GetSpec() *ObjectSpec {
+	return &o.Spec
+}
+
+// GetStatus returns the status of the object
+func (o *Object) GetStatus() *ObjectStatus {
+	return &o.Status
+}
+
+//+kubebuilder:object:root=true
+
+// ObjectList contains a list of Object
+type ObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Object `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Object{}, &ObjectList{})
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConcurrencyPolicy is how a scheduled run is handled while previous runs
+// have not succeeded yet
+// +kubebuilder:validation:Enum:=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow keeps retrying the previous runs next to the new one
+	ConcurrencyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyForbid skips the new run until the previous runs succeed
+	ConcurrencyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyReplace abandons the previous runs for the new one
+	ConcurrencyReplace ConcurrencyPolicy = "Replace"
+)
+
+// ScheduledObjectSpec defines the desired state of ScheduledObject
+type ScheduledObjectSpec struct {
+	// cron expression of the snapshots, e.g. `0 2 * * *` or `@daily`, in UTC
+	// unless prefixed by CRON_TZ=<time zone>
+	// +kubebuilder:validation:MinLength:=1
+	Schedule string `json:"schedule"`
+	// how a run is handled while previous runs have not succeeded yet:
+	// Allow / Forbid / Replace
+	// +kubebuilder:default:=Forbid
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// number of snapshots to keep, older snapshots are deleted from the
+	// object store
+	// +kubebuilder:default:=7
+	// +kubebuilder:validation:Minimum:=1
+	// +optional
+	SnapshotsLimit int `json:"snapshotsLimit,omitempty"`
+	// what happens to the snapshots on deletion, the default deletion policy
+	// of the controller if empty. Retain keeps the snapshots, while the other
+	// policies delete them.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	Credentials    Credentials    `json:"credentials"`
+	// source of the snapshots, resources are read from the namespace of the
+	// scheduled object
+	// +kubebuilder:validation:XValidation:rule="self.type != 'PVC'",message="PVC sources are only supported by Object"
+	Source ObjectSource `json:"source"`
+	// target of the snapshots, each snapshot is stored under
+	// `<key>.<timestamp>`
+	Target ObjectTarget `json:"target"`
+	// halt the scheduling of new snapshots
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// ScheduledObjectStatus defines the observed state of ScheduledObject
+type ScheduledObjectStatus struct {
+	// time of the last scheduled run
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// time of the last stored snapshot
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// time of the next scheduled run
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+	// scheduled times of the runs not completed yet
+	// +optional
+	Active []metav1.Time `json:"active,omitempty"`
+	// snapshots kept in the object store, newest first
+	// +optional
+	Snapshots []ObjectVersion `json:"snapshots,omitempty"`
+	// conditions of the scheduled object
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule",description="Cron schedule of the snapshots"
+//+kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend",description="Whether or not scheduling is suspended"
+//+kubebuilder:printcolumn:name="Last Schedule",type="date",JSONPath=".status.lastScheduleTime",description="Time of the last scheduled run"
+//+kubebuilder:printcolumn:name="Next Schedule",type="string",JSONPath=".status.nextScheduleTime",description="Time of the next scheduled run"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ScheduledObject is the Schema for the scheduledobjects API, storing a
+// timestamped snapshot of its source on a cron schedule
+type ScheduledObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScheduledObjectSpec   `json:"spec,omitempty"`
+	Status ScheduledObjectStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ScheduledObjectList contains a list of ScheduledObject
+type ScheduledObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScheduledObject `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ScheduledObject{}, &ScheduledObjectList{})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers"
+	"dev.nimak.link/s3-copy-controller/controllers/backup"
+)
+
+type restoreBackupOptions struct {
+	file      string
+	keyFile   string
+	namespace string
+	overwrite bool
+}
+
+func (o *options) restoreBackupCommand() *cobra.Command {
+	var bo restoreBackupOptions
+	cmd := &cobra.Command{
+		Use:   "restore-backup (NAME | --from-file PATH)",
+		Short: "Restore the resources archived by a NamespaceBackup",
+		Long: "Restore the resources archived by a NamespaceBackup, or by a downloaded archive when\n" +
+			"the NamespaceBackup is gone. Existing resources are skipped unless --overwrite is set.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 0) == (bo.file == "") {
+				return errors.New("exactly one of NAME and --from-file is required")
+			}
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			return o.restoreBackup(cmd.Context(), name, bo)
+		},
+	}
+	cmd.Flags().StringVar(&bo.file, "from-file", "", "The archive to restore instead of the archive of a NamespaceBackup.")
+	cmd.Flags().StringVar(&bo.keyFile, "key-file", "",
+		"The file holding the key of encrypted Secrets, the encryption key of the NamespaceBackup if empty.")
+	cmd.Flags().StringVar(&bo.namespace, "to-namespace", "", "The namespace resources are restored into, the namespace they were backed up from if empty.")
+	cmd.Flags().BoolVar(&bo.overwrite, "overwrite", false, "Replace the resources that already exist.")
+	return cmd
+}
+
+func (o *options) restoreBackup(ctx context.Context, name string, bo restoreBackupOptions) error {
+	var (
+		data, key []byte
+		source    = bo.file
+		err       error
+	)
+	if bo.keyFile != "" {
+		if key, err = ioutil.ReadFile(bo.keyFile); err != nil {
+			return err
+		}
+	}
+
+	if bo.file != "" {
+		if data, err = ioutil.ReadFile(bo.file); err != nil {
+			return err
+		}
+	} else {
+		var nb cloudobject.NamespaceBackup
+		if err := o.client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: name}, &nb); err != nil {
+			return err
+		}
+		objectStore, target, err := o.objectStoreFor(ctx, nb.Name, nb.Spec.Credentials, nb.Spec.Target)
+		if err != nil {
+			return err
+		}
+		if data, _, err = objectStore.Get(ctx, target, ""); err != nil {
+			return err
+		}
+		source = storeReference(target)
+
+		if key == nil && nb.Spec.Encryption != nil {
+			if key, err = controllers.SecretKey(ctx, o.client, nb.Spec.Encryption.KeyReference); err != nil {
+				return errors.Wrap(err, "cannot read encryption key")
+			}
+		}
+	}
+
+	index, resources, err := backup.Read(data, key)
+	if err != nil {
+		return err
+	}
+	namespace := bo.namespace
+	if namespace == "" {
+		namespace = index.Namespace
+	}
+
+	restored := 0
+	for i := range resources {
+		resource := &resources[i]
+		resource.SetNamespace(namespace)
+		ok, err := o.restoreResource(ctx, resource, bo.overwrite)
+		if err != nil {
+			return errors.Wrapf(err, "cannot restore %s %s", resource.GetKind(), resource.GetName())
+		}
+		if !ok {
+			fmt.Fprintf(o.out, "skipped existing %s %s\n", resource.GetKind(), resource.GetName())
+			continue
+		}
+		restored++
+	}
+	fmt.Fprintf(o.out, "restored %d of %d resources from %s into namespace %s\n", restored, len(resources), source, namespace)
+	return nil
+}
+
+// restoreResource creates resource, or replaces it if it exists and
+// overwrite is set, and reports whether it was restored
+func (o *options) restoreResource(ctx context.Context, resource *unstructured.Unstructured, overwrite bool) (bool, error) {
+	err := o.client.Create(ctx, resource)
+	if !apierrors.IsAlreadyExists(err) {
+		return err == nil, err
+	}
+	if !overwrite {
+		return false, nil
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(resource.GroupVersionKind())
+	if err := o.client.Get(ctx, types.NamespacedName{Namespace: resource.GetNamespace(), Name: resource.GetName()}, existing); err != nil {
+		return false, err
+	}
+	resource.SetResourceVersion(existing.GetResourceVersion())
+	return true, o.client.Update(ctx, resource)
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers"
+)
+
+type createOptions struct {
+	configMap       string
+	bucket          string
+	keyPrefix       string
+	region          string
+	secret          string
+	secretNamespace string
+	secretKey       string
+	deletionPolicy  string
+	output          string
+}
+
+func (o *options) createCommand() *cobra.Command {
+	var co createOptions
+	cmd := &cobra.Command{
+		Use:   "create --from-configmap NAME --bucket BUCKET --secret NAME",
+		Short: "Create an Object for every key of a ConfigMap",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.create(cmd.Context(), co)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&co.configMap, "from-configmap", "", "The ConfigMap whose keys are stored.")
+	flags.StringVar(&co.bucket, "bucket", "", "The bucket the keys are stored into.")
+	flags.StringVar(&co.keyPrefix, "key-prefix", "", "The prefix of the object keys, e.g. configs/.")
+	flags.StringVar(&co.region, "target-region", "", "The region of the bucket, the default region of the controller if empty.")
+	flags.StringVar(&co.secret, "secret", "", "The Secret holding the AWS credentials.")
+	flags.StringVar(&co.secretNamespace, "secret-namespace", "", "The namespace of the Secret, the namespace of the Objects if empty.")
+	flags.StringVar(&co.secretKey, "secret-key", "aws.creds", "The key of the AWS credentials in the Secret.")
+	flags.StringVar(&co.deletionPolicy, "deletion-policy", "",
+		"Delete, Retain or OrphanOnMismatch, the default deletion policy of the controller if empty.")
+	flags.StringVarP(&co.output, "output", "o", "", "Print the Objects as yaml instead of creating them.")
+	for _, name := range []string{"from-configmap", "bucket", "secret"} {
+		_ = cmd.MarkFlagRequired(name)
+	}
+	return cmd
+}
+
+func (o *options) create(ctx context.Context, co createOptions) error {
+	if co.output != "" && co.output != "yaml" {
+		return errors.Errorf("unsupported output format %s", co.output)
+	}
+	if co.deletionPolicy != "" {
+		policy, err := controllers.ParseDeletionPolicy(co.deletionPolicy)
+		if err != nil {
+			return err
+		}
+		co.deletionPolicy = string(policy)
+	}
+
+	var cm corev1.ConfigMap
+	if err := o.client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: co.configMap}, &cm); err != nil {
+		return err
+	}
+
+	objects := objectsFromConfigMap(&cm, co)
+	if len(objects) == 0 {
+		return errors.Errorf("configmap %s has no data keys", cm.Name)
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		if co.output == "yaml" {
+			out, err := yaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(o.out, "---\n%s", out)
+			continue
+		}
+		if err := o.client.Create(ctx, obj); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.out, "object %s/%s created\n", obj.Namespace, obj.Name)
+	}
+	return nil
+}
+
+// invalidNameChars matches the characters not allowed in object names
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// objectsFromConfigMap returns an Object storing every data key of the
+// ConfigMap under the key prefix
+func objectsFromConfigMap(cm *corev1.ConfigMap, co createOptions) []cloudobject.Object {
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	secretNamespace := co.secretNamespace
+	if secretNamespace == "" {
+		secretNamespace = cm.Namespace
+	}
+
+	var objects []cloudobject.Object
+	for _, key := range keys {
+		name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(cm.Name+"-"+key), "-"), "-")
+		objects = append(objects, cloudobject.Object{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: cloudobject.GroupVersion.String(),
+				Kind:       "Object",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: cm.Namespace,
+			},
+			Spec: cloudobject.ObjectSpec{
+				DeletionPolicy: cloudobject.DeletionPolicy(co.deletionPolicy),
+				Credentials: cloudobject.Credentials{
+					Source: cloudobject.CredentialsSecret,
+					SecretReference: cloudobject.SecretKeySelector{
+						SecretReference: cloudobject.SecretReference{Name: co.secret, Namespace: secretNamespace},
+						Key:             co.secretKey,
+					},
+				},
+				Source: cloudobject.ObjectSource{
+					Type: cloudobject.SourceConfigMap,
+					ConfigMap: &cloudobject.ConfigMapSource{
+						Namespace: cm.Namespace,
+						Name:      cm.Name,
+						Key:       key,
+					},
+				},
+				Target: cloudobject.ObjectTarget{
+					Bucket: co.bucket,
+					Region: co.region,
+					Key:    co.keyPrefix + key,
+				},
+			},
+		})
+	}
+	return objects
+}
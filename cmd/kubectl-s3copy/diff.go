@@ -0,0 +1,90 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"dev.nimak.link/s3-copy-controller/controllers"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+func (o *options) diffCommand() *cobra.Command {
+	var clusterName string
+	cmd := &cobra.Command{
+		Use:   "diff NAME",
+		Short: "Show the difference between the source of an Object and the stored object",
+		Long: "Show the difference between the source of an Object and the stored object.\n" +
+			"Exits with status 1 if they differ.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.diff(cmd.Context(), args[0], clusterName)
+		},
+	}
+	cmd.Flags().StringVar(&clusterName, "cluster-name", "",
+		"The name given to the controller with --cluster-name, rendered by templates as {{ .Cluster.Name }}.")
+	return cmd
+}
+
+func (o *options) diff(ctx context.Context, name, clusterName string) error {
+	obj, err := o.getObject(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// templates are rendered with the cluster info of the controller
+	serverVersion, err := o.discovery.ServerVersion()
+	if err != nil {
+		return errors.Wrap(err, "cannot read the server version")
+	}
+	cluster := controllers.ClusterInfo{Name: clusterName, Version: serverVersion.GitVersion}
+
+	source, err := controllers.ExtractData(ctx, o.client, obj, cluster)
+	if err != nil {
+		return errors.Wrap(err, "cannot read source")
+	}
+
+	objectStore, target, err := o.objectStore(ctx, obj)
+	if err != nil {
+		return err
+	}
+	stored, _, err := objectStore.Get(ctx, target, "")
+	if err != nil && !errors.Is(err, ctrlapi.ErrNotFound) {
+		return err
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(stored)),
+		B:        difflib.SplitLines(string(source)),
+		FromFile: storeReference(target),
+		ToFile:   sourceReference(obj.Spec.Source),
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		return nil
+	}
+	fmt.Fprint(o.out, diff)
+	return errDiffers
+}
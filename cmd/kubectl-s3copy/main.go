@@ -0,0 +1,174 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-s3copy is a kubectl plugin to inspect and operate the Objects of
+// the S3 copy controller
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	awshelper "dev.nimak.link/s3-copy-controller/controllers/aws"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(cloudobject.AddToScheme(scheme))
+}
+
+// errDiffers is returned by diff when the source and the stored object
+// differ, exiting with status 1 like kubectl diff
+var errDiffers = errors.New("source and stored object differ")
+
+func main() {
+	if err := newRootCommand(os.Stdout).Execute(); err != nil {
+		if !errors.Is(err, errDiffers) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// options are shared by all commands
+type options struct {
+	out       io.Writer
+	client    client.Client
+	discovery discovery.ServerVersionInterface
+	namespace string
+	// region of targets without a region
+	region string
+	// endpoint of S3 compatible stores
+	endpoint string
+	newStore func(ctrlapi.ConfigData) ctrlapi.ObjectStore
+}
+
+func newRootCommand(out io.Writer) *cobra.Command {
+	o := &options{
+		out: out,
+		newStore: func(config ctrlapi.ConfigData) ctrlapi.ObjectStore {
+			return awshelper.NewS3ObjectStore(config, nil)
+		},
+	}
+	return o.rootCommand()
+}
+
+func (o *options) rootCommand() *cobra.Command {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{}
+
+	cmd := &cobra.Command{
+		Use:           "kubectl-s3copy",
+		Short:         "Inspect and operate the Objects of the S3 copy controller",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			if o.client != nil {
+				return nil
+			}
+			return o.connect(clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides))
+		},
+	}
+
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&loadingRules.ExplicitPath, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	clientcmd.BindOverrideFlags(overrides, flags, clientcmd.RecommendedConfigOverrideFlags(""))
+	flags.StringVar(&o.region, "region", "", "The region of targets without a region.")
+	flags.StringVar(&o.endpoint, "s3-endpoint", "", "The URL of the S3 endpoint, the AWS endpoint if empty.")
+
+	cmd.AddCommand(
+		o.statusCommand(),
+		o.diffCommand(),
+		o.resyncCommand(),
+		o.suspendCommand(true),
+		o.suspendCommand(false),
+		o.restoreCommand(),
+		o.restoreBackupCommand(),
+		o.createCommand(),
+	)
+	return cmd
+}
+
+func (o *options) connect(config clientcmd.ClientConfig) error {
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return err
+	}
+	if o.namespace, _, err = config.Namespace(); err != nil {
+		return err
+	}
+	if o.discovery, err = discovery.NewDiscoveryClientForConfig(restConfig); err != nil {
+		return err
+	}
+	o.client, err = client.New(restConfig, client.Options{Scheme: scheme})
+	return err
+}
+
+func (o *options) getObject(ctx context.Context, name string) (*cloudobject.Object, error) {
+	var obj cloudobject.Object
+	if err := o.client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: name}, &obj); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// objectStore returns the object store of obj with its credentials, and its
+// target with the region resolved
+func (o *options) objectStore(ctx context.Context, obj *cloudobject.Object) (ctrlapi.ObjectStore, cloudobject.ObjectTarget, error) {
+	return o.objectStoreFor(ctx, obj.Name, obj.Spec.Credentials, obj.Spec.Target)
+}
+
+// objectStoreFor returns the object store of the target of the named
+// resource with its credentials, and the target with the region resolved
+func (o *options) objectStoreFor(ctx context.Context, name string, creds cloudobject.Credentials, target cloudobject.ObjectTarget) (ctrlapi.ObjectStore, cloudobject.ObjectTarget, error) {
+	if target.Region == "" {
+		target.Region = o.region
+	}
+	if target.Region == "" {
+		return nil, target, errors.Errorf("no region set on target of %s, use --region", name)
+	}
+
+	secret, err := controllers.PullCredentials(ctx, o.client, creds)
+	if err != nil {
+		return nil, target, errors.Wrap(err, "cannot read credentials")
+	}
+	return o.newStore(ctrlapi.ConfigData{
+		Secret:    secret,
+		Region:    target.Region,
+		Endpoints: ctrlapi.Endpoints{S3: o.endpoint},
+	}), target, nil
+}
+
+func storeReference(target cloudobject.ObjectTarget) string {
+	return fmt.Sprintf("s3://%s/%s", target.Bucket, target.Key)
+}
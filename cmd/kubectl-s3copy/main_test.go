@@ -0,0 +1,285 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"dev.nimak.link/s3-copy-controller/controllers/api/apifakes"
+	"dev.nimak.link/s3-copy-controller/controllers/backup"
+)
+
+var _ = Describe("kubectl-s3copy", func() {
+	var (
+		ctx        context.Context
+		out        *bytes.Buffer
+		store      *apifakes.FakeObjectStore
+		o          *options
+		obj        *cloudobj.Object
+		storeCalls []ctrlapi.ConfigData
+	)
+
+	run := func(args ...string) error {
+		cmd := o.rootCommand()
+		cmd.SetArgs(args)
+		return cmd.ExecuteContext(ctx)
+	}
+
+	get := func(name string, into client.Object) {
+		Expect(o.client.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, into)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		out = &bytes.Buffer{}
+		store = &apifakes.FakeObjectStore{}
+		storeCalls = nil
+
+		obj = &cloudobj.Object{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+			Spec: cloudobj.ObjectSpec{
+				Credentials: cloudobj.Credentials{
+					SecretReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Name: "creds", Namespace: "default"},
+						Key:             "aws.creds",
+					},
+				},
+				Source: cloudobj.ObjectSource{Inline: &cloudobj.InlineSource{Data: "line 1\nline 2\n"}},
+				Target: cloudobj.ObjectTarget{Bucket: "test-bucket", Key: "dir/file.txt", Region: "us-west-2"},
+			},
+			Status: cloudobj.ObjectStatus{
+				ETag:     "etag-1",
+				Versions: []cloudobj.ObjectVersion{{Key: "dir/file.txt.20211201T000000.000Z"}},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"},
+			Data:       map[string][]byte{"aws.creds": []byte("creds")},
+		}
+
+		o = &options{
+			out: out,
+			discovery: &fakediscovery.FakeDiscovery{
+				Fake:               &clienttesting.Fake{},
+				FakedServerVersion: &version.Info{GitVersion: "v1.22.1"},
+			},
+			namespace: "default",
+			client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(obj, secret).Build(),
+			newStore: func(config ctrlapi.ConfigData) ctrlapi.ObjectStore {
+				storeCalls = append(storeCalls, config)
+				return store
+			},
+		}
+	})
+
+	It("should show the status and the stored object", func() {
+		store.HeadReturns(ctrlapi.ObjectInfo{
+			ETag:     "etag-2",
+			Metadata: map[string]string{controllers.OwnerMetadataKey: "default/sample"},
+		}, nil)
+
+		Expect(run("status", "sample")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("s3://test-bucket/dir/file.txt"))
+		Expect(out.String()).To(ContainSubstring("modified since stored"))
+		Expect(storeCalls).To(ConsistOf(ctrlapi.ConfigData{Secret: []byte("creds"), Region: "us-west-2"}))
+	})
+
+	It("should report a missing stored object", func() {
+		store.HeadReturns(ctrlapi.ObjectInfo{}, ctrlapi.ErrNotFound)
+
+		Expect(run("status", "sample")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("missing"))
+	})
+
+	It("should diff the source against the stored object", func() {
+		store.GetReturns([]byte("line 1\nline 2\n"), ctrlapi.ObjectInfo{}, nil)
+		Expect(run("diff", "sample")).To(Succeed())
+		Expect(out.String()).To(BeEmpty())
+
+		store.GetReturns([]byte("line 1\nchanged\n"), ctrlapi.ObjectInfo{}, nil)
+		Expect(run("diff", "sample")).To(MatchError(errDiffers))
+		Expect(out.String()).To(ContainSubstring("-changed"))
+		Expect(out.String()).To(ContainSubstring("+line 2"))
+	})
+
+	It("should render templates with the cluster info when diffing", func() {
+		obj.Spec.Source.Inline = &cloudobj.InlineSource{
+			Data:     "cluster: {{ .Cluster.Name }}\nversion: {{ .Cluster.Version }}\n",
+			Template: &cloudobj.InlineTemplate{},
+		}
+		Expect(o.client.Update(ctx, obj)).To(Succeed())
+		store.GetReturns([]byte("cluster: prod-eu\nversion: v1.22.1\n"), ctrlapi.ObjectInfo{}, nil)
+
+		Expect(run("diff", "sample", "--cluster-name", "prod-eu")).To(Succeed())
+		Expect(out.String()).To(BeEmpty())
+
+		Expect(run("diff", "sample")).To(MatchError(errDiffers))
+		Expect(out.String()).To(ContainSubstring("+cluster: \n"))
+	})
+
+	It("should request a resync", func() {
+		Expect(run("resync", "sample")).To(Succeed())
+
+		var updated cloudobj.Object
+		get("sample", &updated)
+		Expect(updated.Annotations).To(HaveKey(controllers.ResyncAnnotation))
+	})
+
+	It("should suspend and resume the object", func() {
+		var updated cloudobj.Object
+		Expect(run("suspend", "sample")).To(Succeed())
+		get("sample", &updated)
+		Expect(updated.Spec.Suspend).To(BeTrue())
+
+		var resumed cloudobj.Object
+		Expect(run("resume", "sample")).To(Succeed())
+		get("sample", &resumed)
+		Expect(resumed.Spec.Suspend).To(BeFalse())
+	})
+
+	It("should restore a previous version into a file", func() {
+		store.GetReturns([]byte("previous"), ctrlapi.ObjectInfo{}, nil)
+		dir, err := ioutil.TempDir("", "kubectl-s3copy")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "restored.txt")
+
+		Expect(run("restore", "sample", "--to-file", file, "--version", "dir/file.txt.20211201T000000.000Z")).To(Succeed())
+		content, err := ioutil.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("previous"))
+
+		_, target, versionID := store.GetArgsForCall(0)
+		Expect(target.Key).To(Equal("dir/file.txt.20211201T000000.000Z"))
+		Expect(versionID).To(BeEmpty())
+
+		Expect(run("restore", "sample", "--to-file", file, "--version", "unknown")).NotTo(Succeed())
+	})
+
+	It("should restore the stored object into a configmap", func() {
+		store.GetReturns([]byte("current"), ctrlapi.ObjectInfo{}, nil)
+
+		Expect(run("restore", "sample", "--to-configmap", "restored")).To(Succeed())
+
+		var cm corev1.ConfigMap
+		get("restored", &cm)
+		Expect(cm.Data).To(HaveKeyWithValue("file.txt", "current"))
+	})
+
+	It("should create an object for every key of a configmap", func() {
+		Expect(o.client.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"},
+			Data:       map[string]string{"settings.yaml": "a: b", "Feature_Flags.json": "{}"},
+		})).To(Succeed())
+
+		Expect(run("create", "--from-configmap", "app-config", "--bucket", "test-bucket",
+			"--secret", "creds", "--key-prefix", "configs/")).To(Succeed())
+
+		var created cloudobj.Object
+		get("app-config-settings-yaml", &created)
+		Expect(created.Spec.Source).To(Equal(cloudobj.ObjectSource{
+			Type:      cloudobj.SourceConfigMap,
+			ConfigMap: &cloudobj.ConfigMapSource{Namespace: "default", Name: "app-config", Key: "settings.yaml"},
+		}))
+		Expect(created.Spec.Target.Key).To(Equal("configs/settings.yaml"))
+		Expect(created.Spec.Credentials.SecretReference.Namespace).To(Equal("default"))
+
+		get("app-config-feature-flags-json", &created)
+		Expect(created.Spec.Target.Key).To(Equal("configs/Feature_Flags.json"))
+	})
+
+	It("should restore the resources archived by a namespace backup", func() {
+		key := bytes.Repeat([]byte("k"), backup.KeySize)
+		Expect(o.client.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "backup-key", Namespace: "default"},
+			Data:       map[string][]byte{"key": key},
+		})).To(Succeed())
+		Expect(o.client.Create(ctx, &cloudobj.NamespaceBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default"},
+			Spec: cloudobj.NamespaceBackupSpec{
+				Credentials: obj.Spec.Credentials,
+				Target:      cloudobj.ObjectTarget{Bucket: "test-bucket", Key: "default.tar.gz", Region: "us-west-2"},
+				Encryption: &cloudobj.BackupEncryption{
+					KeyReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Name: "backup-key", Namespace: "default"},
+						Key:             "key",
+					},
+				},
+			},
+		})).To(Succeed())
+		Expect(o.client.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"},
+			Data:       map[string]string{"setting": "current"},
+		})).To(Succeed())
+
+		archive, err := backup.Write("default", time.Now(), []unstructured.Unstructured{
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata":   map[string]interface{}{"name": "db"},
+				"data":       map[string]interface{}{"password": "c2VjcmV0"},
+			}},
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "existing"},
+				"data":       map[string]interface{}{"setting": "backed up"},
+			}},
+		}, key)
+		Expect(err).NotTo(HaveOccurred())
+		store.GetReturns(archive, ctrlapi.ObjectInfo{}, nil)
+
+		Expect(run("restore-backup", "nightly")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("skipped existing ConfigMap existing"))
+		Expect(out.String()).To(ContainSubstring("restored 1 of 2 resources from s3://test-bucket/default.tar.gz into namespace default"))
+		_, target, _ := store.GetArgsForCall(0)
+		Expect(target.Key).To(Equal("default.tar.gz"))
+
+		var secret corev1.Secret
+		get("db", &secret)
+		Expect(secret.Data).To(HaveKeyWithValue("password", []byte("secret")))
+		var cm corev1.ConfigMap
+		get("existing", &cm)
+		Expect(cm.Data).To(HaveKeyWithValue("setting", "current"))
+
+		Expect(run("restore-backup", "nightly", "--overwrite")).To(Succeed())
+		get("existing", &cm)
+		Expect(cm.Data).To(HaveKeyWithValue("setting", "backed up"))
+
+		Expect(run("restore-backup")).NotTo(Succeed())
+	})
+})
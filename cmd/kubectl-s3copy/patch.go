@@ -0,0 +1,78 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers"
+)
+
+func (o *options) resyncCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resync NAME",
+		Short: "Upload the source of an Object again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.patch(cmd.Context(), args[0], "resync requested", func(obj *cloudobject.Object) {
+				if obj.Annotations == nil {
+					obj.Annotations = map[string]string{}
+				}
+				obj.Annotations[controllers.ResyncAnnotation] = time.Now().UTC().Format(time.RFC3339)
+			})
+		},
+	}
+}
+
+func (o *options) suspendCommand(suspend bool) *cobra.Command {
+	use, short, done := "suspend NAME", "Halt the object store operations of an Object", "suspended"
+	if !suspend {
+		use, short, done = "resume NAME", "Resume the object store operations of an Object", "resumed"
+	}
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.patch(cmd.Context(), args[0], done, func(obj *cloudobject.Object) {
+				obj.Spec.Suspend = suspend
+			})
+		},
+	}
+}
+
+// patch applies mutate to the Object as a merge patch
+func (o *options) patch(ctx context.Context, name, done string, mutate func(*cloudobject.Object)) error {
+	obj, err := o.getObject(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	original := obj.DeepCopy()
+	mutate(obj)
+	if err := o.client.Patch(ctx, obj, client.MergeFrom(original)); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.out, "object %s/%s %s\n", obj.Namespace, obj.Name, done)
+	return nil
+}
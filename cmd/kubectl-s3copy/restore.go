@@ -0,0 +1,139 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+type restoreOptions struct {
+	file      string
+	configMap string
+	key       string
+	version   string
+}
+
+func (o *options) restoreCommand() *cobra.Command {
+	var ro restoreOptions
+	cmd := &cobra.Command{
+		Use:   "restore NAME (--to-file PATH | --to-configmap NAME)",
+		Short: "Download the stored object of an Object into a file or a ConfigMap",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (ro.file == "") == (ro.configMap == "") {
+				return errors.New("exactly one of --to-file and --to-configmap is required")
+			}
+			return o.restore(cmd.Context(), args[0], ro)
+		},
+	}
+	cmd.Flags().StringVar(&ro.file, "to-file", "", "The file the object is written to, - for the standard output.")
+	cmd.Flags().StringVar(&ro.configMap, "to-configmap", "", "The ConfigMap the object is written to, created if missing.")
+	cmd.Flags().StringVar(&ro.key, "key", "", "The ConfigMap key the object is written to, the base name of the target key if empty.")
+	cmd.Flags().StringVar(&ro.version, "version", "",
+		"The key or version id of a previous version listed in the status of the Object, the current version if empty.")
+	return cmd
+}
+
+func (o *options) restore(ctx context.Context, name string, ro restoreOptions) error {
+	obj, err := o.getObject(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	objectStore, target, err := o.objectStore(ctx, obj)
+	if err != nil {
+		return err
+	}
+	versionID := ""
+	if ro.version != "" {
+		version, ok := findVersion(obj, ro.version)
+		if !ok {
+			return errors.Errorf("version %s not found in the status of %s", ro.version, obj.Name)
+		}
+		target.Key, versionID = version.Key, version.VersionID
+	}
+
+	content, _, err := objectStore.Get(ctx, target, versionID)
+	if err != nil {
+		return err
+	}
+
+	if ro.file == "-" {
+		_, err = o.out.Write(content)
+		return err
+	}
+	if ro.file != "" {
+		if err := ioutil.WriteFile(ro.file, content, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.out, "restored %s into %s\n", storeReference(target), ro.file)
+		return nil
+	}
+
+	key := ro.key
+	if key == "" {
+		key = path.Base(obj.Spec.Target.Key)
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: obj.Namespace, Name: ro.configMap}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, o.client, cm, func() error {
+		setConfigMapKey(cm, key, content)
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.out, "restored %s into configmap %s/%s[%s]\n", storeReference(target), cm.Namespace, cm.Name, key)
+	return nil
+}
+
+// findVersion looks up a previous version by key or version id
+func findVersion(obj *cloudobject.Object, version string) (cloudobject.ObjectVersion, bool) {
+	for _, v := range obj.Status.Versions {
+		if v.Key == version || (v.VersionID != "" && v.VersionID == version) {
+			return v, true
+		}
+	}
+	return cloudobject.ObjectVersion{}, false
+}
+
+// setConfigMapKey stores text content as data and anything else as binary data
+func setConfigMapKey(cm *corev1.ConfigMap, key string, content []byte) {
+	if utf8.Valid(content) {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = string(content)
+		delete(cm.BinaryData, key)
+		return
+	}
+	if cm.BinaryData == nil {
+		cm.BinaryData = map[string][]byte{}
+	}
+	cm.BinaryData[key] = content
+	delete(cm.Data, key)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+func (o *options) statusCommand() *cobra.Command {
+	var remote bool
+	cmd := &cobra.Command{
+		Use:   "status NAME",
+		Short: "Show the status, conditions and stored object of an Object",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.status(cmd.Context(), args[0], remote)
+		},
+	}
+	cmd.Flags().BoolVar(&remote, "remote", true, "Show the metadata of the stored object.")
+	return cmd
+}
+
+func (o *options) status(ctx context.Context, name string, remote bool) error {
+	obj, err := o.getObject(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s/%s\n", obj.Namespace, obj.Name)
+	fmt.Fprintf(w, "Source:\t%s\n", sourceReference(obj.Spec.Source))
+	fmt.Fprintf(w, "Target:\t%s\n", storeReference(obj.Spec.Target))
+	if obj.Spec.Output != nil {
+		fmt.Fprintf(w, "Output Format:\t%s\n", obj.Spec.Output.Format)
+	}
+	fmt.Fprintf(w, "Region:\t%s\n", obj.Spec.Target.Region)
+	fmt.Fprintf(w, "Deletion Policy:\t%s\n", obj.Spec.DeletionPolicy)
+	fmt.Fprintf(w, "Suspended:\t%t\n", obj.Spec.Suspend)
+	fmt.Fprintf(w, "Synced:\t%t\n", obj.Status.Synced)
+	fmt.Fprintf(w, "Checksum:\t%s\n", obj.Status.Checksum)
+	fmt.Fprintf(w, "ETag:\t%s\n", obj.Status.ETag)
+	if obj.Status.SourceRevision != "" {
+		fmt.Fprintf(w, "Source Revision:\t%s\n", obj.Status.SourceRevision)
+	}
+	if upload := obj.Status.Upload; upload != nil {
+		fmt.Fprintf(w, "Upload Job:\t%s (%s)\n", upload.JobName, upload.Phase)
+	}
+	fmt.Fprintf(w, "Versions:\t%d\n", len(obj.Status.Versions))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(o.out, "Conditions:")
+	w = tabwriter.NewWriter(o.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+	for _, c := range obj.Status.Conditions {
+		age := duration.HumanDuration(time.Since(c.LastTransitionTime.Time))
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", c.Type, c.Status, c.Reason, age, c.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !remote {
+		return nil
+	}
+	fmt.Fprintln(o.out, "Stored Object:")
+	objectStore, target, err := o.objectStore(ctx, obj)
+	if err != nil {
+		return err
+	}
+	info, err := objectStore.Head(ctx, target)
+	if errors.Is(err, ctrlapi.ErrNotFound) {
+		fmt.Fprintln(o.out, "  State:  missing")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	w = tabwriter.NewWriter(o.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  State:\t%s\n", remoteState(obj, info))
+	fmt.Fprintf(w, "  ETag:\t%s\n", info.ETag)
+	if info.VersionID != "" {
+		fmt.Fprintf(w, "  Version ID:\t%s\n", info.VersionID)
+	}
+	keys := make([]string, 0, len(info.Metadata))
+	for key := range info.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  Metadata %s:\t%s\n", key, info.Metadata[key])
+	}
+	return w.Flush()
+}
+
+// remoteState compares the stored object with what the Object stored
+func remoteState(obj *cloudobject.Object, info ctrlapi.ObjectInfo) string {
+	owner := info.Metadata[controllers.OwnerMetadataKey]
+	switch {
+	case owner != fmt.Sprintf("%s/%s", obj.Namespace, obj.Name):
+		if owner == "" {
+			owner = "an unknown writer"
+		}
+		return fmt.Sprintf("owned by %s", owner)
+	case obj.Status.ETag != "" && info.ETag != obj.Status.ETag:
+		return "modified since stored"
+	default:
+		return "in sync"
+	}
+}
+
+func sourceReference(src cloudobject.ObjectSource) string {
+	switch {
+	case src.Type == cloudobject.SourceConfigMap && src.ConfigMap != nil:
+		return fmt.Sprintf("configmap %s/%s[%s]", src.ConfigMap.Namespace, src.ConfigMap.Name, src.ConfigMap.Key)
+	case src.Type == cloudobject.SourceHTTP && src.HTTP != nil:
+		return fmt.Sprintf("http %s", src.HTTP.URL)
+	case src.Type == cloudobject.SourceOCI && src.OCI != nil:
+		return fmt.Sprintf("oci %s", src.OCI.Reference)
+	case src.Type == cloudobject.SourcePVC && src.PVC != nil:
+		return fmt.Sprintf("pvc %s[%s]", src.PVC.ClaimName, src.PVC.Path)
+	case src.Type == cloudobject.SourceS3 && src.S3 != nil:
+		return fmt.Sprintf("s3 s3://%s/%s", src.S3.Bucket, src.S3.Key)
+	case src.Inline != nil && src.Inline.Template != nil:
+		return fmt.Sprintf("inline template (%d bytes, %d inputs)", len(src.Inline.Data), len(src.Inline.Template.Inputs))
+	case src.Inline != nil:
+		return fmt.Sprintf("inline (%d bytes)", len(src.Inline.Data))
+	default:
+		return fmt.Sprintf("%s source", src.Type)
+	}
+}
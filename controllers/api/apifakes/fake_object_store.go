@@ -5,16 +5,44 @@ import (
 	"context"
 	"sync"
 
-	"dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	"dev.nimak.link/s3-copy-controller/api/v1beta1"
 	"dev.nimak.link/s3-copy-controller/controllers/api"
 )
 
 type FakeObjectStore struct {
-	DeleteStub        func(context.Context, v1alpha1.ObjectTarget) error
+	CopyStub        func(context.Context, v1beta1.ObjectTarget, v1beta1.ObjectTarget, map[string]string) (api.ObjectInfo, error)
+	copyMutex       sync.RWMutex
+	copyArgsForCall []struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+		arg3 v1beta1.ObjectTarget
+		arg4 map[string]string
+	}
+	copyReturns struct {
+		result1 api.ObjectInfo
+		result2 error
+	}
+	copyReturnsOnCall map[int]struct {
+		result1 api.ObjectInfo
+		result2 error
+	}
+	CreateBucketStub        func(context.Context, v1beta1.ObjectTarget) error
+	createBucketMutex       sync.RWMutex
+	createBucketArgsForCall []struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+	}
+	createBucketReturns struct {
+		result1 error
+	}
+	createBucketReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteStub        func(context.Context, v1beta1.ObjectTarget) error
 	deleteMutex       sync.RWMutex
 	deleteArgsForCall []struct {
 		arg1 context.Context
-		arg2 v1alpha1.ObjectTarget
+		arg2 v1beta1.ObjectTarget
 	}
 	deleteReturns struct {
 		result1 error
@@ -22,29 +50,205 @@ type FakeObjectStore struct {
 	deleteReturnsOnCall map[int]struct {
 		result1 error
 	}
-	StoreStub        func(context.Context, []byte, v1alpha1.ObjectTarget) error
+	DeleteVersionStub        func(context.Context, v1beta1.ObjectTarget, string) error
+	deleteVersionMutex       sync.RWMutex
+	deleteVersionArgsForCall []struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+		arg3 string
+	}
+	deleteVersionReturns struct {
+		result1 error
+	}
+	deleteVersionReturnsOnCall map[int]struct {
+		result1 error
+	}
+	GetStub        func(context.Context, v1beta1.ObjectTarget, string) ([]byte, api.ObjectInfo, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+		arg3 string
+	}
+	getReturns struct {
+		result1 []byte
+		result2 api.ObjectInfo
+		result3 error
+	}
+	getReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 api.ObjectInfo
+		result3 error
+	}
+	HeadStub        func(context.Context, v1beta1.ObjectTarget) (api.ObjectInfo, error)
+	headMutex       sync.RWMutex
+	headArgsForCall []struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+	}
+	headReturns struct {
+		result1 api.ObjectInfo
+		result2 error
+	}
+	headReturnsOnCall map[int]struct {
+		result1 api.ObjectInfo
+		result2 error
+	}
+	StoreStub        func(context.Context, []byte, v1beta1.ObjectTarget, map[string]string) (api.ObjectInfo, error)
 	storeMutex       sync.RWMutex
 	storeArgsForCall []struct {
 		arg1 context.Context
 		arg2 []byte
-		arg3 v1alpha1.ObjectTarget
+		arg3 v1beta1.ObjectTarget
+		arg4 map[string]string
 	}
 	storeReturns struct {
-		result1 error
+		result1 api.ObjectInfo
+		result2 error
 	}
 	storeReturnsOnCall map[int]struct {
-		result1 error
+		result1 api.ObjectInfo
+		result2 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeObjectStore) Delete(arg1 context.Context, arg2 v1alpha1.ObjectTarget) error {
+func (fake *FakeObjectStore) Copy(arg1 context.Context, arg2 v1beta1.ObjectTarget, arg3 v1beta1.ObjectTarget, arg4 map[string]string) (api.ObjectInfo, error) {
+	fake.copyMutex.Lock()
+	ret, specificReturn := fake.copyReturnsOnCall[len(fake.copyArgsForCall)]
+	fake.copyArgsForCall = append(fake.copyArgsForCall, struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+		arg3 v1beta1.ObjectTarget
+		arg4 map[string]string
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.CopyStub
+	fakeReturns := fake.copyReturns
+	fake.recordInvocation("Copy", []interface{}{arg1, arg2, arg3, arg4})
+	fake.copyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeObjectStore) CopyCallCount() int {
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
+	return len(fake.copyArgsForCall)
+}
+
+func (fake *FakeObjectStore) CopyCalls(stub func(context.Context, v1beta1.ObjectTarget, v1beta1.ObjectTarget, map[string]string) (api.ObjectInfo, error)) {
+	fake.copyMutex.Lock()
+	defer fake.copyMutex.Unlock()
+	fake.CopyStub = stub
+}
+
+func (fake *FakeObjectStore) CopyArgsForCall(i int) (context.Context, v1beta1.ObjectTarget, v1beta1.ObjectTarget, map[string]string) {
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
+	argsForCall := fake.copyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeObjectStore) CopyReturns(result1 api.ObjectInfo, result2 error) {
+	fake.copyMutex.Lock()
+	defer fake.copyMutex.Unlock()
+	fake.CopyStub = nil
+	fake.copyReturns = struct {
+		result1 api.ObjectInfo
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) CopyReturnsOnCall(i int, result1 api.ObjectInfo, result2 error) {
+	fake.copyMutex.Lock()
+	defer fake.copyMutex.Unlock()
+	fake.CopyStub = nil
+	if fake.copyReturnsOnCall == nil {
+		fake.copyReturnsOnCall = make(map[int]struct {
+			result1 api.ObjectInfo
+			result2 error
+		})
+	}
+	fake.copyReturnsOnCall[i] = struct {
+		result1 api.ObjectInfo
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) CreateBucket(arg1 context.Context, arg2 v1beta1.ObjectTarget) error {
+	fake.createBucketMutex.Lock()
+	ret, specificReturn := fake.createBucketReturnsOnCall[len(fake.createBucketArgsForCall)]
+	fake.createBucketArgsForCall = append(fake.createBucketArgsForCall, struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+	}{arg1, arg2})
+	stub := fake.CreateBucketStub
+	fakeReturns := fake.createBucketReturns
+	fake.recordInvocation("CreateBucket", []interface{}{arg1, arg2})
+	fake.createBucketMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeObjectStore) CreateBucketCallCount() int {
+	fake.createBucketMutex.RLock()
+	defer fake.createBucketMutex.RUnlock()
+	return len(fake.createBucketArgsForCall)
+}
+
+func (fake *FakeObjectStore) CreateBucketCalls(stub func(context.Context, v1beta1.ObjectTarget) error) {
+	fake.createBucketMutex.Lock()
+	defer fake.createBucketMutex.Unlock()
+	fake.CreateBucketStub = stub
+}
+
+func (fake *FakeObjectStore) CreateBucketArgsForCall(i int) (context.Context, v1beta1.ObjectTarget) {
+	fake.createBucketMutex.RLock()
+	defer fake.createBucketMutex.RUnlock()
+	argsForCall := fake.createBucketArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeObjectStore) CreateBucketReturns(result1 error) {
+	fake.createBucketMutex.Lock()
+	defer fake.createBucketMutex.Unlock()
+	fake.CreateBucketStub = nil
+	fake.createBucketReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) CreateBucketReturnsOnCall(i int, result1 error) {
+	fake.createBucketMutex.Lock()
+	defer fake.createBucketMutex.Unlock()
+	fake.CreateBucketStub = nil
+	if fake.createBucketReturnsOnCall == nil {
+		fake.createBucketReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.createBucketReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) Delete(arg1 context.Context, arg2 v1beta1.ObjectTarget) error {
 	fake.deleteMutex.Lock()
 	ret, specificReturn := fake.deleteReturnsOnCall[len(fake.deleteArgsForCall)]
 	fake.deleteArgsForCall = append(fake.deleteArgsForCall, struct {
 		arg1 context.Context
-		arg2 v1alpha1.ObjectTarget
+		arg2 v1beta1.ObjectTarget
 	}{arg1, arg2})
 	stub := fake.DeleteStub
 	fakeReturns := fake.deleteReturns
@@ -65,13 +269,13 @@ func (fake *FakeObjectStore) DeleteCallCount() int {
 	return len(fake.deleteArgsForCall)
 }
 
-func (fake *FakeObjectStore) DeleteCalls(stub func(context.Context, v1alpha1.ObjectTarget) error) {
+func (fake *FakeObjectStore) DeleteCalls(stub func(context.Context, v1beta1.ObjectTarget) error) {
 	fake.deleteMutex.Lock()
 	defer fake.deleteMutex.Unlock()
 	fake.DeleteStub = stub
 }
 
-func (fake *FakeObjectStore) DeleteArgsForCall(i int) (context.Context, v1alpha1.ObjectTarget) {
+func (fake *FakeObjectStore) DeleteArgsForCall(i int) (context.Context, v1beta1.ObjectTarget) {
 	fake.deleteMutex.RLock()
 	defer fake.deleteMutex.RUnlock()
 	argsForCall := fake.deleteArgsForCall[i]
@@ -101,7 +305,204 @@ func (fake *FakeObjectStore) DeleteReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeObjectStore) Store(arg1 context.Context, arg2 []byte, arg3 v1alpha1.ObjectTarget) error {
+func (fake *FakeObjectStore) DeleteVersion(arg1 context.Context, arg2 v1beta1.ObjectTarget, arg3 string) error {
+	fake.deleteVersionMutex.Lock()
+	ret, specificReturn := fake.deleteVersionReturnsOnCall[len(fake.deleteVersionArgsForCall)]
+	fake.deleteVersionArgsForCall = append(fake.deleteVersionArgsForCall, struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.DeleteVersionStub
+	fakeReturns := fake.deleteVersionReturns
+	fake.recordInvocation("DeleteVersion", []interface{}{arg1, arg2, arg3})
+	fake.deleteVersionMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeObjectStore) DeleteVersionCallCount() int {
+	fake.deleteVersionMutex.RLock()
+	defer fake.deleteVersionMutex.RUnlock()
+	return len(fake.deleteVersionArgsForCall)
+}
+
+func (fake *FakeObjectStore) DeleteVersionCalls(stub func(context.Context, v1beta1.ObjectTarget, string) error) {
+	fake.deleteVersionMutex.Lock()
+	defer fake.deleteVersionMutex.Unlock()
+	fake.DeleteVersionStub = stub
+}
+
+func (fake *FakeObjectStore) DeleteVersionArgsForCall(i int) (context.Context, v1beta1.ObjectTarget, string) {
+	fake.deleteVersionMutex.RLock()
+	defer fake.deleteVersionMutex.RUnlock()
+	argsForCall := fake.deleteVersionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) DeleteVersionReturns(result1 error) {
+	fake.deleteVersionMutex.Lock()
+	defer fake.deleteVersionMutex.Unlock()
+	fake.DeleteVersionStub = nil
+	fake.deleteVersionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) DeleteVersionReturnsOnCall(i int, result1 error) {
+	fake.deleteVersionMutex.Lock()
+	defer fake.deleteVersionMutex.Unlock()
+	fake.DeleteVersionStub = nil
+	if fake.deleteVersionReturnsOnCall == nil {
+		fake.deleteVersionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteVersionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) Get(arg1 context.Context, arg2 v1beta1.ObjectTarget, arg3 string) ([]byte, api.ObjectInfo, error) {
+	fake.getMutex.Lock()
+	ret, specificReturn := fake.getReturnsOnCall[len(fake.getArgsForCall)]
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.GetStub
+	fakeReturns := fake.getReturns
+	fake.recordInvocation("Get", []interface{}{arg1, arg2, arg3})
+	fake.getMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeObjectStore) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeObjectStore) GetCalls(stub func(context.Context, v1beta1.ObjectTarget, string) ([]byte, api.ObjectInfo, error)) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = stub
+}
+
+func (fake *FakeObjectStore) GetArgsForCall(i int) (context.Context, v1beta1.ObjectTarget, string) {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	argsForCall := fake.getArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) GetReturns(result1 []byte, result2 api.ObjectInfo, result3 error) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 []byte
+		result2 api.ObjectInfo
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeObjectStore) GetReturnsOnCall(i int, result1 []byte, result2 api.ObjectInfo, result3 error) {
+	fake.getMutex.Lock()
+	defer fake.getMutex.Unlock()
+	fake.GetStub = nil
+	if fake.getReturnsOnCall == nil {
+		fake.getReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 api.ObjectInfo
+			result3 error
+		})
+	}
+	fake.getReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 api.ObjectInfo
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeObjectStore) Head(arg1 context.Context, arg2 v1beta1.ObjectTarget) (api.ObjectInfo, error) {
+	fake.headMutex.Lock()
+	ret, specificReturn := fake.headReturnsOnCall[len(fake.headArgsForCall)]
+	fake.headArgsForCall = append(fake.headArgsForCall, struct {
+		arg1 context.Context
+		arg2 v1beta1.ObjectTarget
+	}{arg1, arg2})
+	stub := fake.HeadStub
+	fakeReturns := fake.headReturns
+	fake.recordInvocation("Head", []interface{}{arg1, arg2})
+	fake.headMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeObjectStore) HeadCallCount() int {
+	fake.headMutex.RLock()
+	defer fake.headMutex.RUnlock()
+	return len(fake.headArgsForCall)
+}
+
+func (fake *FakeObjectStore) HeadCalls(stub func(context.Context, v1beta1.ObjectTarget) (api.ObjectInfo, error)) {
+	fake.headMutex.Lock()
+	defer fake.headMutex.Unlock()
+	fake.HeadStub = stub
+}
+
+func (fake *FakeObjectStore) HeadArgsForCall(i int) (context.Context, v1beta1.ObjectTarget) {
+	fake.headMutex.RLock()
+	defer fake.headMutex.RUnlock()
+	argsForCall := fake.headArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeObjectStore) HeadReturns(result1 api.ObjectInfo, result2 error) {
+	fake.headMutex.Lock()
+	defer fake.headMutex.Unlock()
+	fake.HeadStub = nil
+	fake.headReturns = struct {
+		result1 api.ObjectInfo
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) HeadReturnsOnCall(i int, result1 api.ObjectInfo, result2 error) {
+	fake.headMutex.Lock()
+	defer fake.headMutex.Unlock()
+	fake.HeadStub = nil
+	if fake.headReturnsOnCall == nil {
+		fake.headReturnsOnCall = make(map[int]struct {
+			result1 api.ObjectInfo
+			result2 error
+		})
+	}
+	fake.headReturnsOnCall[i] = struct {
+		result1 api.ObjectInfo
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) Store(arg1 context.Context, arg2 []byte, arg3 v1beta1.ObjectTarget, arg4 map[string]string) (api.ObjectInfo, error) {
 	var arg2Copy []byte
 	if arg2 != nil {
 		arg2Copy = make([]byte, len(arg2))
@@ -112,19 +513,20 @@ func (fake *FakeObjectStore) Store(arg1 context.Context, arg2 []byte, arg3 v1alp
 	fake.storeArgsForCall = append(fake.storeArgsForCall, struct {
 		arg1 context.Context
 		arg2 []byte
-		arg3 v1alpha1.ObjectTarget
-	}{arg1, arg2Copy, arg3})
+		arg3 v1beta1.ObjectTarget
+		arg4 map[string]string
+	}{arg1, arg2Copy, arg3, arg4})
 	stub := fake.StoreStub
 	fakeReturns := fake.storeReturns
-	fake.recordInvocation("Store", []interface{}{arg1, arg2Copy, arg3})
+	fake.recordInvocation("Store", []interface{}{arg1, arg2Copy, arg3, arg4})
 	fake.storeMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3)
+		return stub(arg1, arg2, arg3, arg4)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
-	return fakeReturns.result1
+	return fakeReturns.result1, fakeReturns.result2
 }
 
 func (fake *FakeObjectStore) StoreCallCount() int {
@@ -133,47 +535,60 @@ func (fake *FakeObjectStore) StoreCallCount() int {
 	return len(fake.storeArgsForCall)
 }
 
-func (fake *FakeObjectStore) StoreCalls(stub func(context.Context, []byte, v1alpha1.ObjectTarget) error) {
+func (fake *FakeObjectStore) StoreCalls(stub func(context.Context, []byte, v1beta1.ObjectTarget, map[string]string) (api.ObjectInfo, error)) {
 	fake.storeMutex.Lock()
 	defer fake.storeMutex.Unlock()
 	fake.StoreStub = stub
 }
 
-func (fake *FakeObjectStore) StoreArgsForCall(i int) (context.Context, []byte, v1alpha1.ObjectTarget) {
+func (fake *FakeObjectStore) StoreArgsForCall(i int) (context.Context, []byte, v1beta1.ObjectTarget, map[string]string) {
 	fake.storeMutex.RLock()
 	defer fake.storeMutex.RUnlock()
 	argsForCall := fake.storeArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
-func (fake *FakeObjectStore) StoreReturns(result1 error) {
+func (fake *FakeObjectStore) StoreReturns(result1 api.ObjectInfo, result2 error) {
 	fake.storeMutex.Lock()
 	defer fake.storeMutex.Unlock()
 	fake.StoreStub = nil
 	fake.storeReturns = struct {
-		result1 error
-	}{result1}
+		result1 api.ObjectInfo
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *FakeObjectStore) StoreReturnsOnCall(i int, result1 error) {
+func (fake *FakeObjectStore) StoreReturnsOnCall(i int, result1 api.ObjectInfo, result2 error) {
 	fake.storeMutex.Lock()
 	defer fake.storeMutex.Unlock()
 	fake.StoreStub = nil
 	if fake.storeReturnsOnCall == nil {
 		fake.storeReturnsOnCall = make(map[int]struct {
-			result1 error
+			result1 api.ObjectInfo
+			result2 error
 		})
 	}
 	fake.storeReturnsOnCall[i] = struct {
-		result1 error
-	}{result1}
+		result1 api.ObjectInfo
+		result2 error
+	}{result1, result2}
 }
 
 func (fake *FakeObjectStore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
+	fake.createBucketMutex.RLock()
+	defer fake.createBucketMutex.RUnlock()
 	fake.deleteMutex.RLock()
 	defer fake.deleteMutex.RUnlock()
+	fake.deleteVersionMutex.RLock()
+	defer fake.deleteVersionMutex.RUnlock()
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	fake.headMutex.RLock()
+	defer fake.headMutex.RUnlock()
 	fake.storeMutex.RLock()
 	defer fake.storeMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
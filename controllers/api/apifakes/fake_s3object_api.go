@@ -0,0 +1,454 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package apifakes
+
+import (
+	"context"
+	"sync"
+
+	"dev.nimak.link/s3-copy-controller/controllers/api"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type FakeS3ObjectAPI struct {
+	CopyObjectStub        func(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	copyObjectMutex       sync.RWMutex
+	copyObjectArgsForCall []struct {
+		arg1 context.Context
+		arg2 *s3.CopyObjectInput
+		arg3 []func(*s3.Options)
+	}
+	copyObjectReturns struct {
+		result1 *s3.CopyObjectOutput
+		result2 error
+	}
+	copyObjectReturnsOnCall map[int]struct {
+		result1 *s3.CopyObjectOutput
+		result2 error
+	}
+	DeleteObjectStub        func(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	deleteObjectMutex       sync.RWMutex
+	deleteObjectArgsForCall []struct {
+		arg1 context.Context
+		arg2 *s3.DeleteObjectInput
+		arg3 []func(*s3.Options)
+	}
+	deleteObjectReturns struct {
+		result1 *s3.DeleteObjectOutput
+		result2 error
+	}
+	deleteObjectReturnsOnCall map[int]struct {
+		result1 *s3.DeleteObjectOutput
+		result2 error
+	}
+	GetObjectStub        func(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	getObjectMutex       sync.RWMutex
+	getObjectArgsForCall []struct {
+		arg1 context.Context
+		arg2 *s3.GetObjectInput
+		arg3 []func(*s3.Options)
+	}
+	getObjectReturns struct {
+		result1 *s3.GetObjectOutput
+		result2 error
+	}
+	getObjectReturnsOnCall map[int]struct {
+		result1 *s3.GetObjectOutput
+		result2 error
+	}
+	HeadObjectStub        func(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	headObjectMutex       sync.RWMutex
+	headObjectArgsForCall []struct {
+		arg1 context.Context
+		arg2 *s3.HeadObjectInput
+		arg3 []func(*s3.Options)
+	}
+	headObjectReturns struct {
+		result1 *s3.HeadObjectOutput
+		result2 error
+	}
+	headObjectReturnsOnCall map[int]struct {
+		result1 *s3.HeadObjectOutput
+		result2 error
+	}
+	PutObjectStub        func(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	putObjectMutex       sync.RWMutex
+	putObjectArgsForCall []struct {
+		arg1 context.Context
+		arg2 *s3.PutObjectInput
+		arg3 []func(*s3.Options)
+	}
+	putObjectReturns struct {
+		result1 *s3.PutObjectOutput
+		result2 error
+	}
+	putObjectReturnsOnCall map[int]struct {
+		result1 *s3.PutObjectOutput
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeS3ObjectAPI) CopyObject(arg1 context.Context, arg2 *s3.CopyObjectInput, arg3 ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	fake.copyObjectMutex.Lock()
+	ret, specificReturn := fake.copyObjectReturnsOnCall[len(fake.copyObjectArgsForCall)]
+	fake.copyObjectArgsForCall = append(fake.copyObjectArgsForCall, struct {
+		arg1 context.Context
+		arg2 *s3.CopyObjectInput
+		arg3 []func(*s3.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.CopyObjectStub
+	fakeReturns := fake.copyObjectReturns
+	fake.recordInvocation("CopyObject", []interface{}{arg1, arg2, arg3})
+	fake.copyObjectMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeS3ObjectAPI) CopyObjectCallCount() int {
+	fake.copyObjectMutex.RLock()
+	defer fake.copyObjectMutex.RUnlock()
+	return len(fake.copyObjectArgsForCall)
+}
+
+func (fake *FakeS3ObjectAPI) CopyObjectCalls(stub func(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error)) {
+	fake.copyObjectMutex.Lock()
+	defer fake.copyObjectMutex.Unlock()
+	fake.CopyObjectStub = stub
+}
+
+func (fake *FakeS3ObjectAPI) CopyObjectArgsForCall(i int) (context.Context, *s3.CopyObjectInput, []func(*s3.Options)) {
+	fake.copyObjectMutex.RLock()
+	defer fake.copyObjectMutex.RUnlock()
+	argsForCall := fake.copyObjectArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeS3ObjectAPI) CopyObjectReturns(result1 *s3.CopyObjectOutput, result2 error) {
+	fake.copyObjectMutex.Lock()
+	defer fake.copyObjectMutex.Unlock()
+	fake.CopyObjectStub = nil
+	fake.copyObjectReturns = struct {
+		result1 *s3.CopyObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) CopyObjectReturnsOnCall(i int, result1 *s3.CopyObjectOutput, result2 error) {
+	fake.copyObjectMutex.Lock()
+	defer fake.copyObjectMutex.Unlock()
+	fake.CopyObjectStub = nil
+	if fake.copyObjectReturnsOnCall == nil {
+		fake.copyObjectReturnsOnCall = make(map[int]struct {
+			result1 *s3.CopyObjectOutput
+			result2 error
+		})
+	}
+	fake.copyObjectReturnsOnCall[i] = struct {
+		result1 *s3.CopyObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) DeleteObject(arg1 context.Context, arg2 *s3.DeleteObjectInput, arg3 ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	fake.deleteObjectMutex.Lock()
+	ret, specificReturn := fake.deleteObjectReturnsOnCall[len(fake.deleteObjectArgsForCall)]
+	fake.deleteObjectArgsForCall = append(fake.deleteObjectArgsForCall, struct {
+		arg1 context.Context
+		arg2 *s3.DeleteObjectInput
+		arg3 []func(*s3.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.DeleteObjectStub
+	fakeReturns := fake.deleteObjectReturns
+	fake.recordInvocation("DeleteObject", []interface{}{arg1, arg2, arg3})
+	fake.deleteObjectMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeS3ObjectAPI) DeleteObjectCallCount() int {
+	fake.deleteObjectMutex.RLock()
+	defer fake.deleteObjectMutex.RUnlock()
+	return len(fake.deleteObjectArgsForCall)
+}
+
+func (fake *FakeS3ObjectAPI) DeleteObjectCalls(stub func(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)) {
+	fake.deleteObjectMutex.Lock()
+	defer fake.deleteObjectMutex.Unlock()
+	fake.DeleteObjectStub = stub
+}
+
+func (fake *FakeS3ObjectAPI) DeleteObjectArgsForCall(i int) (context.Context, *s3.DeleteObjectInput, []func(*s3.Options)) {
+	fake.deleteObjectMutex.RLock()
+	defer fake.deleteObjectMutex.RUnlock()
+	argsForCall := fake.deleteObjectArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeS3ObjectAPI) DeleteObjectReturns(result1 *s3.DeleteObjectOutput, result2 error) {
+	fake.deleteObjectMutex.Lock()
+	defer fake.deleteObjectMutex.Unlock()
+	fake.DeleteObjectStub = nil
+	fake.deleteObjectReturns = struct {
+		result1 *s3.DeleteObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) DeleteObjectReturnsOnCall(i int, result1 *s3.DeleteObjectOutput, result2 error) {
+	fake.deleteObjectMutex.Lock()
+	defer fake.deleteObjectMutex.Unlock()
+	fake.DeleteObjectStub = nil
+	if fake.deleteObjectReturnsOnCall == nil {
+		fake.deleteObjectReturnsOnCall = make(map[int]struct {
+			result1 *s3.DeleteObjectOutput
+			result2 error
+		})
+	}
+	fake.deleteObjectReturnsOnCall[i] = struct {
+		result1 *s3.DeleteObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) GetObject(arg1 context.Context, arg2 *s3.GetObjectInput, arg3 ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	fake.getObjectMutex.Lock()
+	ret, specificReturn := fake.getObjectReturnsOnCall[len(fake.getObjectArgsForCall)]
+	fake.getObjectArgsForCall = append(fake.getObjectArgsForCall, struct {
+		arg1 context.Context
+		arg2 *s3.GetObjectInput
+		arg3 []func(*s3.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.GetObjectStub
+	fakeReturns := fake.getObjectReturns
+	fake.recordInvocation("GetObject", []interface{}{arg1, arg2, arg3})
+	fake.getObjectMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeS3ObjectAPI) GetObjectCallCount() int {
+	fake.getObjectMutex.RLock()
+	defer fake.getObjectMutex.RUnlock()
+	return len(fake.getObjectArgsForCall)
+}
+
+func (fake *FakeS3ObjectAPI) GetObjectCalls(stub func(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)) {
+	fake.getObjectMutex.Lock()
+	defer fake.getObjectMutex.Unlock()
+	fake.GetObjectStub = stub
+}
+
+func (fake *FakeS3ObjectAPI) GetObjectArgsForCall(i int) (context.Context, *s3.GetObjectInput, []func(*s3.Options)) {
+	fake.getObjectMutex.RLock()
+	defer fake.getObjectMutex.RUnlock()
+	argsForCall := fake.getObjectArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeS3ObjectAPI) GetObjectReturns(result1 *s3.GetObjectOutput, result2 error) {
+	fake.getObjectMutex.Lock()
+	defer fake.getObjectMutex.Unlock()
+	fake.GetObjectStub = nil
+	fake.getObjectReturns = struct {
+		result1 *s3.GetObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) GetObjectReturnsOnCall(i int, result1 *s3.GetObjectOutput, result2 error) {
+	fake.getObjectMutex.Lock()
+	defer fake.getObjectMutex.Unlock()
+	fake.GetObjectStub = nil
+	if fake.getObjectReturnsOnCall == nil {
+		fake.getObjectReturnsOnCall = make(map[int]struct {
+			result1 *s3.GetObjectOutput
+			result2 error
+		})
+	}
+	fake.getObjectReturnsOnCall[i] = struct {
+		result1 *s3.GetObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) HeadObject(arg1 context.Context, arg2 *s3.HeadObjectInput, arg3 ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	fake.headObjectMutex.Lock()
+	ret, specificReturn := fake.headObjectReturnsOnCall[len(fake.headObjectArgsForCall)]
+	fake.headObjectArgsForCall = append(fake.headObjectArgsForCall, struct {
+		arg1 context.Context
+		arg2 *s3.HeadObjectInput
+		arg3 []func(*s3.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.HeadObjectStub
+	fakeReturns := fake.headObjectReturns
+	fake.recordInvocation("HeadObject", []interface{}{arg1, arg2, arg3})
+	fake.headObjectMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeS3ObjectAPI) HeadObjectCallCount() int {
+	fake.headObjectMutex.RLock()
+	defer fake.headObjectMutex.RUnlock()
+	return len(fake.headObjectArgsForCall)
+}
+
+func (fake *FakeS3ObjectAPI) HeadObjectCalls(stub func(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)) {
+	fake.headObjectMutex.Lock()
+	defer fake.headObjectMutex.Unlock()
+	fake.HeadObjectStub = stub
+}
+
+func (fake *FakeS3ObjectAPI) HeadObjectArgsForCall(i int) (context.Context, *s3.HeadObjectInput, []func(*s3.Options)) {
+	fake.headObjectMutex.RLock()
+	defer fake.headObjectMutex.RUnlock()
+	argsForCall := fake.headObjectArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeS3ObjectAPI) HeadObjectReturns(result1 *s3.HeadObjectOutput, result2 error) {
+	fake.headObjectMutex.Lock()
+	defer fake.headObjectMutex.Unlock()
+	fake.HeadObjectStub = nil
+	fake.headObjectReturns = struct {
+		result1 *s3.HeadObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) HeadObjectReturnsOnCall(i int, result1 *s3.HeadObjectOutput, result2 error) {
+	fake.headObjectMutex.Lock()
+	defer fake.headObjectMutex.Unlock()
+	fake.HeadObjectStub = nil
+	if fake.headObjectReturnsOnCall == nil {
+		fake.headObjectReturnsOnCall = make(map[int]struct {
+			result1 *s3.HeadObjectOutput
+			result2 error
+		})
+	}
+	fake.headObjectReturnsOnCall[i] = struct {
+		result1 *s3.HeadObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) PutObject(arg1 context.Context, arg2 *s3.PutObjectInput, arg3 ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	fake.putObjectMutex.Lock()
+	ret, specificReturn := fake.putObjectReturnsOnCall[len(fake.putObjectArgsForCall)]
+	fake.putObjectArgsForCall = append(fake.putObjectArgsForCall, struct {
+		arg1 context.Context
+		arg2 *s3.PutObjectInput
+		arg3 []func(*s3.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.PutObjectStub
+	fakeReturns := fake.putObjectReturns
+	fake.recordInvocation("PutObject", []interface{}{arg1, arg2, arg3})
+	fake.putObjectMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeS3ObjectAPI) PutObjectCallCount() int {
+	fake.putObjectMutex.RLock()
+	defer fake.putObjectMutex.RUnlock()
+	return len(fake.putObjectArgsForCall)
+}
+
+func (fake *FakeS3ObjectAPI) PutObjectCalls(stub func(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)) {
+	fake.putObjectMutex.Lock()
+	defer fake.putObjectMutex.Unlock()
+	fake.PutObjectStub = stub
+}
+
+func (fake *FakeS3ObjectAPI) PutObjectArgsForCall(i int) (context.Context, *s3.PutObjectInput, []func(*s3.Options)) {
+	fake.putObjectMutex.RLock()
+	defer fake.putObjectMutex.RUnlock()
+	argsForCall := fake.putObjectArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeS3ObjectAPI) PutObjectReturns(result1 *s3.PutObjectOutput, result2 error) {
+	fake.putObjectMutex.Lock()
+	defer fake.putObjectMutex.Unlock()
+	fake.PutObjectStub = nil
+	fake.putObjectReturns = struct {
+		result1 *s3.PutObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) PutObjectReturnsOnCall(i int, result1 *s3.PutObjectOutput, result2 error) {
+	fake.putObjectMutex.Lock()
+	defer fake.putObjectMutex.Unlock()
+	fake.PutObjectStub = nil
+	if fake.putObjectReturnsOnCall == nil {
+		fake.putObjectReturnsOnCall = make(map[int]struct {
+			result1 *s3.PutObjectOutput
+			result2 error
+		})
+	}
+	fake.putObjectReturnsOnCall[i] = struct {
+		result1 *s3.PutObjectOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeS3ObjectAPI) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.copyObjectMutex.RLock()
+	defer fake.copyObjectMutex.RUnlock()
+	fake.deleteObjectMutex.RLock()
+	defer fake.deleteObjectMutex.RUnlock()
+	fake.getObjectMutex.RLock()
+	defer fake.getObjectMutex.RUnlock()
+	fake.headObjectMutex.RLock()
+	defer fake.headObjectMutex.RUnlock()
+	fake.putObjectMutex.RLock()
+	defer fake.putObjectMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeS3ObjectAPI) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ api.S3ObjectAPI = new(FakeS3ObjectAPI)
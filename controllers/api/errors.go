@@ -0,0 +1,86 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"errors"
+)
+
+// reasons classifying failures of the object store, used in conditions,
+// events and metrics
+const (
+	ReasonAccessDenied       = "AccessDenied"
+	ReasonBucketNotFound     = "BucketNotFound"
+	ReasonInvalidCredentials = "InvalidCredentials"
+	ReasonExpiredCredentials = "ExpiredCredentials"
+	ReasonThrottled          = "Throttled"
+	ReasonTimeout            = "Timeout"
+	ReasonNetworkError       = "NetworkError"
+	ReasonUnknown            = "Unknown"
+)
+
+var (
+	// ErrNotFound is returned when the object does not exist in the object store
+	ErrNotFound = errors.New("object not found")
+	// ErrBucketNotFound matches errors caused by a missing bucket
+	ErrBucketNotFound = errors.New("bucket not found")
+	// ErrInvalidCredentials matches errors caused by rejected or expired credentials
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrAccessDenied matches errors caused by a missing permission
+	ErrAccessDenied = errors.New("access denied")
+)
+
+// sentinels maps the reasons to the errors they match
+var sentinels = map[string]error{
+	ReasonAccessDenied:       ErrAccessDenied,
+	ReasonBucketNotFound:     ErrBucketNotFound,
+	ReasonInvalidCredentials: ErrInvalidCredentials,
+	ReasonExpiredCredentials: ErrInvalidCredentials,
+}
+
+// Error is a failure of the object store classified by a stable reason
+type Error struct {
+	// Reason classifies the failure
+	Reason string
+	// Message describes the failure without request details
+	Message string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the sentinel error of the reason
+func (e *Error) Is(target error) bool {
+	sentinel, ok := sentinels[e.Reason]
+	return ok && sentinel == target
+}
+
+// ReasonOf returns the reason of a classified error, or ReasonUnknown
+func ReasonOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return ReasonUnknown
+}
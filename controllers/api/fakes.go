@@ -16,4 +16,4 @@ limitations under the License.
 
 package api
 
-//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
+//go:generate go tool counterfeiter -generate
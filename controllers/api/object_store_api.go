@@ -19,11 +19,33 @@ package api
 import (
 	"context"
 
-	cloudobject "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
 )
 
+// ObjectInfo describes an object persisted to the object store
+type ObjectInfo struct {
+	// ETag identifies the content of the object
+	ETag string
+	// VersionID is only set when versioning is enabled on the bucket
+	VersionID string
+	// Metadata holds the user defined metadata of the object
+	Metadata map[string]string
+	// Size of the object in bytes, only set by Head
+	Size int64
+}
+
 //counterfeiter:generate . ObjectStore
 type ObjectStore interface {
-	Store(context.Context, []byte, cloudobject.ObjectTarget) error
+	Store(context.Context, []byte, cloudobject.ObjectTarget, map[string]string) (ObjectInfo, error)
+	Head(context.Context, cloudobject.ObjectTarget) (ObjectInfo, error)
+	// Get downloads a version of the object, the current version if the
+	// version id is empty
+	Get(context.Context, cloudobject.ObjectTarget, string) ([]byte, ObjectInfo, error)
+	// Copy copies the source object into the target object server side,
+	// replacing its metadata, the source must be readable with the
+	// credentials of the store
+	Copy(context.Context, cloudobject.ObjectTarget, cloudobject.ObjectTarget, map[string]string) (ObjectInfo, error)
 	Delete(context.Context, cloudobject.ObjectTarget) error
+	DeleteVersion(context.Context, cloudobject.ObjectTarget, string) error
+	CreateBucket(context.Context, cloudobject.ObjectTarget) error
 }
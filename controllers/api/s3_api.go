@@ -22,6 +22,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+//counterfeiter:generate . S3ObjectAPI
 type S3ObjectAPI interface {
 	PutObject(ctx context.Context,
 		params *s3.PutObjectInput,
@@ -29,6 +30,15 @@ type S3ObjectAPI interface {
 	DeleteObject(ctx context.Context,
 		params *s3.DeleteObjectInput,
 		optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	HeadObject(ctx context.Context,
+		params *s3.HeadObjectInput,
+		optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context,
+		params *s3.GetObjectInput,
+		optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	CopyObject(ctx context.Context,
+		params *s3.CopyObjectInput,
+		optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
 }
 
 func PutItem(c context.Context, api S3ObjectAPI, input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
@@ -38,3 +48,60 @@ func PutItem(c context.Context, api S3ObjectAPI, input *s3.PutObjectInput) (*s3.
 func DeleteItem(c context.Context, api S3ObjectAPI, input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
 	return api.DeleteObject(c, input)
 }
+
+func HeadItem(c context.Context, api S3ObjectAPI, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return api.HeadObject(c, input)
+}
+
+func GetItem(c context.Context, api S3ObjectAPI, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return api.GetObject(c, input)
+}
+
+func CopyItem(c context.Context, api S3ObjectAPI, input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	return api.CopyObject(c, input)
+}
+
+type S3BucketAPI interface {
+	CreateBucket(ctx context.Context,
+		params *s3.CreateBucketInput,
+		optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	PutPublicAccessBlock(ctx context.Context,
+		params *s3.PutPublicAccessBlockInput,
+		optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error)
+	PutBucketEncryption(ctx context.Context,
+		params *s3.PutBucketEncryptionInput,
+		optFns ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error)
+	PutBucketVersioning(ctx context.Context,
+		params *s3.PutBucketVersioningInput,
+		optFns ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error)
+	HeadBucket(ctx context.Context,
+		params *s3.HeadBucketInput,
+		optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	DeleteBucket(ctx context.Context,
+		params *s3.DeleteBucketInput,
+		optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error)
+}
+
+func MakeBucket(c context.Context, api S3BucketAPI, input *s3.CreateBucketInput) (*s3.CreateBucketOutput, error) {
+	return api.CreateBucket(c, input)
+}
+
+func RemoveBucket(c context.Context, api S3BucketAPI, input *s3.DeleteBucketInput) (*s3.DeleteBucketOutput, error) {
+	return api.DeleteBucket(c, input)
+}
+
+func BlockPublicAccess(c context.Context, api S3BucketAPI, input *s3.PutPublicAccessBlockInput) (*s3.PutPublicAccessBlockOutput, error) {
+	return api.PutPublicAccessBlock(c, input)
+}
+
+func EncryptBucket(c context.Context, api S3BucketAPI, input *s3.PutBucketEncryptionInput) (*s3.PutBucketEncryptionOutput, error) {
+	return api.PutBucketEncryption(c, input)
+}
+
+func VersionBucket(c context.Context, api S3BucketAPI, input *s3.PutBucketVersioningInput) (*s3.PutBucketVersioningOutput, error) {
+	return api.PutBucketVersioning(c, input)
+}
+
+func ProbeBucket(c context.Context, api S3BucketAPI, input *s3.HeadBucketInput) (*s3.HeadBucketOutput, error) {
+	return api.HeadBucket(c, input)
+}
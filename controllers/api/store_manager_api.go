@@ -16,12 +16,40 @@ limitations under the License.
 
 package api
 
+import "context"
+
 type ConfigData struct {
-	Secret []byte
-	Region string
+	Secret    []byte
+	Region    string
+	Limits    Limits
+	Endpoints Endpoints
+}
+
+// Endpoints override the default endpoints of the object store, the
+// default endpoints are used if empty
+type Endpoints struct {
+	// S3 endpoint URL
+	S3 string
+	// STS endpoint URL
+	STS string
+}
+
+// Limits pace and retry the calls made to the object store
+type Limits struct {
+	// RequestsPerSecond allowed per bucket and credentials, unlimited if zero
+	RequestsPerSecond float64
+	// Burst of requests allowed above RequestsPerSecond
+	Burst int
+	// MaxAttempts of every call, including retries
+	MaxAttempts int
+	// RetryMode: standard / adaptive, adaptive lowers the request rate
+	// while calls are throttled
+	RetryMode string
 }
 
 //counterfeiter:generate . StoreManager
 type StoreManager interface {
 	Get(ConfigData) ObjectStore
+	// Validate checks that the credentials are valid and grant access to the bucket
+	Validate(context.Context, ConfigData, string) error
 }
@@ -0,0 +1,129 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/pkg/errors"
+)
+
+// defaultRegion is the region buckets are created in without a location constraint
+const defaultRegion = "us-east-1"
+
+// CreateBucket creates the target bucket in the target region and applies
+// the public access, encryption and versioning settings of the target, a
+// bucket whose settings cannot be applied is removed again
+func (s *s3ObjectStore) CreateBucket(ctx context.Context, target cloudobject.ObjectTarget) error {
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.call(ctx, target.Bucket, func() error {
+		return createBucket(ctx, client, target)
+	})
+}
+
+func createBucket(ctx context.Context, api ctrlapi.S3BucketAPI, target cloudobject.ObjectTarget) error {
+	input := &s3.CreateBucketInput{
+		Bucket: &target.Bucket,
+	}
+	if target.Region != "" && target.Region != defaultRegion {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(target.Region),
+		}
+	}
+
+	var owned *types.BucketAlreadyOwnedByYou
+	created := true
+	if _, err := ctrlapi.MakeBucket(ctx, api, input); err != nil {
+		if !errors.As(err, &owned) {
+			return classify(fmt.Sprintf("s3:CreateBucket on bucket %s", target.Bucket), err)
+		}
+		created = false
+	}
+
+	err := configureBucket(ctx, api, target)
+	if err == nil || !created {
+		return err
+	}
+	// the new and still empty bucket is removed when its settings cannot be
+	// applied, so that the next store creates it again instead of writing
+	// into an unprotected bucket
+	if _, deleteErr := ctrlapi.RemoveBucket(ctx, api, &s3.DeleteBucketInput{Bucket: &target.Bucket}); deleteErr != nil {
+		return errors.Wrapf(err, "bucket %s left without its settings, s3:DeleteBucket failed: %s", target.Bucket, deleteErr)
+	}
+	return err
+}
+
+// configureBucket applies the public access, encryption and versioning
+// settings of the target to its bucket
+func configureBucket(ctx context.Context, api ctrlapi.S3BucketAPI, target cloudobject.ObjectTarget) error {
+	settings := cloudobject.BucketSettings{}
+	if target.BucketSettings != nil {
+		settings = *target.BucketSettings
+	}
+
+	if !settings.AllowPublicAccess {
+		if _, err := ctrlapi.BlockPublicAccess(ctx, api, &s3.PutPublicAccessBlockInput{
+			Bucket: &target.Bucket,
+			PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+				BlockPublicAcls:       true,
+				BlockPublicPolicy:     true,
+				IgnorePublicAcls:      true,
+				RestrictPublicBuckets: true,
+			},
+		}); err != nil {
+			return classify(fmt.Sprintf("s3:PutBucketPublicAccessBlock on bucket %s", target.Bucket), err)
+		}
+	}
+
+	encryption := &types.ServerSideEncryptionByDefault{SSEAlgorithm: types.ServerSideEncryptionAes256}
+	if settings.Encryption == string(types.ServerSideEncryptionAwsKms) {
+		encryption.SSEAlgorithm = types.ServerSideEncryptionAwsKms
+		if settings.KMSKeyID != "" {
+			encryption.KMSMasterKeyID = &settings.KMSKeyID
+		}
+	}
+	if _, err := ctrlapi.EncryptBucket(ctx, api, &s3.PutBucketEncryptionInput{
+		Bucket: &target.Bucket,
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{ApplyServerSideEncryptionByDefault: encryption}},
+		},
+	}); err != nil {
+		return classify(fmt.Sprintf("s3:PutEncryptionConfiguration on bucket %s", target.Bucket), err)
+	}
+
+	if settings.Versioning {
+		if _, err := ctrlapi.VersionBucket(ctx, api, &s3.PutBucketVersioningInput{
+			Bucket: &target.Bucket,
+			VersioningConfiguration: &types.VersioningConfiguration{
+				Status: types.BucketVersioningStatusEnabled,
+			},
+		}); err != nil {
+			return classify(fmt.Sprintf("s3:PutBucketVersioning on bucket %s", target.Bucket), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+// newRecordingClient returns an S3 client answering every request with an
+// empty success response and recording the request bodies by sub resource
+func newRecordingClient(requests map[string]string) *s3.Client {
+	return s3.New(s3.Options{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		Retryer:     aws.NopRetryer{},
+		HTTPClient: smithyhttp.ClientDoFunc(func(r *http.Request) (*http.Response, error) {
+			body := []byte{}
+			if r.Body != nil {
+				body, _ = ioutil.ReadAll(r.Body)
+			}
+			resource := strings.SplitN(r.URL.RawQuery, "=", 2)[0]
+			requests[resource] = string(body)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    r,
+			}, nil
+		}),
+	})
+}
+
+// newFailingClient returns an S3 client recording the method and sub resource
+// of every request and answering those listed in failures with the given
+// status and error code
+func newFailingClient(calls *[]string, failures map[string]int) *s3.Client {
+	codes := map[int]string{
+		http.StatusForbidden: "AccessDenied",
+		http.StatusConflict:  "BucketAlreadyOwnedByYou",
+	}
+	return s3.New(s3.Options{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		Retryer:     aws.NopRetryer{},
+		HTTPClient: smithyhttp.ClientDoFunc(func(r *http.Request) (*http.Response, error) {
+			call := strings.TrimSpace(r.Method + " " + strings.SplitN(r.URL.RawQuery, "=", 2)[0])
+			*calls = append(*calls, call)
+			status, body := http.StatusOK, ""
+			if failed, ok := failures[call]; ok {
+				status = failed
+				body = "<Error><Code>" + codes[failed] + "</Code></Error>"
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    r,
+			}, nil
+		}),
+	})
+}
+
+var _ = Describe("Bucket creation", func() {
+	It("should create the bucket in the target region with secure defaults", func() {
+		requests := map[string]string{}
+		err := createBucket(context.Background(), newRecordingClient(requests), cloudobject.ObjectTarget{
+			Bucket: "test-bucket",
+			Region: "eu-west-1",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).To(HaveKeyWithValue("", ContainSubstring("<LocationConstraint>eu-west-1</LocationConstraint>")))
+		Expect(requests).To(HaveKeyWithValue("publicAccessBlock", ContainSubstring("<BlockPublicAcls>true</BlockPublicAcls>")))
+		Expect(requests).To(HaveKeyWithValue("encryption", ContainSubstring("<SSEAlgorithm>AES256</SSEAlgorithm>")))
+		Expect(requests).NotTo(HaveKey("versioning"))
+	})
+
+	It("should apply the bucket settings of the target", func() {
+		requests := map[string]string{}
+		err := createBucket(context.Background(), newRecordingClient(requests), cloudobject.ObjectTarget{
+			Bucket: "test-bucket",
+			Region: "eu-west-1",
+			BucketSettings: &cloudobject.BucketSettings{
+				AllowPublicAccess: true,
+				Encryption:        "aws:kms",
+				KMSKeyID:          "test-key",
+				Versioning:        true,
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).NotTo(HaveKey("publicAccessBlock"))
+		Expect(requests).To(HaveKeyWithValue("encryption", ContainSubstring("<KMSMasterKeyID>test-key</KMSMasterKeyID>")))
+		Expect(requests).To(HaveKeyWithValue("versioning", ContainSubstring("<Status>Enabled</Status>")))
+	})
+	It("should remove a created bucket whose settings cannot be applied", func() {
+		calls := []string{}
+		err := createBucket(context.Background(), newFailingClient(&calls, map[string]int{
+			"PUT encryption": http.StatusForbidden,
+		}), cloudobject.ObjectTarget{
+			Bucket: "test-bucket",
+			Region: "eu-west-1",
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("s3:PutEncryptionConfiguration"))
+
+		Expect(calls).To(Equal([]string{"PUT", "PUT publicAccessBlock", "PUT encryption", "DELETE"}))
+	})
+
+	It("should apply the settings again to a bucket it already owns", func() {
+		calls := []string{}
+		err := createBucket(context.Background(), newFailingClient(&calls, map[string]int{
+			"PUT": http.StatusConflict,
+		}), cloudobject.ObjectTarget{
+			Bucket: "test-bucket",
+			Region: "eu-west-1",
+			BucketSettings: &cloudobject.BucketSettings{
+				Versioning: true,
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(calls).To(Equal([]string{"PUT", "PUT publicAccessBlock", "PUT encryption", "PUT versioning"}))
+	})
+
+	It("should not remove a bucket it did not create", func() {
+		calls := []string{}
+		err := createBucket(context.Background(), newFailingClient(&calls, map[string]int{
+			"PUT":            http.StatusConflict,
+			"PUT encryption": http.StatusForbidden,
+		}), cloudobject.ObjectTarget{
+			Bucket: "test-bucket",
+			Region: "eu-west-1",
+		})
+		Expect(err).To(HaveOccurred())
+
+		Expect(calls).NotTo(ContainElement("DELETE"))
+	})
+})
@@ -20,9 +20,14 @@ import (
 	"context"
 	"fmt"
 
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/go-ini/ini"
 	"github.com/pkg/errors"
 )
@@ -73,14 +78,69 @@ func credentialsIDSecret(data []byte, profile string) (aws.Credentials, error) {
 }
 
 // UseProviderSecret - AWS configuration which can be used to issue requests against AWS API
-func useProviderSecret(ctx context.Context, data []byte, region, profile string) (*aws.Config, error) {
+func useProviderSecret(ctx context.Context, data []byte, region, profile string, optFns ...func(*config.LoadOptions) error) (*aws.Config, error) {
 	creds, err := credentialsIDSecret(data, profile)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot parse credentials secret")
+		return nil, &ctrlapi.Error{
+			Reason:  ctrlapi.ReasonInvalidCredentials,
+			Message: err.Error(),
+			Err:     err,
+		}
 	}
 
-	config, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), config.WithCredentialsProvider(credentials.StaticCredentialsProvider{
-		Value: creds,
-	}))
+	optFns = append([]func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithCredentialsProvider(credentials.StaticCredentialsProvider{
+			Value: creds,
+		}),
+		config.WithAPIOptions([]func(*middleware.Stack) error{addTracingMiddleware}),
+	}, optFns...)
+
+	config, err := config.LoadDefaultConfig(ctx, optFns...)
 	return &config, err
 }
+
+// clientOptions returns the options of the clients created for data,
+// adaptive is the retryer shared by the clients in adaptive mode if not nil
+func clientOptions(data ctrlapi.ConfigData, adaptive *retry.AdaptiveMode) []func(*config.LoadOptions) error {
+	return []func(*config.LoadOptions) error{
+		withRetries(data.Limits, adaptive),
+		withEndpoints(data.Endpoints),
+	}
+}
+
+// withEndpoints resolves the S3 and STS endpoints to the configured URLs,
+// falling back to the default endpoints
+func withEndpoints(endpoints ctrlapi.Endpoints) config.LoadOptionsFunc {
+	urls := map[string]string{
+		s3.ServiceID:  endpoints.S3,
+		sts.ServiceID: endpoints.STS,
+	}
+	return config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
+		func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			if url := urls[service]; url != "" {
+				// custom endpoints are addressed by path rather than by
+				// virtual host
+				return aws.Endpoint{URL: url, SigningRegion: region, HostnameImmutable: true}, nil
+			}
+			return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+		}))
+}
+
+// withRetries configures the retry mode and the number of attempts of every
+// call
+func withRetries(limits ctrlapi.Limits, adaptive *retry.AdaptiveMode) config.LoadOptionsFunc {
+	return config.WithRetryer(func() aws.Retryer {
+		retryer := aws.Retryer(retry.NewStandard())
+		if limits.RetryMode == RetryModeAdaptive {
+			if adaptive == nil {
+				adaptive = retry.NewAdaptiveMode()
+			}
+			retryer = adaptive
+		}
+		if limits.MaxAttempts > 0 {
+			retryer = retry.AddWithMaxAttempts(retryer, limits.MaxAttempts)
+		}
+		return retryer
+	})
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+var _ = Describe("Endpoints", func() {
+	It("should resolve the configured endpoints and fall back to the defaults", func() {
+		var options config.LoadOptions
+		Expect(withEndpoints(ctrlapi.Endpoints{S3: "https://minio.example.com:9000"})(&options)).To(Succeed())
+		resolver := options.EndpointResolverWithOptions
+
+		endpoint, err := resolver.ResolveEndpoint(s3.ServiceID, "eu-west-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(endpoint.URL).To(Equal("https://minio.example.com:9000"))
+		Expect(endpoint.SigningRegion).To(Equal("eu-west-1"))
+		Expect(endpoint.HostnameImmutable).To(BeTrue())
+
+		_, err = resolver.ResolveEndpoint(sts.ServiceID, "eu-west-1")
+		var notFound *aws.EndpointNotFoundError
+		Expect(err).To(BeAssignableToTypeOf(notFound))
+	})
+})
+
+var _ = Describe("Retries", func() {
+	It("should use the adaptive retryer of the SDK in adaptive mode", func() {
+		var options config.LoadOptions
+		Expect(withRetries(ctrlapi.Limits{}, nil)(&options)).To(Succeed())
+		Expect(options.Retryer()).To(BeAssignableToTypeOf(&retry.Standard{}))
+
+		adaptive := retry.NewAdaptiveMode()
+		Expect(withRetries(ctrlapi.Limits{RetryMode: RetryModeAdaptive}, adaptive)(&options)).To(Succeed())
+		Expect(options.Retryer()).To(BeIdenticalTo(adaptive))
+	})
+
+	It("should share the adaptive retryer of the same credentials and region", func() {
+		limiters := NewLimiters()
+		data := ctrlapi.ConfigData{Secret: []byte(testSecret), Region: "eu-west-1", Limits: ctrlapi.Limits{RetryMode: RetryModeAdaptive}}
+		Expect(limiters.retryer(data)).NotTo(BeNil())
+		Expect(limiters.retryer(data)).To(BeIdenticalTo(limiters.retryer(data)))
+
+		other := data
+		other.Region = "us-west-2"
+		Expect(limiters.retryer(other)).NotTo(BeIdenticalTo(limiters.retryer(data)))
+
+		data.Limits.RetryMode = RetryModeStandard
+		Expect(limiters.retryer(data)).To(BeNil())
+	})
+})
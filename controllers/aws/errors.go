@@ -0,0 +1,101 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+)
+
+// errorReasons maps AWS error codes to the reasons they are classified as
+var errorReasons = map[string]string{
+	"AccessDenied":          ctrlapi.ReasonAccessDenied,
+	"AllAccessDisabled":     ctrlapi.ReasonAccessDenied,
+	"AccountProblem":        ctrlapi.ReasonAccessDenied,
+	"NoSuchBucket":          ctrlapi.ReasonBucketNotFound,
+	"InvalidAccessKeyId":    ctrlapi.ReasonInvalidCredentials,
+	"SignatureDoesNotMatch": ctrlapi.ReasonInvalidCredentials,
+	"InvalidClientTokenId":  ctrlapi.ReasonInvalidCredentials,
+	"InvalidToken":          ctrlapi.ReasonInvalidCredentials,
+	"ExpiredToken":          ctrlapi.ReasonExpiredCredentials,
+	"ExpiredTokenException": ctrlapi.ReasonExpiredCredentials,
+	"RequestExpired":        ctrlapi.ReasonExpiredCredentials,
+	"SlowDown":              ctrlapi.ReasonThrottled,
+	"Throttling":            ctrlapi.ReasonThrottled,
+	"ThrottlingException":   ctrlapi.ReasonThrottled,
+	"RequestLimitExceeded":  ctrlapi.ReasonThrottled,
+	"TooManyRequests":       ctrlapi.ReasonThrottled,
+	"RequestTimeout":        ctrlapi.ReasonTimeout,
+}
+
+// classify turns an error returned by the SDK for the given operation into
+// a ctrlapi.Error with a stable reason and a message without request details
+func classify(operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	reason, detail := ctrlapi.ReasonUnknown, err.Error()
+
+	var apiErr smithy.APIError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		detail = apiErr.ErrorCode()
+		if msg := apiErr.ErrorMessage(); msg != "" {
+			detail = fmt.Sprintf("%s: %s", detail, msg)
+		}
+		if r, ok := errorReasons[apiErr.ErrorCode()]; ok {
+			reason = r
+		} else if httpStatus(err) != 0 {
+			reason = statusReason(httpStatus(err))
+		}
+	case httpStatus(err) != 0:
+		// responses without a body, e.g. to HEAD requests, carry no error code
+		reason = statusReason(httpStatus(err))
+		detail = http.StatusText(httpStatus(err))
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		reason = ctrlapi.ReasonTimeout
+	case errors.As(err, &netErr):
+		reason = ctrlapi.ReasonNetworkError
+	}
+
+	return &ctrlapi.Error{
+		Reason:  reason,
+		Message: fmt.Sprintf("%s failed: %s", operation, detail),
+		Err:     err,
+	}
+}
+
+func statusReason(status int) string {
+	switch status {
+	case http.StatusForbidden:
+		return ctrlapi.ReasonAccessDenied
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return ctrlapi.ReasonThrottled
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ctrlapi.ReasonTimeout
+	default:
+		return ctrlapi.ReasonUnknown
+	}
+}
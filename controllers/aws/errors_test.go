@@ -0,0 +1,75 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+var _ = Describe("Error classification", func() {
+	putObject := func(status int, body string) error {
+		_, err := newTestClient(status, body).PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String("test-bucket"),
+			Key:    aws.String("test.key"),
+			Body:   bytes.NewReader([]byte("test-data")),
+		})
+		return classify("s3:PutObject on s3://test-bucket/test.key", err)
+	}
+
+	DescribeTable("should map AWS error codes to reasons",
+		func(status int, code, reason string) {
+			err := putObject(status, "<Error><Code>"+code+"</Code><Message>test message</Message><RequestId>test-request</RequestId></Error>")
+			Expect(ctrlapi.ReasonOf(err)).To(Equal(reason))
+			Expect(err.Error()).To(Equal("s3:PutObject on s3://test-bucket/test.key failed: " + code + ": test message"))
+		},
+		Entry("access denied", http.StatusForbidden, "AccessDenied", ctrlapi.ReasonAccessDenied),
+		Entry("missing bucket", http.StatusNotFound, "NoSuchBucket", ctrlapi.ReasonBucketNotFound),
+		Entry("throttling", http.StatusServiceUnavailable, "SlowDown", ctrlapi.ReasonThrottled),
+		Entry("unknown access key", http.StatusForbidden, "InvalidAccessKeyId", ctrlapi.ReasonInvalidCredentials),
+		Entry("expired token", http.StatusBadRequest, "ExpiredToken", ctrlapi.ReasonExpiredCredentials),
+		Entry("unknown code", http.StatusBadRequest, "InvalidArgument", ctrlapi.ReasonUnknown),
+	)
+
+	It("should match the sentinel errors of the reasons", func() {
+		Expect(errors.Is(putObject(http.StatusNotFound, "<Error><Code>NoSuchBucket</Code></Error>"), ctrlapi.ErrBucketNotFound)).To(BeTrue())
+		Expect(errors.Is(putObject(http.StatusBadRequest, "<Error><Code>ExpiredToken</Code></Error>"), ctrlapi.ErrInvalidCredentials)).To(BeTrue())
+		Expect(errors.Is(putObject(http.StatusForbidden, "<Error><Code>AccessDenied</Code></Error>"), ctrlapi.ErrAccessDenied)).To(BeTrue())
+	})
+
+	It("should classify responses without an error code by status", func() {
+		Expect(ctrlapi.ReasonOf(putObject(http.StatusForbidden, ""))).To(Equal(ctrlapi.ReasonAccessDenied))
+	})
+
+	It("should classify network failures", func() {
+		timeout := &net.DNSError{Err: "i/o timeout", IsTimeout: true}
+		Expect(ctrlapi.ReasonOf(classify("s3:PutObject", timeout))).To(Equal(ctrlapi.ReasonTimeout))
+		Expect(ctrlapi.ReasonOf(classify("s3:PutObject", context.DeadlineExceeded))).To(Equal(ctrlapi.ReasonTimeout))
+		Expect(ctrlapi.ReasonOf(classify("s3:PutObject", &net.OpError{Op: "dial", Err: errors.New("connection refused")}))).To(Equal(ctrlapi.ReasonNetworkError))
+	})
+})
@@ -0,0 +1,165 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"golang.org/x/time/rate"
+)
+
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+
+	// adaptive mode never lowers the request rate below minAdaptiveRate and
+	// recovers by adaptiveRecovery on every successful call
+	minAdaptiveRate  = 0.5
+	adaptiveRecovery = 1.1
+)
+
+// Limiters hands out one rate limiter per bucket and credentials, so that
+// all objects storing into a bucket with the same credentials share it, and
+// one adaptive retryer per credentials and region
+type Limiters struct {
+	mu       sync.Mutex
+	limiters map[string]*bucketLimiter
+	retryers map[string]*retry.AdaptiveMode
+}
+
+func NewLimiters() *Limiters {
+	return &Limiters{
+		limiters: map[string]*bucketLimiter{},
+		retryers: map[string]*retry.AdaptiveMode{},
+	}
+}
+
+// retryer returns the adaptive retryer of the credentials and region, so
+// that the client side rate of the SDK outlives a single client, nil unless
+// the adaptive retry mode is configured
+func (l *Limiters) retryer(config ctrlapi.ConfigData) *retry.AdaptiveMode {
+	if l == nil || config.Limits.RetryMode != RetryModeAdaptive {
+		return nil
+	}
+
+	key := credentialsKey(config) + "/" + config.Region
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	retryer, ok := l.retryers[key]
+	if !ok {
+		retryer = retry.NewAdaptiveMode()
+		l.retryers[key] = retryer
+	}
+	return retryer
+}
+
+// get returns the limiter of the bucket, applying the latest limits to it
+func (l *Limiters) get(config ctrlapi.ConfigData, bucket string) *bucketLimiter {
+	if l == nil || config.Limits.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	key := credentialsKey(config) + "/" + bucket
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = &bucketLimiter{Limiter: rate.NewLimiter(rate.Limit(config.Limits.RequestsPerSecond), burst(config.Limits))}
+		l.limiters[key] = limiter
+	}
+	limiter.configure(config.Limits)
+	return limiter
+}
+
+// credentialsKey identifies the credentials of config without keeping them
+func credentialsKey(config ctrlapi.ConfigData) string {
+	sum := sha256.Sum256(config.Secret)
+	return hex.EncodeToString(sum[:])
+}
+
+func burst(limits ctrlapi.Limits) int {
+	if limits.Burst > 0 {
+		return limits.Burst
+	}
+	return 1
+}
+
+// bucketLimiter paces the calls made to a bucket, lowering the rate in
+// adaptive mode while the calls are throttled
+type bucketLimiter struct {
+	*rate.Limiter
+
+	mu       sync.Mutex
+	max      rate.Limit
+	adaptive bool
+}
+
+func (b *bucketLimiter) configure(limits ctrlapi.Limits) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	max := rate.Limit(limits.RequestsPerSecond)
+	if max != b.max {
+		b.max = max
+		b.SetLimit(max)
+	}
+	b.SetBurst(burst(limits))
+	b.adaptive = limits.RetryMode == RetryModeAdaptive
+}
+
+// wait blocks until the next call is allowed
+func (b *bucketLimiter) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	return b.Wait(ctx)
+}
+
+// observe adapts the rate to the outcome of a call
+func (b *bucketLimiter) observe(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.adaptive {
+		return
+	}
+
+	switch {
+	case ctrlapi.ReasonOf(err) == ctrlapi.ReasonThrottled:
+		limit := b.Limit() / 2
+		if limit < minAdaptiveRate {
+			limit = minAdaptiveRate
+		}
+		b.SetLimit(limit)
+	case err == nil && b.Limit() < b.max:
+		limit := b.Limit() * adaptiveRecovery
+		if limit > b.max {
+			limit = b.max
+		}
+		b.SetLimit(limit)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"dev.nimak.link/s3-copy-controller/controllers/api/apifakes"
+)
+
+const testSecret = `[default]
+aws_access_key_id = id
+aws_secret_access_key = secret
+`
+
+// newFakeStore returns an object store sending its object calls to the fake
+func newFakeStore(fake *apifakes.FakeS3ObjectAPI, limits ctrlapi.Limits, limiters *Limiters) *s3ObjectStore {
+	return &s3ObjectStore{
+		config:   ctrlapi.ConfigData{Secret: []byte(testSecret), Region: "eu-west-1", Limits: limits},
+		limiters: limiters,
+		newClient: func(aws.Config) s3API {
+			return struct {
+				ctrlapi.S3ObjectAPI
+				ctrlapi.S3BucketAPI
+			}{S3ObjectAPI: fake}
+		},
+	}
+}
+
+var _ = Describe("Rate limiting", func() {
+	var (
+		ctx    context.Context
+		fake   *apifakes.FakeS3ObjectAPI
+		target cloudobject.ObjectTarget
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = &apifakes.FakeS3ObjectAPI{}
+		fake.PutObjectReturns(&s3.PutObjectOutput{}, nil)
+		target = cloudobject.ObjectTarget{Bucket: "test-bucket", Key: "key", Region: "eu-west-1"}
+	})
+
+	It("should pace the calls to a bucket", func() {
+		store := newFakeStore(fake, ctrlapi.Limits{RequestsPerSecond: 20, Burst: 1}, NewLimiters())
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			_, err := store.Store(ctx, []byte("data"), target, nil)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 190*time.Millisecond))
+		Expect(fake.PutObjectCallCount()).To(Equal(5))
+	})
+
+	It("should share the limiter of a bucket between stores with the same credentials", func() {
+		limits := ctrlapi.Limits{RequestsPerSecond: 1, Burst: 1}
+		limiters := NewLimiters()
+
+		_, err := newFakeStore(fake, limits, limiters).Store(ctx, []byte("data"), target, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err = newFakeStore(fake, limits, limiters).Store(waitCtx, []byte("data"), target, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(fake.PutObjectCallCount()).To(Equal(1))
+
+		target.Bucket = "other-bucket"
+		_, err = newFakeStore(fake, limits, limiters).Store(ctx, []byte("data"), target, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.PutObjectCallCount()).To(Equal(2))
+	})
+
+	It("should not limit the calls without a request rate", func() {
+		store := newFakeStore(fake, ctrlapi.Limits{}, NewLimiters())
+		Expect(store.limiters.get(store.config, target.Bucket)).To(BeNil())
+
+		_, err := store.Store(ctx, []byte("data"), target, nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should lower the rate while throttled in adaptive mode and recover after", func() {
+		limits := ctrlapi.Limits{RequestsPerSecond: 100, Burst: 10, RetryMode: RetryModeAdaptive}
+		store := newFakeStore(fake, limits, NewLimiters())
+		limiter := store.limiters.get(store.config, target.Bucket)
+
+		fake.PutObjectReturns(nil, &smithy.GenericAPIError{Code: "SlowDown", Message: "Please reduce your request rate."})
+		_, err := store.Store(ctx, []byte("data"), target, nil)
+		Expect(ctrlapi.ReasonOf(err)).To(Equal(ctrlapi.ReasonThrottled))
+		Expect(limiter.Limit()).To(Equal(rate.Limit(50)))
+
+		_, err = store.Store(ctx, []byte("data"), target, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(limiter.Limit()).To(Equal(rate.Limit(25)))
+
+		fake.PutObjectReturns(&s3.PutObjectOutput{}, nil)
+		_, err = store.Store(ctx, []byte("data"), target, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limiter.Limit()).To(BeNumerically("~", 27.5, 0.01))
+
+		for i := 0; i < 20; i++ {
+			_, err = store.Store(ctx, []byte("data"), target, nil)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(limiter.Limit()).To(Equal(rate.Limit(100)))
+	})
+
+	It("should keep the rate while throttled in standard mode", func() {
+		store := newFakeStore(fake, ctrlapi.Limits{RequestsPerSecond: 100, Burst: 10}, NewLimiters())
+
+		fake.PutObjectReturns(nil, &smithy.GenericAPIError{Code: "SlowDown"})
+		_, err := store.Store(ctx, []byte("data"), target, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(store.limiters.get(store.config, target.Bucket).Limit()).To(Equal(rate.Limit(100)))
+	})
+})
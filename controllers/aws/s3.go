@@ -19,45 +19,221 @@ package aws
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 
-	cloudobject "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
 	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/pkg/errors"
 )
 
+// s3API is the subset of the S3 client used by the object store
+type s3API interface {
+	ctrlapi.S3ObjectAPI
+	ctrlapi.S3BucketAPI
+}
+
 type s3ObjectStore struct {
-	config ctrlapi.ConfigData
+	config    ctrlapi.ConfigData
+	limiters  *Limiters
+	newClient func(aws.Config) s3API
 }
 
-func NewS3ObjectStore(config ctrlapi.ConfigData) ctrlapi.ObjectStore {
+// NewS3ObjectStore returns an object store for the given credentials and
+// region, pacing its calls with the limiter of each bucket
+func NewS3ObjectStore(config ctrlapi.ConfigData, limiters *Limiters) ctrlapi.ObjectStore {
 	return &s3ObjectStore{
-		config: config,
+		config:   config,
+		limiters: limiters,
+		newClient: func(cfg aws.Config) s3API {
+			return s3.NewFromConfig(cfg)
+		},
 	}
 }
 
-func (s *s3ObjectStore) Store(ctx context.Context, content []byte, target cloudobject.ObjectTarget) error {
-	cfg, err := useProviderSecret(ctx, s.config.Secret, s.config.Region, defaultProfile)
+func (s *s3ObjectStore) client(ctx context.Context) (s3API, error) {
+	cfg, err := useProviderSecret(ctx, s.config.Secret, s.config.Region, defaultProfile, clientOptions(s.config, s.limiters.retryer(s.config))...)
 	if err != nil {
+		return nil, err
+	}
+	return s.newClient(*cfg), nil
+}
+
+// call waits for the limiter of the bucket before calling fn and adapts the
+// limiter to the outcome
+func (s *s3ObjectStore) call(ctx context.Context, bucket string, fn func() error) error {
+	limiter := s.limiters.get(s.config, bucket)
+	if err := limiter.wait(ctx); err != nil {
 		return err
 	}
+	err := fn()
+	limiter.observe(err)
+	return err
+}
+
+func (s *s3ObjectStore) Store(ctx context.Context, content []byte, target cloudobject.ObjectTarget, metadata map[string]string) (ctrlapi.ObjectInfo, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, err
+	}
 
 	input := &s3.PutObjectInput{
+		Bucket:   &target.Bucket,
+		Key:      &target.Key,
+		Body:     bytes.NewReader(content),
+		Metadata: metadata,
+	}
+
+	var output *s3.PutObjectOutput
+	err = s.call(ctx, target.Bucket, func() (err error) {
+		if output, err = ctrlapi.PutItem(ctx, client, input); err != nil {
+			return classify(objectOperation("s3:PutObject", target), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, err
+	}
+
+	return ctrlapi.ObjectInfo{
+		ETag:      StringValue(output.ETag),
+		VersionID: StringValue(output.VersionId),
+		Metadata:  metadata,
+	}, nil
+}
+
+// Head retrieves the metadata of the object, returning ctrlapi.ErrNotFound
+// if it does not exist
+func (s *s3ObjectStore) Head(ctx context.Context, target cloudobject.ObjectTarget) (ctrlapi.ObjectInfo, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, err
+	}
+
+	input := &s3.HeadObjectInput{
 		Bucket: &target.Bucket,
 		Key:    &target.Key,
-		Body:   bytes.NewReader(content),
 	}
 
-	client := s3.NewFromConfig(*cfg)
-	_, err = ctrlapi.PutItem(ctx, client, input)
+	var output *s3.HeadObjectOutput
+	err = s.call(ctx, target.Bucket, func() (err error) {
+		if output, err = ctrlapi.HeadItem(ctx, client, input); err != nil {
+			if isNotFound(err) {
+				return ctrlapi.ErrNotFound
+			}
+			return classify(objectOperation("s3:HeadObject", target), err)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return ctrlapi.ObjectInfo{}, err
+	}
+
+	return ctrlapi.ObjectInfo{
+		ETag:      StringValue(output.ETag),
+		VersionID: StringValue(output.VersionId),
+		Metadata:  output.Metadata,
+		Size:      output.ContentLength,
+	}, nil
+}
+
+// Get downloads a version of the object, returning ctrlapi.ErrNotFound if it
+// does not exist
+func (s *s3ObjectStore) Get(ctx context.Context, target cloudobject.ObjectTarget, versionID string) ([]byte, ctrlapi.ObjectInfo, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, ctrlapi.ObjectInfo{}, err
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: &target.Bucket,
+		Key:    &target.Key,
+	}
+	if versionID != "" {
+		input.VersionId = &versionID
 	}
 
-	return nil
+	var output *s3.GetObjectOutput
+	err = s.call(ctx, target.Bucket, func() (err error) {
+		if output, err = ctrlapi.GetItem(ctx, client, input); err != nil {
+			if isNotFound(err) {
+				return ctrlapi.ErrNotFound
+			}
+			return classify(objectOperation("s3:GetObject", target), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, ctrlapi.ObjectInfo{}, err
+	}
+	defer output.Body.Close()
+
+	content, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return nil, ctrlapi.ObjectInfo{}, classify(objectOperation("s3:GetObject", target), err)
+	}
+
+	return content, ctrlapi.ObjectInfo{
+		ETag:      StringValue(output.ETag),
+		VersionID: StringValue(output.VersionId),
+		Metadata:  output.Metadata,
+	}, nil
+}
+
+// Copy copies the source object into the target object server side,
+// replacing the metadata of the copy. The call is paced by the limiter of the
+// target bucket.
+func (s *s3ObjectStore) Copy(ctx context.Context, source, target cloudobject.ObjectTarget, metadata map[string]string) (ctrlapi.ObjectInfo, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, err
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:            &target.Bucket,
+		Key:               &target.Key,
+		CopySource:        aws.String(copySource(source)),
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	}
+
+	var output *s3.CopyObjectOutput
+	err = s.call(ctx, target.Bucket, func() (err error) {
+		if output, err = ctrlapi.CopyItem(ctx, client, input); err != nil {
+			if isNotFound(err) {
+				return ctrlapi.ErrNotFound
+			}
+			return classify(objectOperation("s3:CopyObject", target), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, err
+	}
+
+	info := ctrlapi.ObjectInfo{
+		VersionID: StringValue(output.VersionId),
+		Metadata:  metadata,
+	}
+	if output.CopyObjectResult != nil {
+		info.ETag = StringValue(output.CopyObjectResult.ETag)
+	}
+	return info, nil
 }
 
 func (s *s3ObjectStore) Delete(ctx context.Context, target cloudobject.ObjectTarget) error {
-	cfg, err := useProviderSecret(ctx, s.config.Secret, s.config.Region, defaultProfile)
+	return s.DeleteVersion(ctx, target, "")
+}
+
+// DeleteVersion removes a specific version of the object, or the current
+// version if versionID is empty
+func (s *s3ObjectStore) DeleteVersion(ctx context.Context, target cloudobject.ObjectTarget, versionID string) error {
+	client, err := s.client(ctx)
 	if err != nil {
 		return err
 	}
@@ -66,11 +242,37 @@ func (s *s3ObjectStore) Delete(ctx context.Context, target cloudobject.ObjectTar
 		Bucket: &target.Bucket,
 		Key:    &target.Key,
 	}
-
-	client := s3.NewFromConfig(*cfg)
-	if _, err = ctrlapi.DeleteItem(ctx, client, input); err != nil {
-		return err
+	if versionID != "" {
+		input.VersionId = &versionID
 	}
 
-	return nil
+	return s.call(ctx, target.Bucket, func() error {
+		if _, err := ctrlapi.DeleteItem(ctx, client, input); err != nil {
+			return classify(objectOperation("s3:DeleteObject", target), err)
+		}
+		return nil
+	})
+}
+
+// objectOperation describes an operation on the target object in errors
+func objectOperation(operation string, target cloudobject.ObjectTarget) string {
+	return fmt.Sprintf("%s on s3://%s/%s", operation, target.Bucket, target.Key)
+}
+
+// copySource returns the URL encoded bucket and key of the source of a copy
+func copySource(source cloudobject.ObjectTarget) string {
+	return source.Bucket + "/" + url.PathEscape(source.Key)
+}
+
+// isNotFound reports whether err is the response to a missing object
+func isNotFound(err error) bool {
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	return httpStatus(err) == http.StatusNotFound
 }
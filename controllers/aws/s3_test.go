@@ -0,0 +1,111 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"dev.nimak.link/s3-copy-controller/controllers/api/apifakes"
+)
+
+var _ = Describe("Object download", func() {
+	var (
+		fake   *apifakes.FakeS3ObjectAPI
+		store  *s3ObjectStore
+		target cloudobject.ObjectTarget
+	)
+
+	BeforeEach(func() {
+		fake = &apifakes.FakeS3ObjectAPI{}
+		store = newFakeStore(fake, ctrlapi.Limits{}, NewLimiters())
+		target = cloudobject.ObjectTarget{Bucket: "test-bucket", Key: "key", Region: "eu-west-1"}
+	})
+
+	It("should download a version of the object", func() {
+		fake.GetObjectReturns(&s3.GetObjectOutput{
+			Body:      ioutil.NopCloser(strings.NewReader("content")),
+			ETag:      aws.String("etag"),
+			VersionId: aws.String("v1"),
+		}, nil)
+
+		content, info, err := store.Get(context.Background(), target, "v1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("content"))
+		Expect(info).To(Equal(ctrlapi.ObjectInfo{ETag: "etag", VersionID: "v1"}))
+
+		_, input, _ := fake.GetObjectArgsForCall(0)
+		Expect(aws.ToString(input.VersionId)).To(Equal("v1"))
+	})
+
+	It("should report a missing object as not found", func() {
+		fake.GetObjectReturns(nil, &types.NoSuchKey{})
+
+		_, _, err := store.Get(context.Background(), target, "")
+		Expect(err).To(MatchError(ctrlapi.ErrNotFound))
+	})
+})
+
+var _ = Describe("Object copy", func() {
+	var (
+		fake   *apifakes.FakeS3ObjectAPI
+		store  *s3ObjectStore
+		source cloudobject.ObjectTarget
+		target cloudobject.ObjectTarget
+	)
+
+	BeforeEach(func() {
+		fake = &apifakes.FakeS3ObjectAPI{}
+		store = newFakeStore(fake, ctrlapi.Limits{}, NewLimiters())
+		source = cloudobject.ObjectTarget{Bucket: "source-bucket", Key: "reports/q1 summary.csv", Region: "us-east-1"}
+		target = cloudobject.ObjectTarget{Bucket: "test-bucket", Key: "key", Region: "eu-west-1"}
+	})
+
+	It("should copy the source object replacing its metadata", func() {
+		fake.CopyObjectReturns(&s3.CopyObjectOutput{
+			CopyObjectResult: &types.CopyObjectResult{ETag: aws.String("etag")},
+			VersionId:        aws.String("v1"),
+		}, nil)
+		metadata := map[string]string{"owner": "default/object"}
+
+		info, err := store.Copy(context.Background(), source, target, metadata)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info).To(Equal(ctrlapi.ObjectInfo{ETag: "etag", VersionID: "v1", Metadata: metadata}))
+
+		_, input, _ := fake.CopyObjectArgsForCall(0)
+		Expect(aws.ToString(input.CopySource)).To(Equal("source-bucket/reports%2Fq1%20summary.csv"))
+		Expect(aws.ToString(input.Bucket)).To(Equal("test-bucket"))
+		Expect(input.MetadataDirective).To(Equal(types.MetadataDirectiveReplace))
+		Expect(input.Metadata).To(Equal(metadata))
+	})
+
+	It("should report a missing source object as not found", func() {
+		fake.CopyObjectReturns(nil, &types.NoSuchKey{})
+
+		_, err := store.Copy(context.Background(), source, target, nil)
+		Expect(err).To(MatchError(ctrlapi.ErrNotFound))
+	})
+})
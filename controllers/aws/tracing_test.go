@@ -0,0 +1,97 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// newTestClient returns an S3 client answering every request with the
+// given status code and body instead of calling AWS
+func newTestClient(status int, body string) *s3.Client {
+	return s3.New(s3.Options{
+		Region:      "us-west-2",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		Retryer:     aws.NopRetryer{},
+		HTTPClient: smithyhttp.ClientDoFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"X-Amz-Request-Id": []string{"test-request"}},
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    r,
+			}, nil
+		}),
+		APIOptions: []func(*middleware.Stack) error{addTracingMiddleware},
+	})
+}
+
+var _ = Describe("Tracing middleware", func() {
+	var exporter *tracetest.InMemoryExporter
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	})
+
+	It("should record a span for a successful call", func() {
+		client := newTestClient(http.StatusOK, "")
+		_, err := client.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String("test-bucket"),
+			Key:    aws.String("test.key"),
+			Body:   bytes.NewReader([]byte("test-data")),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("S3.PutObject"))
+		Expect(spans[0].Attributes).To(ContainElement(semconv.HTTPStatusCodeKey.Int(http.StatusOK)))
+		Expect(spans[0].Status.Code).To(Equal(codes.Unset))
+	})
+
+	It("should mark the span as failed for a failed call", func() {
+		client := newTestClient(http.StatusForbidden,
+			`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		_, err := client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+			Bucket: aws.String("test-bucket"),
+			Key:    aws.String("test.key"),
+		})
+		Expect(err).To(HaveOccurred())
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("S3.DeleteObject"))
+		Expect(spans[0].Status.Code).To(Equal(codes.Error))
+	})
+})
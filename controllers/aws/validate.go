@@ -0,0 +1,97 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/pkg/errors"
+)
+
+// ValidateAccess checks the credentials with STS, unless only a custom S3
+// endpoint is configured, and probes the access to the bucket, before any
+// data is sent to it
+func ValidateAccess(ctx context.Context, config ctrlapi.ConfigData, bucket string) error {
+	cfg, err := useProviderSecret(ctx, config.Secret, config.Region, defaultProfile, clientOptions(config, nil)...)
+	if err != nil {
+		return err
+	}
+
+	var identity ctrlapi.STSAPI
+	if config.Endpoints.S3 == "" || config.Endpoints.STS != "" {
+		// S3 compatible stores rarely offer STS, the credentials of a custom
+		// S3 endpoint are only checked with STS if it is configured too
+		identity = sts.NewFromConfig(*cfg)
+	}
+	return validateAccess(ctx, identity, s3.NewFromConfig(*cfg), bucket)
+}
+
+// validateAccess checks the credentials with identity, if not nil, and
+// probes the bucket
+func validateAccess(ctx context.Context, identity ctrlapi.STSAPI, buckets ctrlapi.S3BucketAPI, bucket string) error {
+	principal := ""
+	if identity != nil {
+		caller, err := ctrlapi.CallerIdentity(ctx, identity, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			var classified *ctrlapi.Error
+			if errors.As(classify("sts:GetCallerIdentity", err), &classified) {
+				switch classified.Reason {
+				case ctrlapi.ReasonUnknown, ctrlapi.ReasonAccessDenied:
+					// STS answers any valid credentials
+					classified.Reason = ctrlapi.ReasonInvalidCredentials
+				}
+			}
+			return classified
+		}
+		principal = " to " + StringValue(caller.Arn)
+	}
+
+	_, err := ctrlapi.ProbeBucket(ctx, buckets, &s3.HeadBucketInput{Bucket: &bucket})
+	switch {
+	case err == nil:
+		return nil
+	case httpStatus(err) == http.StatusNotFound:
+		return &ctrlapi.Error{
+			Reason:  ctrlapi.ReasonBucketNotFound,
+			Message: fmt.Sprintf("bucket %s not found", bucket),
+			Err:     err,
+		}
+	case httpStatus(err) == http.StatusForbidden:
+		return &ctrlapi.Error{
+			Reason:  ctrlapi.ReasonAccessDenied,
+			Message: fmt.Sprintf("access denied on s3:ListBucket for bucket %s%s", bucket, principal),
+			Err:     err,
+		}
+	default:
+		return classify(fmt.Sprintf("s3:HeadBucket on bucket %s", bucket), err)
+	}
+}
+
+// httpStatus returns the HTTP status code of the response to a failed call
+func httpStatus(err error) int {
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+type fakeIdentity struct {
+	arn string
+	err error
+}
+
+func (f fakeIdentity) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &sts.GetCallerIdentityOutput{Arn: aws.String(f.arn)}, nil
+}
+
+var _ = Describe("Access validation", func() {
+	identity := fakeIdentity{arn: "arn:aws:iam::123456789012:user/test"}
+
+	It("should accept credentials with access to the bucket", func() {
+		err := validateAccess(context.Background(), identity, newTestClient(http.StatusOK, ""), "test-bucket")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject credentials refused by STS", func() {
+		err := validateAccess(context.Background(), fakeIdentity{err: errors.New("expired token")}, newTestClient(http.StatusOK, ""), "test-bucket")
+		Expect(errors.Is(err, ctrlapi.ErrInvalidCredentials)).To(BeTrue())
+	})
+
+	It("should name the denied permission and bucket", func() {
+		err := validateAccess(context.Background(), identity, newTestClient(http.StatusForbidden, ""), "test-bucket")
+		Expect(errors.Is(err, ctrlapi.ErrAccessDenied)).To(BeTrue())
+		Expect(err.Error()).To(Equal("access denied on s3:ListBucket for bucket test-bucket to arn:aws:iam::123456789012:user/test"))
+	})
+
+	It("should report a missing bucket", func() {
+		err := validateAccess(context.Background(), identity, newTestClient(http.StatusNotFound, ""), "test-bucket")
+		Expect(errors.Is(err, ctrlapi.ErrBucketNotFound)).To(BeTrue())
+	})
+	It("should only probe the bucket without an identity", func() {
+		err := validateAccess(context.Background(), nil, newTestClient(http.StatusOK, ""), "test-bucket")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = validateAccess(context.Background(), nil, newTestClient(http.StatusForbidden, ""), "test-bucket")
+		Expect(errors.Is(err, ctrlapi.ErrAccessDenied)).To(BeTrue())
+		Expect(err.Error()).To(Equal("access denied on s3:ListBucket for bucket test-bucket"))
+	})
+})
@@ -0,0 +1,213 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backup writes and reads the archives of namespace backups, a
+// tar.gz of YAML manifests listed in an index
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// IndexFile is the path of the index in the archive
+	IndexFile = "index.yaml"
+
+	// SecretKind is the kind of the resources encrypted in the archive
+	SecretKind = "Secret"
+
+	manifestsDir = "resources"
+)
+
+// An Index lists the resources of an archive, in the order they are restored
+type Index struct {
+	// namespace the resources were read from
+	Namespace string `json:"namespace"`
+	// time the backup was taken
+	Timestamp metav1.Time `json:"timestamp"`
+	// resources of the archive
+	Resources []Entry `json:"resources"`
+}
+
+// An Entry refers to the manifest of a resource in the archive
+type Entry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// path of the manifest in the archive
+	Path string `json:"path"`
+	// whether the manifest is encrypted
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+// Clean removes the status and the metadata populated by the API server from
+// resource, so that it only changes with the resource spec and can be applied
+// again
+func Clean(resource *unstructured.Unstructured) {
+	for _, field := range [][]string{
+		{"status"},
+		{"metadata", "managedFields"},
+		{"metadata", "resourceVersion"},
+		{"metadata", "uid"},
+		{"metadata", "generation"},
+		{"metadata", "creationTimestamp"},
+		{"metadata", "selfLink"},
+	} {
+		unstructured.RemoveNestedField(resource.Object, field...)
+	}
+}
+
+// Write returns the archive of resources read from namespace, encrypting the
+// manifests of Secrets with key unless it is empty. Owner references and
+// cluster IPs are removed, as they would not match in the restored namespace.
+func Write(namespace string, timestamp time.Time, resources []unstructured.Unstructured, key []byte) ([]byte, error) {
+	index := Index{Namespace: namespace, Timestamp: metav1.NewTime(timestamp)}
+	manifests := make([][]byte, 0, len(resources))
+	for i := range resources {
+		resource := resources[i].DeepCopy()
+		Clean(resource)
+		resource.SetNamespace("")
+		resource.SetOwnerReferences(nil)
+		if resource.GetKind() == "Service" {
+			unstructured.RemoveNestedField(resource.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(resource.Object, "spec", "clusterIPs")
+		}
+
+		manifest, err := yaml.Marshal(resource.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to marshal %s %s", resource.GetKind(), resource.GetName())
+		}
+		entry := Entry{
+			APIVersion: resource.GetAPIVersion(),
+			Kind:       resource.GetKind(),
+			Name:       resource.GetName(),
+			Path:       fmt.Sprintf("%s/%s/%s/%s.yaml", manifestsDir, resource.GetAPIVersion(), resource.GetKind(), resource.GetName()),
+		}
+		if entry.Kind == SecretKind && len(key) > 0 {
+			if manifest, err = encrypt(key, manifest); err != nil {
+				return nil, err
+			}
+			entry.Path += ".enc"
+			entry.Encrypted = true
+		}
+		index.Resources = append(index.Resources, entry)
+		manifests = append(manifests, manifest)
+	}
+
+	indexData, err := yaml.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := append([]string{IndexFile}, paths(index)...)
+	for i, content := range append([][]byte{indexData}, manifests...) {
+		header := &tar.Header{
+			Name:    files[i],
+			Mode:    0600,
+			Size:    int64(len(content)),
+			ModTime: timestamp,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Read returns the index and the resources of an archive in the order of the
+// index, decrypting encrypted manifests with key
+func Read(data []byte, key []byte) (*Index, []unstructured.Unstructured, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid archive")
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid archive")
+		}
+		if files[header.Name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	indexData, ok := files[IndexFile]
+	if !ok {
+		return nil, nil, errors.Errorf("no %s in archive", IndexFile)
+	}
+	var index Index
+	if err := yaml.Unmarshal(indexData, &index); err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid %s", IndexFile)
+	}
+
+	resources := make([]unstructured.Unstructured, 0, len(index.Resources))
+	for _, entry := range index.Resources {
+		manifest, ok := files[entry.Path]
+		if !ok {
+			return nil, nil, errors.Errorf("%s listed in the index is missing from the archive", entry.Path)
+		}
+		if entry.Encrypted {
+			if len(key) == 0 {
+				return nil, nil, errors.Errorf("%s is encrypted, a key is required", entry.Path)
+			}
+			if manifest, err = decrypt(key, manifest); err != nil {
+				return nil, nil, errors.Wrapf(err, "unable to decrypt %s", entry.Path)
+			}
+		}
+		var resource unstructured.Unstructured
+		if err := yaml.Unmarshal(manifest, &resource.Object); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid manifest %s", entry.Path)
+		}
+		resources = append(resources, resource)
+	}
+	return &index, resources, nil
+}
+
+func paths(index Index) []string {
+	paths := make([]string, 0, len(index.Resources))
+	for _, entry := range index.Resources {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
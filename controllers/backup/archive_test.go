@@ -0,0 +1,117 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Archive", func() {
+	var (
+		key       []byte
+		timestamp time.Time
+		resources []unstructured.Unstructured
+	)
+
+	BeforeEach(func() {
+		key = bytes.Repeat([]byte("k"), KeySize)
+		timestamp = time.Date(2021, 12, 1, 2, 0, 0, 0, time.UTC)
+		resources = []unstructured.Unstructured{
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata":   map[string]interface{}{"name": "db", "namespace": "team-a", "uid": "1234"},
+				"data":       map[string]interface{}{"password": "c2VjcmV0"},
+			}},
+			{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata": map[string]interface{}{
+					"name":            "web",
+					"namespace":       "team-a",
+					"resourceVersion": "42",
+					"ownerReferences": []interface{}{map[string]interface{}{"kind": "Object", "name": "owner"}},
+				},
+				"spec":   map[string]interface{}{"clusterIP": "10.0.0.1", "ports": []interface{}{map[string]interface{}{"port": int64(80)}}},
+				"status": map[string]interface{}{"loadBalancer": map[string]interface{}{}},
+			}},
+		}
+	})
+
+	It("should round trip resources through an index", func() {
+		data, err := Write("team-a", timestamp, resources, key)
+		Expect(err).NotTo(HaveOccurred())
+
+		index, restored, err := Read(data, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index.Namespace).To(Equal("team-a"))
+		Expect(index.Timestamp.Time.Equal(timestamp)).To(BeTrue())
+		Expect(index.Resources).To(Equal([]Entry{
+			{APIVersion: "v1", Kind: "Secret", Name: "db", Path: "resources/v1/Secret/db.yaml.enc", Encrypted: true},
+			{APIVersion: "v1", Kind: "Service", Name: "web", Path: "resources/v1/Service/web.yaml"},
+		}))
+
+		Expect(restored).To(HaveLen(2))
+		Expect(restored[0].GetName()).To(Equal("db"))
+		Expect(restored[0].GetNamespace()).To(BeEmpty())
+		Expect(restored[0].GetUID()).To(BeEmpty())
+		Expect(restored[0].Object["data"]).To(HaveKeyWithValue("password", "c2VjcmV0"))
+
+		Expect(restored[1].GetResourceVersion()).To(BeEmpty())
+		Expect(restored[1].GetOwnerReferences()).To(BeEmpty())
+		Expect(restored[1].Object).NotTo(HaveKey("status"))
+		Expect(restored[1].Object["spec"]).NotTo(HaveKey("clusterIP"))
+		Expect(restored[1].Object["spec"]).To(HaveKey("ports"))
+
+		// the resources written are left untouched
+		Expect(resources[1].GetResourceVersion()).To(Equal("42"))
+	})
+
+	It("should only store secrets in plain text without a key", func() {
+		data, err := Write("team-a", timestamp, resources, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		index, restored, err := Read(data, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index.Resources[0].Encrypted).To(BeFalse())
+		Expect(restored[0].Object["data"]).To(HaveKeyWithValue("password", "c2VjcmV0"))
+	})
+
+	It("should require the key of encrypted secrets", func() {
+		data, err := Write("team-a", timestamp, resources, key)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = Read(data, nil)
+		Expect(err).To(MatchError(ContainSubstring("a key is required")))
+
+		_, _, err = Read(data, bytes.Repeat([]byte("x"), KeySize))
+		Expect(err).To(MatchError(ContainSubstring("unable to decrypt")))
+	})
+
+	It("should reject invalid keys and archives", func() {
+		_, err := Write("team-a", timestamp, resources, []byte("short"))
+		Expect(err).To(MatchError(ContainSubstring("encryption key must be 32 bytes")))
+
+		_, _, err = Read([]byte("not an archive"), nil)
+		Expect(err).To(MatchError(ContainSubstring("invalid archive")))
+	})
+})
@@ -0,0 +1,85 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+// ClusterObjectKind is the kind of cluster-scoped objects
+const ClusterObjectKind = "ClusterObject"
+
+// ClusterObjectReconciler reconciles a ClusterObject object, sharing the
+// settings and the reconciliation of the ObjectReconciler. The
+// ObjectReconciler must be set up with the manager as well, as it indexes the
+// targets and template inputs of both kinds.
+type ClusterObjectReconciler struct {
+	*ObjectReconciler
+}
+
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=clusterobjects,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=clusterobjects/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=clusterobjects/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get
+
+func (r *ClusterObjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcile(ctx, req, &cloudobject.ClusterObject{})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterObjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		For(&cloudobject.ClusterObject{}).
+		Watches(&source.Kind{Type: &cloudobject.ClusterObject{}}, handler.EnqueueRequestsFromMapFunc(r.clusterObjectsWithSameTarget)).
+		Watches(&source.Kind{Type: &cloudobject.Object{}}, handler.EnqueueRequestsFromMapFunc(r.clusterObjectsWithSameTarget)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.clusterObjectsWithConfigMapInput)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.clusterObjectsWithSecretInput)).
+		WithEventFilter(predicate.NewPredicateFuncs(r.Scope.Contains)).
+		Complete(r)
+}
+
+// clusterObjectsWithSameTarget enqueues the other cluster objects storing
+// into the same target, so that they can claim it once the current owner goes
+// away
+func (r *ClusterObjectReconciler) clusterObjectsWithSameTarget(o client.Object) []reconcile.Request {
+	return r.requestsForTarget(o, &cloudobject.ClusterObjectList{})
+}
+
+// clusterObjectsWithConfigMapInput enqueues the cluster objects whose template
+// reads the configmap o
+func (r *ClusterObjectReconciler) clusterObjectsWithConfigMapInput(o client.Object) []reconcile.Request {
+	return r.requestsForInput(templateInputIndexValue("ConfigMap", o.GetNamespace(), o.GetName()), &cloudobject.ClusterObjectList{})
+}
+
+// clusterObjectsWithSecretInput enqueues the cluster objects whose template
+// reads the secret o
+func (r *ClusterObjectReconciler) clusterObjectsWithSecretInput(o client.Object) []reconcile.Request {
+	return r.requestsForInput(templateInputIndexValue("Secret", o.GetNamespace(), o.GetName()), &cloudobject.ClusterObjectList{})
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+var _ = Describe("ClusterObject controller", func() {
+	const (
+		SecretName = "cluster-creds"
+		Namespace  = "default"
+
+		timeout  = time.Second * 30
+		interval = time.Millisecond * 250
+	)
+
+	// storedContent returns the content last stored under key
+	storedContent := func(key string) string {
+		for i := fakeObjectStore.StoreCallCount() - 1; i >= 0; i-- {
+			_, data, target, _ := fakeObjectStore.StoreArgsForCall(i)
+			if target.Key == key {
+				return string(data)
+			}
+		}
+		return ""
+	}
+
+	newClusterObject := func(name string, source cloudobj.ObjectSource) *cloudobj.ClusterObject {
+		return &cloudobj.ClusterObject{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: cloudobj.ClusterObjectSpec{
+				ObjectSpec: cloudobj.ObjectSpec{
+					DeletionPolicy: cloudobj.DeletionRetain,
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "cluster-bucket",
+						Key:    name + ".yaml",
+					},
+					Source: source,
+					Credentials: cloudobj.Credentials{
+						Source: cloudobj.CredentialsSecret,
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{Namespace: Namespace, Name: SecretName},
+							Key:             "creds-key",
+						},
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace},
+			Data:       map[string][]byte{"creds-key": []byte("c29tZS1kYXRh")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace}}
+		Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+	})
+
+	It("should store the manifest of a cluster-scoped resource", func() {
+		role := &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "backed-up-role"},
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+				Verbs:     []string{"get"},
+			}},
+		}
+		Expect(k8sClient.Create(ctx, role)).Should(Succeed())
+
+		obj := newClusterObject("cluster-role-backup", cloudobj.ObjectSource{
+			Type: cloudobj.SourceResource,
+			Resource: &cloudobj.ResourceSource{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "ClusterRole",
+				Name:       role.Name,
+			},
+		})
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() string {
+			return storedContent("cluster-role-backup.yaml")
+		}, timeout, interval).Should(And(
+			ContainSubstring("kind: ClusterRole"),
+			ContainSubstring("name: backed-up-role"),
+			ContainSubstring("- pods"),
+			Not(ContainSubstring("resourceVersion")),
+		))
+
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return false
+			}
+			return obj.Status.Synced
+		}, timeout, interval).Should(BeTrue())
+
+		_, _, _, metadata := fakeObjectStore.StoreArgsForCall(fakeObjectStore.StoreCallCount() - 1)
+		Expect(metadata).To(HaveKeyWithValue(OwnerMetadataKey, "ClusterObject/cluster-role-backup"))
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, role)).Should(Succeed())
+	})
+
+	It("should only read config maps from the allowed namespaces", func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-config", Namespace: Namespace},
+			Data:       map[string]string{"config.yaml": "setting: true"},
+		}
+		Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+
+		obj := newClusterObject("cluster-config-backup", cloudobj.ObjectSource{
+			Type:      cloudobj.SourceConfigMap,
+			ConfigMap: &cloudobj.ConfigMapSource{Namespace: Namespace, Name: cm.Name, Key: "config.yaml"},
+		})
+		obj.Spec.AllowedNamespaces = []string{Namespace}
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() string {
+			return storedContent("cluster-config-backup.yaml")
+		}, timeout, interval).Should(Equal("setting: true"))
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, cm)).Should(Succeed())
+	})
+
+	It("should refuse sources in namespaces that are not allowed", func() {
+		obj := newClusterObject("cluster-denied-backup", cloudobj.ObjectSource{
+			Type:      cloudobj.SourceConfigMap,
+			ConfigMap: &cloudobj.ConfigMapSource{Namespace: Namespace, Name: "cluster-config", Key: "config.yaml"},
+		})
+		err := k8sClient.Create(ctx, obj)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("a ConfigMap source must be in one of the allowed namespaces"))
+	})
+
+	It("should refuse resource sources on namespaced objects", func() {
+		obj := &cloudobj.Object{
+			ObjectMeta: metav1.ObjectMeta{Name: "resource-object", Namespace: Namespace},
+			Spec:       newClusterObject("resource-object", cloudobj.ObjectSource{}).Spec.ObjectSpec,
+		}
+		obj.Spec.Source = cloudobj.ObjectSource{
+			Type:     cloudobj.SourceResource,
+			Resource: &cloudobj.ResourceSource{APIVersion: "v1", Kind: "Namespace", Name: "default"},
+		}
+		err := k8sClient.Create(ctx, obj)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Resource sources are only supported by ClusterObject"))
+	})
+
+	It("should report a failure for a missing resource", func() {
+		obj := newClusterObject("cluster-missing-backup", cloudobj.ObjectSource{
+			Type:     cloudobj.SourceResource,
+			Resource: &cloudobj.ResourceSource{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "missing-role"},
+		})
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return false
+			}
+			return meta.IsStatusConditionFalse(obj.Status.Conditions, ConditionSynced)
+		}, timeout, interval).Should(BeTrue())
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+	})
+})
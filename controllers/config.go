@@ -0,0 +1,167 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	ctrlconfig "dev.nimak.link/s3-copy-controller/api/config/v1alpha1"
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	awshelper "dev.nimak.link/s3-copy-controller/controllers/aws"
+)
+
+const (
+	// FeatureCredentialValidation validates credentials and bucket access
+	// before storing an object
+	FeatureCredentialValidation = "CredentialValidation"
+	// FeatureBucketCreation lets objects create their missing bucket
+	FeatureBucketCreation = "BucketCreation"
+)
+
+// DefaultFeatures are the known features and whether they are enabled
+// without a feature gate
+var DefaultFeatures = map[string]bool{
+	FeatureCredentialValidation: true,
+	FeatureBucketCreation:       true,
+}
+
+// ValidateConfig checks the controller configuration, reporting every
+// invalid setting
+func ValidateConfig(config *ctrlconfig.ControllerConfig) error {
+	var errs []error
+
+	if _, err := ParseDeletionPolicy(config.DefaultDeletionPolicy); err != nil {
+		errs = append(errs, errors.Errorf("invalid defaultDeletionPolicy %s", config.DefaultDeletionPolicy))
+	}
+
+	for _, endpoint := range []string{config.Endpoints.S3, config.Endpoints.STS} {
+		if endpoint == "" {
+			continue
+		}
+		if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, errors.Errorf("invalid endpoint %s, expected an absolute URL", endpoint))
+		}
+	}
+
+	retry := config.Retry
+	switch retry.Mode {
+	case awshelper.RetryModeStandard, awshelper.RetryModeAdaptive:
+	default:
+		errs = append(errs, errors.Errorf("invalid retry mode %s", retry.Mode))
+	}
+	if retry.RequestsPerSecond < 0 || retry.Burst < 0 || retry.MaxAttempts < 0 {
+		errs = append(errs, errors.New("retry settings must not be negative"))
+	}
+	if config.MaxConcurrentReconciles < 0 {
+		errs = append(errs, errors.New("maxConcurrentReconciles must not be negative"))
+	}
+	if config.DeletionTimeout.Duration < 0 {
+		errs = append(errs, errors.New("deletionTimeout must not be negative"))
+	}
+
+	if _, err := ScopeFromConfig(config.Scope); err != nil {
+		errs = append(errs, errors.Wrap(err, "invalid scope"))
+	}
+
+	for feature := range config.FeatureGates {
+		if _, ok := DefaultFeatures[feature]; !ok {
+			errs = append(errs, errors.Errorf("unknown feature gate %s, known features are %s", feature, knownFeatures()))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// ParseDeletionPolicy returns the deletion policy named by policy, matched
+// case insensitively and ignoring dashes so that the v1alpha1 spelling
+// Orphan-On-Mismatch is still accepted
+func ParseDeletionPolicy(policy string) (cloudobject.DeletionPolicy, error) {
+	name := strings.ReplaceAll(policy, "-", "")
+	for _, known := range []cloudobject.DeletionPolicy{
+		cloudobject.DeletionDelete,
+		cloudobject.DeletionRetain,
+		cloudobject.DeletionOrphanOnMismatch,
+	} {
+		if strings.EqualFold(name, string(known)) {
+			return known, nil
+		}
+	}
+	return "", errors.Errorf("invalid deletion policy %s", policy)
+}
+
+// ScopeFromConfig returns the scope of the controller configuration
+func ScopeFromConfig(scope ctrlconfig.Scope) (Scope, error) {
+	return NewScope(scope.Namespaces, scope.ExcludedNamespaces, scope.ObjectSelector)
+}
+
+// Features returns whether every known feature is enabled, applying the
+// feature gates to the defaults
+func Features(gates map[string]bool) map[string]bool {
+	features := map[string]bool{}
+	for feature, enabled := range DefaultFeatures {
+		features[feature] = enabled
+	}
+	for feature, enabled := range gates {
+		features[feature] = enabled
+	}
+	return features
+}
+
+func knownFeatures() string {
+	var features []string
+	for feature := range DefaultFeatures {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return strings.Join(features, ", ")
+}
+
+// enabled reports whether the feature is enabled on the reconciler
+func (r *ObjectReconciler) enabled(feature string) bool {
+	if enabled, ok := r.Features[feature]; ok {
+		return enabled
+	}
+	return DefaultFeatures[feature]
+}
+
+// applyDefaults fills the settings left empty on the object with the
+// defaults of the controller
+func (r *ObjectReconciler) applyDefaults(obj cloudobject.Storable) error {
+	spec := obj.GetSpec()
+	return r.applyTargetDefaults(&spec.Target, &spec.DeletionPolicy)
+}
+
+// applyTargetDefaults fills the region and deletion policy left empty with
+// the defaults of the controller
+func (r *ObjectReconciler) applyTargetDefaults(target *cloudobject.ObjectTarget, policy *cloudobject.DeletionPolicy) error {
+	if target.Region == "" {
+		target.Region = r.DefaultRegion
+	}
+	if *policy == "" {
+		*policy = r.DefaultDeletionPolicy
+	}
+
+	if target.Region == "" {
+		return errors.Errorf("no region set on target %s and no default region configured", storeReference(*target))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+
+	ctrlconfig "dev.nimak.link/s3-copy-controller/api/config/v1alpha1"
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+var _ = Describe("Controller configuration", func() {
+	var config *ctrlconfig.ControllerConfig
+
+	BeforeEach(func() {
+		config = &ctrlconfig.ControllerConfig{
+			DefaultDeletionPolicy: "Retain",
+			Retry: ctrlconfig.RetryPolicy{
+				RequestsPerSecond: 50,
+				Burst:             100,
+				MaxAttempts:       3,
+				Mode:              "standard",
+			},
+			MaxConcurrentReconciles: 1,
+		}
+	})
+
+	It("should accept a valid configuration", func() {
+		config.Endpoints.S3 = "https://minio.example.com:9000"
+		config.Scope.Namespaces = []string{"team-a"}
+		config.FeatureGates = map[string]bool{FeatureBucketCreation: false}
+		Expect(ValidateConfig(config)).To(Succeed())
+	})
+
+	It("should report every invalid setting", func() {
+		config.DefaultDeletionPolicy = "Keep"
+		config.Endpoints.STS = "sts.example.com"
+		config.Retry.Mode = "fast"
+		config.DeletionTimeout = metav1.Duration{Duration: -time.Minute}
+		config.Scope.ObjectSelector = "tenant in (a"
+		config.FeatureGates = map[string]bool{"Unknown": true}
+
+		err := ValidateConfig(config)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(And(
+			ContainSubstring("invalid defaultDeletionPolicy Keep"),
+			ContainSubstring("invalid endpoint sts.example.com"),
+			ContainSubstring("invalid retry mode fast"),
+			ContainSubstring("deletionTimeout must not be negative"),
+			ContainSubstring("invalid scope"),
+			ContainSubstring("unknown feature gate Unknown"),
+		))
+	})
+
+	It("should accept the adaptive retry mode without a bucket rate limit", func() {
+		config.Retry.Mode = "adaptive"
+		config.Retry.RequestsPerSecond = 0
+		Expect(ValidateConfig(config)).To(Succeed())
+	})
+
+	It("should load the shipped configuration file", func() {
+		path := filepath.Join("..", "config", "manager", "controller_manager_config.yaml")
+		configScheme := runtime.NewScheme()
+		Expect(ctrlconfig.AddToScheme(configScheme)).To(Succeed())
+
+		By("loading it the way the manager does")
+		loaded := &ctrlconfig.ControllerConfig{}
+		options, err := ctrl.Options{Scheme: configScheme}.AndFrom(ctrl.ConfigFile().AtPath(path).OfKind(loaded))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options.LeaderElectionID).To(Equal("f13742af.dev.nimak.link"))
+		Expect(ValidateConfig(loaded)).To(Succeed())
+
+		By("only setting known fields")
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(yaml.UnmarshalStrict(data, &ctrlconfig.ControllerConfig{})).To(Succeed())
+
+		By("documenting every controller setting")
+		var fields map[string]interface{}
+		Expect(yaml.Unmarshal(data, &fields)).To(Succeed())
+		configType := reflect.TypeOf(ctrlconfig.ControllerConfig{})
+		for i := 0; i < configType.NumField(); i++ {
+			name := strings.Split(configType.Field(i).Tag.Get("json"), ",")[0]
+			if name == "" {
+				continue
+			}
+			Expect(fields).To(HaveKey(name))
+		}
+	})
+
+	It("should accept the v1alpha1 spelling of deletion policies", func() {
+		for _, policy := range []string{"OrphanOnMismatch", "Orphan-On-Mismatch", "orphan-on-mismatch"} {
+			Expect(ParseDeletionPolicy(policy)).To(Equal(cloudobj.DeletionOrphanOnMismatch))
+		}
+		Expect(ParseDeletionPolicy("delete")).To(Equal(cloudobj.DeletionDelete))
+		_, err := ParseDeletionPolicy("Keep")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should apply feature gates to the default features", func() {
+		features := Features(map[string]bool{FeatureCredentialValidation: false})
+		Expect(features).To(Equal(map[string]bool{
+			FeatureCredentialValidation: false,
+			FeatureBucketCreation:       true,
+		}))
+
+		r := &ObjectReconciler{Features: features}
+		Expect(r.enabled(FeatureCredentialValidation)).To(BeFalse())
+		Expect(r.enabled(FeatureBucketCreation)).To(BeTrue())
+		Expect((&ObjectReconciler{}).enabled(FeatureBucketCreation)).To(BeTrue())
+	})
+
+	It("should fill the region and deletion policy left empty on objects", func() {
+		r := &ObjectReconciler{DefaultRegion: "eu-west-1", DefaultDeletionPolicy: "Retain"}
+		obj := &cloudobj.Object{Spec: cloudobj.ObjectSpec{Target: cloudobj.ObjectTarget{Bucket: "test-bucket", Key: "key"}}}
+		Expect(r.applyDefaults(obj)).To(Succeed())
+		Expect(obj.Spec.Target.Region).To(Equal("eu-west-1"))
+		Expect(obj.Spec.DeletionPolicy).To(Equal(cloudobj.DeletionRetain))
+
+		obj.Spec.Target.Region = "us-west-2"
+		Expect(r.applyDefaults(obj)).To(Succeed())
+		Expect(obj.Spec.Target.Region).To(Equal("us-west-2"))
+
+		obj.Spec.Target.Region = ""
+		Expect((&ObjectReconciler{}).applyDefaults(obj)).NotTo(Succeed())
+	})
+})
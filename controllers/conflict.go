@@ -0,0 +1,219 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	// targetIndexKey indexes objects by the bucket and key they store into
+	targetIndexKey = ".spec.target.reference"
+
+	// OwnerMetadataKey is the user metadata on the stored object naming the
+	// Object that manages it
+	OwnerMetadataKey = "s3copy-owner"
+
+	ConditionConflict = "Conflict"
+	Conflict          = "Conflict"
+
+	// condition reasons
+	ReasonTargetInUse = "TargetInUse"
+	ReasonNotOwned    = "NotOwned"
+	ReasonModified    = "Modified"
+	ReasonNoConflict  = "NoConflict"
+)
+
+func targetIndexValue(target cloudobject.ObjectTarget) string {
+	return fmt.Sprintf("%s/%s", target.Bucket, target.Key)
+}
+
+func indexTarget(o client.Object) []string {
+	obj := o.(cloudobject.Storable)
+	return []string{targetIndexValue(obj.GetSpec().Target)}
+}
+
+// ownerID identifies the kind storing into the object store in the owner
+// metadata of stored objects
+func ownerID(obj client.Object) string {
+	// kinds start with an upper case letter, unlike namespaces
+	switch obj.(type) {
+	case *cloudobject.ScheduledObject:
+		return fmt.Sprintf("%s/%s", ScheduledObjectKind, client.ObjectKeyFromObject(obj))
+	case *cloudobject.NamespaceBackup:
+		return fmt.Sprintf("%s/%s", NamespaceBackupKind, client.ObjectKeyFromObject(obj))
+	}
+	if obj.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", ClusterObjectKind, obj.GetName())
+	}
+	return client.ObjectKeyFromObject(obj).String()
+}
+
+// ownerMetadata returns the metadata stamped on objects stored for obj
+func ownerMetadata(obj client.Object) map[string]string {
+	return map[string]string{OwnerMetadataKey: ownerID(obj)}
+}
+
+// olderThan orders objects by creation, breaking ties by namespace and name
+func olderThan(a, b cloudobject.Storable) bool {
+	aCreated, bCreated := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !aCreated.Equal(&bCreated) {
+		return aCreated.Before(&bCreated)
+	}
+	return ownerID(a) < ownerID(b)
+}
+
+// targetOwner returns the Object or ClusterObject that first claimed the
+// target of obj
+func (r *ObjectReconciler) targetOwner(ctx context.Context, obj cloudobject.Storable) (cloudobject.Storable, error) {
+	owner := obj
+	for _, list := range []client.ObjectList{&cloudobject.ObjectList{}, &cloudobject.ClusterObjectList{}} {
+		if err := r.List(ctx, list, client.MatchingFields{targetIndexKey: targetIndexValue(obj.GetSpec().Target)}); err != nil {
+			return nil, err
+		}
+		err := meta.EachListItem(list, func(item runtime.Object) error {
+			if other := item.(cloudobject.Storable); olderThan(other, owner) {
+				owner = other
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return owner, nil
+}
+
+// checkOwnership returns a non-empty reason and message if obj must not
+// modify its target, either because another Object claimed it first or
+// because the stored object is owned by another Object
+func (r *ObjectReconciler) checkOwnership(ctx context.Context, objectStore ctrlapi.ObjectStore, obj cloudobject.Storable) (string, string, error) {
+	owner, err := r.targetOwner(ctx, obj)
+	if err != nil {
+		return "", "", err
+	}
+	if owner.GetUID() != obj.GetUID() {
+		return ReasonTargetInUse, fmt.Sprintf("target %s is managed by %s", storeReference(obj.GetSpec().Target), ownerID(owner)), nil
+	}
+
+	info, err := objectStore.Head(ctx, obj.GetSpec().Target)
+	if errors.Is(err, ctrlapi.ErrNotFound) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if current, ok := info.Metadata[OwnerMetadataKey]; ok && current != ownerID(obj) {
+		return ReasonNotOwned, fmt.Sprintf("target %s is owned by %s", storeReference(obj.GetSpec().Target), current), nil
+	}
+	return "", "", nil
+}
+
+// verifyDeletion returns a non-empty reason and message if the stored object
+// must not be deleted, either because another Object manages the target or
+// because the stored object no longer matches what obj stored
+func (r *ObjectReconciler) verifyDeletion(ctx context.Context, objectStore ctrlapi.ObjectStore, obj cloudobject.Storable) (string, string, error) {
+	owner, err := r.targetOwner(ctx, obj)
+	if err != nil {
+		return "", "", err
+	}
+	if owner.GetUID() != obj.GetUID() {
+		return ReasonTargetInUse, fmt.Sprintf("target %s is managed by %s", storeReference(obj.GetSpec().Target), ownerID(owner)), nil
+	}
+
+	info, err := objectStore.Head(ctx, obj.GetSpec().Target)
+	if errors.Is(err, ctrlapi.ErrNotFound) {
+		// nothing left to protect
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if current := info.Metadata[OwnerMetadataKey]; current != ownerID(obj) {
+		if current == "" {
+			current = "an unknown writer"
+		}
+		return ReasonNotOwned, fmt.Sprintf("target %s is owned by %s", storeReference(obj.GetSpec().Target), current), nil
+	}
+	if obj.GetStatus().ETag != "" && info.ETag != obj.GetStatus().ETag {
+		return ReasonModified, fmt.Sprintf("target %s was modified since it was stored, etag %s does not match %s", storeReference(obj.GetSpec().Target), info.ETag, obj.GetStatus().ETag), nil
+	}
+	return "", "", nil
+}
+
+// reportConflict marks the object as conflicting instead of storing it
+func (r *ObjectReconciler) reportConflict(ctx context.Context, obj cloudobject.Storable, reason, msg string) error {
+	obj.GetStatus().Synced = false
+	obj.GetStatus().Reference = ""
+	setConflict(obj, metav1.ConditionTrue, reason, msg)
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return err
+	}
+	objectStates.set(client.ObjectKeyFromObject(obj), false)
+
+	r.Recorder.Event(obj, corev1.EventTypeWarning, Conflict, msg)
+	log.FromContext(ctx).Info("refusing to store object", "reason", reason, "message", msg)
+	return nil
+}
+
+func setConflict(obj cloudobject.Storable, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&obj.GetStatus().Conditions, metav1.Condition{
+		Type:               ConditionConflict,
+		Status:             status,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             reason,
+		Message:            msg,
+	})
+}
+
+// objectsWithSameTarget enqueues the other objects storing into the same
+// target, so that they can claim it once the current owner goes away
+func (r *ObjectReconciler) objectsWithSameTarget(o client.Object) []reconcile.Request {
+	return r.requestsForTarget(o, &cloudobject.ObjectList{})
+}
+
+// requestsForTarget enqueues the items of the list storing into the same
+// target as o, other than o itself
+func (r *ObjectReconciler) requestsForTarget(o client.Object, list client.ObjectList) []reconcile.Request {
+	obj := o.(cloudobject.Storable)
+
+	if err := r.List(context.Background(), list, client.MatchingFields{targetIndexKey: targetIndexValue(obj.GetSpec().Target)}); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	_ = meta.EachListItem(list, func(item runtime.Object) error {
+		if other := item.(client.Object); other.GetUID() != obj.GetUID() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(other)})
+		}
+		return nil
+	})
+	return requests
+}
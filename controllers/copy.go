@@ -0,0 +1,160 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"dev.nimak.link/s3-copy-controller/controllers/remote"
+)
+
+// A bucketCopy is the object of an S3 source to copy into a target
+type bucketCopy struct {
+	// store reading the source object
+	store ctrlapi.ObjectStore
+	// source object
+	source cloudobject.ObjectTarget
+	// info of the source object when it was last read
+	info ctrlapi.ObjectInfo
+	// serverSide is set when the target store may copy the source object
+	// itself, i.e. the source is read with the same credentials and endpoint
+	serverSide bool
+}
+
+// bucketSource returns the copy of the object referenced by src, read with the
+// credentials and in the region of the target unless src overrides them
+func (r *ObjectReconciler) bucketSource(ctx context.Context, creds cloudobject.Credentials, target cloudobject.ObjectTarget, src *cloudobject.S3Source) (*bucketCopy, error) {
+	source := cloudobject.ObjectTarget{Bucket: src.Bucket, Key: src.Key, Region: src.Region}
+	if source.Region == "" {
+		source.Region = target.Region
+	}
+	sameCredentials := src.Credentials == nil || *src.Credentials == creds
+	if src.Credentials != nil {
+		creds = *src.Credentials
+	}
+
+	secretData, err := PullCredentials(ctx, r, creds)
+	if err != nil {
+		credentialFailuresTotal.Inc()
+		return nil, err
+	}
+
+	endpoints := r.Endpoints
+	if src.Endpoint != "" {
+		endpoints.S3 = src.Endpoint
+	}
+	storeConfig := ctrlapi.ConfigData{
+		Secret:    secretData,
+		Region:    source.Region,
+		Limits:    r.limits(source),
+		Endpoints: endpoints,
+	}
+	return &bucketCopy{
+		store:      withTracing(withMetrics(r.StoreManager.Get(storeConfig), ProviderAWS)),
+		source:     source,
+		serverSide: sameCredentials && endpoints.S3 == r.Endpoints.S3,
+	}, nil
+}
+
+// head reads the info of the source object, returning remote.ErrNotModified
+// while its ETag equals revision
+func (c *bucketCopy) head(ctx context.Context, revision string) error {
+	info, err := c.store.Head(ctx, c.source)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read source object %s", storeReference(c.source))
+	}
+	c.info = info
+	if revision != "" && info.ETag == revision {
+		return remote.ErrNotModified
+	}
+	return nil
+}
+
+// download returns the content of the source object
+func (c *bucketCopy) download(ctx context.Context) ([]byte, error) {
+	data, info, err := c.store.Get(ctx, c.source, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to download source object %s", storeReference(c.source))
+	}
+	c.info = info
+	return data, nil
+}
+
+// readBucket checks the S3 source of obj against revision, returning
+// remote.ErrNotModified while its ETag is unchanged. The content is
+// downloaded unless the object store can copy the source object server side,
+// which is not used with history as the versions record their content, nor
+// with an output format as the content is converted.
+func (r *ObjectReconciler) readBucket(ctx context.Context, obj cloudobject.Storable, revision string) ([]byte, *bucketCopy, error) {
+	spec := obj.GetSpec()
+	if spec.Source.S3 == nil {
+		return nil, nil, errors.New("s3 required for an 'S3' source")
+	}
+	c, err := r.bucketSource(ctx, spec.Credentials, spec.Target, spec.Source.S3)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.head(ctx, revision); err != nil {
+		return nil, c, err
+	}
+	if c.serverSide && spec.History == nil && !converts(spec.Output) {
+		return nil, c, nil
+	}
+
+	c.serverSide = false
+	data, err := c.download(ctx)
+	return data, c, err
+}
+
+// copy copies the source object into the target of obj server side. When the
+// target credentials cannot read the source object or the target bucket is
+// missing, the source object is downloaded and stored instead, in which case
+// its content is returned.
+func (r *ObjectReconciler) copy(ctx context.Context, objectStore ctrlapi.ObjectStore, obj cloudobject.Storable, c *bucketCopy) (ctrlapi.ObjectInfo, []byte, error) {
+	target := obj.GetSpec().Target
+	info, err := objectStore.Copy(ctx, c.source, target, ownerMetadata(obj))
+	if !errors.Is(err, ctrlapi.ErrAccessDenied) && !errors.Is(err, ctrlapi.ErrBucketNotFound) {
+		return info, nil, err
+	}
+
+	log.FromContext(ctx).Info("unable to copy server side, downloading the source object",
+		"source", storeReference(c.source), "reason", ctrlapi.ReasonOf(err))
+	data, err := c.download(ctx)
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, nil, err
+	}
+	info, err = r.store(ctx, objectStore, obj, target, data)
+	return info, data, err
+}
+
+// downloadBucket returns the content of the S3 source src of a snapshot taken
+// with the given credentials into target
+func (r *ObjectReconciler) downloadBucket(ctx context.Context, creds cloudobject.Credentials, target cloudobject.ObjectTarget, src *cloudobject.S3Source) ([]byte, error) {
+	if src == nil {
+		return nil, errors.New("s3 required for an 'S3' source")
+	}
+	c, err := r.bucketSource(ctx, creds, target, src)
+	if err != nil {
+		return nil, err
+	}
+	return c.download(ctx)
+}
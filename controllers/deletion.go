@@ -0,0 +1,85 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+const (
+	// ForceDeleteAnnotation removes the finalizer of an object once deleting
+	// it failed ForceDeleteAttempts times
+	ForceDeleteAnnotation = "objstore.dev.nimak.link/force-delete"
+	ForceDeleteAttempts   = 3
+
+	Abandoned = "Abandoned"
+)
+
+// abandonDeletion records a failed deletion attempt and reports whether the
+// finalizer should be removed regardless, either because force-delete was
+// requested or because the deletion timeout expired
+func (r *ObjectReconciler) abandonDeletion(ctx context.Context, obj cloudobject.Storable, deleteErr error) (bool, error) {
+	obj.GetStatus().DeletionAttempts++
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return false, err
+	}
+
+	var why string
+	switch {
+	case forceDeleteRequested(obj) && obj.GetStatus().DeletionAttempts >= ForceDeleteAttempts:
+		why = fmt.Sprintf("force-delete requested after %d failed attempts", obj.GetStatus().DeletionAttempts)
+	case r.DeletionTimeout > 0 && time.Since(obj.GetDeletionTimestamp().Time) > r.DeletionTimeout:
+		why = fmt.Sprintf("deletion did not succeed within %s", r.DeletionTimeout)
+	default:
+		return false, nil
+	}
+
+	msg := fmt.Sprintf("removing finalizer, %s: %s; possibly left behind in the object store: %s",
+		why, deleteErr, strings.Join(leftBehind(obj), ", "))
+	r.Recorder.Event(obj, corev1.EventTypeWarning, Abandoned, msg)
+	log.FromContext(ctx).Info("abandoning deletion", "key", printReference(obj), "reason", why)
+	return true, nil
+}
+
+func forceDeleteRequested(obj cloudobject.Storable) bool {
+	force, err := strconv.ParseBool(obj.GetAnnotations()[ForceDeleteAnnotation])
+	return err == nil && force
+}
+
+// leftBehind lists the references of everything obj stored into the object store
+func leftBehind(obj cloudobject.Storable) []string {
+	refs := []string{storeReference(obj.GetSpec().Target)}
+	for _, version := range obj.GetStatus().Versions {
+		target := obj.GetSpec().Target
+		target.Key = version.Key
+		ref := storeReference(target)
+		if version.VersionID != "" {
+			ref = fmt.Sprintf("%s?versionId=%s", ref, version.VersionID)
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// unsafeEnvChars are replaced in the names of environment variables
+var unsafeEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// An entry is a leaf of a document, flattened under its path
+type entry struct {
+	path  []string
+	value string
+}
+
+// flatten returns the leaves of value in order, with the index of list items
+// formatted by index
+func flatten(value interface{}, path []string, index func(int) string, entries []entry) []entry {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			entries = flatten(v[key], append(path[:len(path):len(path)], key), index, entries)
+		}
+	case []interface{}:
+		for i, item := range v {
+			entries = flatten(item, append(path[:len(path):len(path)], index(i)), index, entries)
+		}
+	case nil:
+		entries = append(entries, entry{path: path})
+	case string:
+		entries = append(entries, entry{path: path, value: v})
+	case json.Number:
+		entries = append(entries, entry{path: path, value: v.String()})
+	default:
+		entries = append(entries, entry{path: path, value: fmt.Sprint(v)})
+	}
+	return entries
+}
+
+// encodeEnv writes the leaves of a mapping as environment variables, named
+// after their upper-cased path joined by underscores, e.g. DB_HOSTS_0
+func encodeEnv(value interface{}) ([]byte, error) {
+	root, err := document(value, Env)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, e := range flatten(root, nil, strconv.Itoa, nil) {
+		name := unsafeEnvChars.ReplaceAllString(strings.ToUpper(strings.Join(e.path, "_")), "_")
+		fmt.Fprintf(&out, "%s=%s\n", name, envValue(e.value))
+	}
+	return out.Bytes(), nil
+}
+
+// envValue quotes values holding whitespace, quotes or comments
+func envValue(value string) string {
+	if value == "" || !strings.ContainsAny(value, " \t\r\n\"'`$#\\") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// encodeProperties writes the leaves of a mapping as Java properties, keyed
+// by their path joined by dots with list items indexed in brackets, e.g.
+// db.hosts[0]
+func encodeProperties(value interface{}) ([]byte, error) {
+	root, err := document(value, Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, e := range flatten(root, nil, func(i int) string { return fmt.Sprintf("[%d]", i) }, nil) {
+		key := strings.Replace(strings.Join(e.path, "."), ".[", "[", -1)
+		fmt.Fprintf(&out, "%s=%s\n", escapeProperty(key, true), escapeProperty(e.value, false))
+	}
+	return out.Bytes(), nil
+}
+
+// escapeProperty escapes the special characters of a property key or value,
+// writing non-ASCII characters as unicode escapes
+func escapeProperty(s string, key bool) string {
+	var out strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			out.WriteString(`\\`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r == '\f':
+			out.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			out.WriteString(`\ `)
+		case (r == '=' || r == ':') && key:
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case (r == '#' || r == '!') && i == 0:
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&out, `\u%04x`, unit)
+			}
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package format converts structured content, read as YAML or JSON, into the
+// format of the stored object
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// formats of the stored object
+const (
+	Raw        = "Raw"
+	JSON       = "JSON"
+	YAML       = "YAML"
+	TOML       = "TOML"
+	Env        = "Env"
+	Properties = "Properties"
+)
+
+var (
+	// ErrUnparseable is returned when the content is not valid YAML or JSON
+	ErrUnparseable = errors.New("content is not valid YAML or JSON")
+	// ErrUnsupported is returned when the content cannot be represented in
+	// the requested format
+	ErrUnsupported = errors.New("content cannot be represented in the format")
+)
+
+// Convert parses data as YAML or JSON and encodes it in the given format.
+// The Raw format, or an empty one, returns data as is.
+func Convert(data []byte, format string) ([]byte, error) {
+	if format == Raw || format == "" {
+		return data, nil
+	}
+
+	value, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case JSON:
+		out, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(ErrUnsupported, err.Error())
+		}
+		return append(out, '\n'), nil
+	case YAML:
+		return yaml.Marshal(value)
+	case TOML:
+		return encodeTOML(value)
+	case Env:
+		return encodeEnv(value)
+	case Properties:
+		return encodeProperties(value)
+	default:
+		return nil, errors.Errorf("unknown format %s", format)
+	}
+}
+
+// parse decodes YAML or JSON data, keeping numbers as written
+func parse(data []byte) (interface{}, error) {
+	converted, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, errors.Wrap(ErrUnparseable, err.Error())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(converted))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrap(ErrUnparseable, err.Error())
+	}
+	return value, nil
+}
+
+// document returns value as the mapping at the root of a document in the
+// given format
+func document(value interface{}, format string) (map[string]interface{}, error) {
+	root, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.Wrapf(ErrUnsupported, "a %s document must be a mapping", format)
+	}
+	return root, nil
+}
+
+// sortedKeys returns the keys of m in order, so that the output is stable
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package format
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+const source = `
+name: web
+replicas: 3
+ratio: 0.25
+debug: false
+owner: ~
+labels:
+  app.kubernetes.io/name: web
+db:
+  hosts:
+  - primary.example.com
+  - replica.example.com
+  password: "p@ss word"
+listeners:
+- port: 80
+- port: 443
+  tls: true
+`
+
+var _ = Describe("Convert", func() {
+	It("should return raw content as is", func() {
+		Expect(Convert([]byte("not: [valid"), Raw)).To(Equal([]byte("not: [valid")))
+		Expect(Convert([]byte("not: [valid"), "")).To(Equal([]byte("not: [valid")))
+	})
+
+	It("should convert YAML to JSON keeping numbers as written", func() {
+		out, err := Convert([]byte("id: 12345678901234567890\nratio: 0.25\n"), JSON)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("{\n  \"id\": 12345678901234567890,\n  \"ratio\": 0.25\n}\n"))
+	})
+
+	It("should convert JSON to YAML", func() {
+		out, err := Convert([]byte(`{"name": "web", "ports": [80, 443]}`), YAML)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("name: web\nports:\n- 80\n- 443\n"))
+	})
+
+	It("should convert a mapping to environment variables", func() {
+		out, err := Convert([]byte(source), Env)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`DB_HOSTS_0=primary.example.com
+DB_HOSTS_1=replica.example.com
+DB_PASSWORD="p@ss word"
+DEBUG=false
+LABELS_APP_KUBERNETES_IO_NAME=web
+LISTENERS_0_PORT=80
+LISTENERS_1_PORT=443
+LISTENERS_1_TLS=true
+NAME=web
+OWNER=
+RATIO=0.25
+REPLICAS=3
+`))
+	})
+
+	It("should convert a mapping to Java properties", func() {
+		out, err := Convert([]byte(source), Properties)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`db.hosts[0]=primary.example.com
+db.hosts[1]=replica.example.com
+db.password=p@ss word
+debug=false
+labels.app.kubernetes.io/name=web
+listeners[0].port=80
+listeners[1].port=443
+listeners[1].tls=true
+name=web
+owner=
+ratio=0.25
+replicas=3
+`))
+	})
+
+	It("should escape the special characters of properties", func() {
+		out, err := Convert([]byte("\"key=a: b\": \" café\\n\"\n"), Properties)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`key\=a\:\ b=\ caf\u00e9\n` + "\n"))
+	})
+
+	It("should convert a mapping to TOML tables", func() {
+		out, err := Convert([]byte("name: web\ntags: [a, b]\nlabels:\n  app.kubernetes.io/name: web\ndb:\n  port: 5432\n  pool: {size: 4}\nlisteners:\n- port: 80\n- port: 443\n  tls: true\n"), TOML)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`name = "web"
+tags = ["a", "b"]
+
+[db]
+port = 5432
+
+[db.pool]
+size = 4
+
+[labels]
+"app.kubernetes.io/name" = "web"
+
+[[listeners]]
+port = 80
+
+[[listeners]]
+port = 443
+tls = true
+`))
+	})
+
+	It("should reject null values in TOML", func() {
+		_, err := Convert([]byte(source), TOML)
+		Expect(err).To(MatchError(ErrUnsupported))
+	})
+
+	table.DescribeTable("should reject documents which are not a mapping",
+		func(format string) {
+			_, err := Convert([]byte("- a\n- b\n"), format)
+			Expect(err).To(MatchError(ErrUnsupported))
+		},
+		table.Entry("env", Env),
+		table.Entry("properties", Properties),
+		table.Entry("toml", TOML),
+	)
+
+	It("should reject content which is not YAML or JSON", func() {
+		_, err := Convert([]byte("name: [web"), JSON)
+		Expect(err).To(MatchError(ErrUnparseable))
+	})
+})
@@ -0,0 +1,179 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// bareKey matches the keys written without quotes
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// encodeTOML writes a mapping as a TOML document. Nested mappings are written
+// as tables and lists of mappings as arrays of tables, other values inline.
+func encodeTOML(value interface{}) ([]byte, error) {
+	root, err := document(value, TOML)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := writeTable(&out, nil, root); err != nil {
+		return nil, err
+	}
+	// documents without root keys start with a table header
+	return bytes.TrimPrefix(out.Bytes(), []byte("\n")), nil
+}
+
+// writeTable writes the keys of table, then its tables and arrays of tables
+// under their own headers, as the keys following a header belong to it
+func writeTable(out *bytes.Buffer, path []string, table map[string]interface{}) error {
+	var tables, arrays []string
+	for _, key := range sortedKeys(table) {
+		switch v := table[key].(type) {
+		case map[string]interface{}:
+			tables = append(tables, key)
+			continue
+		case []interface{}:
+			if isTableArray(v) {
+				arrays = append(arrays, key)
+				continue
+			}
+		}
+
+		inline, err := tomlValue(table[key], append(path[:len(path):len(path)], key))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s = %s\n", tomlKey(key), inline)
+	}
+
+	for _, key := range tables {
+		child := append(path[:len(path):len(path)], key)
+		fmt.Fprintf(out, "\n[%s]\n", tomlPath(child))
+		if err := writeTable(out, child, table[key].(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+	for _, key := range arrays {
+		child := append(path[:len(path):len(path)], key)
+		for _, item := range table[key].([]interface{}) {
+			fmt.Fprintf(out, "\n[[%s]]\n", tomlPath(child))
+			if err := writeTable(out, child, item.(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTableArray reports whether list is a non-empty list of mappings
+func isTableArray(list []interface{}) bool {
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+// tomlValue returns value written inline, failing on null values which TOML
+// cannot represent
+func tomlValue(value interface{}, path []string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", errors.Wrapf(ErrUnsupported, "TOML has no null value, set at %s", strings.Join(path, "."))
+	case string:
+		return tomlString(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for i, item := range v {
+			inline, err := tomlValue(item, append(path[:len(path):len(path)], fmt.Sprint(i)))
+			if err != nil {
+				return "", err
+			}
+			items = append(items, inline)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		items := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			inline, err := tomlValue(v[key], append(path[:len(path):len(path)], key))
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(key)+" = "+inline)
+		}
+		if len(items) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	default:
+		return "", errors.Wrapf(ErrUnsupported, "unexpected %T at %s", value, strings.Join(path, "."))
+	}
+}
+
+// tomlString returns s as a TOML basic string
+func tomlString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			out.WriteString(`\"`)
+		case r == '\\':
+			out.WriteString(`\\`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&out, `\u%04X`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = tomlKey(key)
+	}
+	return strings.Join(keys, ".")
+}
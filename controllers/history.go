@@ -0,0 +1,113 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	DefaultHistoryLimit = 5
+
+	versionTimeFormat = "20060102T150405.000Z"
+)
+
+// recordVersion adds the freshly stored content to the version history of
+// the object and prunes versions beyond the configured limit
+func (r *ObjectReconciler) recordVersion(ctx context.Context, objectStore ctrlapi.ObjectStore, obj cloudobject.Storable, data []byte, info ctrlapi.ObjectInfo) error {
+	history := obj.GetSpec().History
+	version := cloudobject.ObjectVersion{
+		Key:       obj.GetSpec().Target.Key,
+		Checksum:  checksum(data),
+		Timestamp: metav1.Now(),
+	}
+
+	switch history.Mode {
+	case cloudobject.HistoryKey, Empty:
+		target := obj.GetSpec().Target
+		target.Key = versionedKey(target.Key, version.Timestamp.Time)
+		if _, err := objectStore.Store(ctx, data, target, ownerMetadata(obj)); err != nil {
+			return err
+		}
+		version.Key = target.Key
+	case cloudobject.HistoryVersionID:
+		if info.VersionID == "" {
+			return errors.Errorf("no version id returned, versioning not enabled on bucket %s", obj.GetSpec().Target.Bucket)
+		}
+		version.VersionID = info.VersionID
+	default:
+		return errors.Errorf("invalid history mode %s", history.Mode)
+	}
+
+	// record the version before pruning so that it is kept in the status
+	// even if removing older versions fails
+	obj.GetStatus().Versions = append([]cloudobject.ObjectVersion{version}, obj.GetStatus().Versions...)
+
+	limit := history.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	for len(obj.GetStatus().Versions) > limit {
+		oldest := obj.GetStatus().Versions[len(obj.GetStatus().Versions)-1]
+		if err := deleteVersion(ctx, objectStore, obj.GetSpec().Target, oldest); err != nil {
+			return err
+		}
+		obj.GetStatus().Versions = obj.GetStatus().Versions[:len(obj.GetStatus().Versions)-1]
+		log.FromContext(ctx).Info("pruned object version", "key", oldest.Key, "versionId", oldest.VersionID)
+	}
+
+	return nil
+}
+
+// deleteVersions removes all versions recorded in the status of the object
+func (r *ObjectReconciler) deleteVersions(ctx context.Context, objectStore ctrlapi.ObjectStore, obj cloudobject.Storable) error {
+	for len(obj.GetStatus().Versions) > 0 {
+		if err := deleteVersion(ctx, objectStore, obj.GetSpec().Target, obj.GetStatus().Versions[0]); err != nil {
+			return err
+		}
+		obj.GetStatus().Versions = obj.GetStatus().Versions[1:]
+	}
+	return nil
+}
+
+func deleteVersion(ctx context.Context, objectStore ctrlapi.ObjectStore, target cloudobject.ObjectTarget, version cloudobject.ObjectVersion) error {
+	target.Key = version.Key
+	if version.VersionID != "" {
+		return objectStore.DeleteVersion(ctx, target, version.VersionID)
+	}
+	return objectStore.Delete(ctx, target)
+}
+
+func versionedKey(key string, t time.Time) string {
+	return fmt.Sprintf("%s.%s", key, t.UTC().Format(versionTimeFormat))
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
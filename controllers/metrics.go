@@ -0,0 +1,226 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	metricsNamespace = "s3copy"
+
+	ProviderAWS = "aws"
+
+	resultSuccess = "success"
+	resultError   = "error"
+	resultSkipped = "skipped"
+)
+
+var (
+	uploadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "uploads_total",
+		Help:      "Number of uploads to the object store by result and provider",
+	}, []string{"result", "provider"})
+
+	deletesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "deletes_total",
+		Help:      "Number of deletes from the object store by result and provider",
+	}, []string{"result", "provider"})
+
+	uploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "upload_duration_seconds",
+		Help:      "Latency of uploads to the object store",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"provider"})
+
+	uploadedBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "uploaded_bytes_total",
+		Help:      "Number of bytes successfully uploaded to the object store",
+	}, []string{"provider"})
+
+	objectsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "objects",
+		Help:      "Number of objects by synced state",
+	}, []string{"synced"})
+
+	driftDetectionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "drift_detections_total",
+		Help:      "Number of times the source content changed without a change to the object spec",
+	})
+
+	credentialFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "credential_failures_total",
+		Help:      "Number of failures to load the credentials of an object",
+	})
+
+	objectStoreErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "object_store_errors_total",
+		Help:      "Number of failed calls to the object store by operation and reason",
+	}, []string{"operation", "reason", "provider"})
+
+	scheduledRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "scheduled_runs_total",
+		Help:      "Number of scheduled snapshot runs by result",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		uploadsTotal,
+		deletesTotal,
+		uploadDuration,
+		uploadedBytesTotal,
+		objectsGauge,
+		driftDetectionsTotal,
+		credentialFailuresTotal,
+		objectStoreErrorsTotal,
+		scheduledRunsTotal,
+	)
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultSuccess
+}
+
+// meteredObjectStore records metrics for the calls made to the wrapped ObjectStore
+type meteredObjectStore struct {
+	ctrlapi.ObjectStore
+	provider string
+}
+
+func withMetrics(store ctrlapi.ObjectStore, provider string) ctrlapi.ObjectStore {
+	return &meteredObjectStore{ObjectStore: store, provider: provider}
+}
+
+func (m *meteredObjectStore) Store(ctx context.Context, content []byte, target cloudobject.ObjectTarget, metadata map[string]string) (ctrlapi.ObjectInfo, error) {
+	start := time.Now()
+	info, err := m.ObjectStore.Store(ctx, content, target, metadata)
+	uploadDuration.WithLabelValues(m.provider).Observe(time.Since(start).Seconds())
+	uploadsTotal.WithLabelValues(resultLabel(err), m.provider).Inc()
+	m.countError("store", err)
+	if err == nil {
+		uploadedBytesTotal.WithLabelValues(m.provider).Add(float64(len(content)))
+	}
+	return info, err
+}
+
+func (m *meteredObjectStore) Head(ctx context.Context, target cloudobject.ObjectTarget) (ctrlapi.ObjectInfo, error) {
+	info, err := m.ObjectStore.Head(ctx, target)
+	if !errors.Is(err, ctrlapi.ErrNotFound) {
+		m.countError("head", err)
+	}
+	return info, err
+}
+
+func (m *meteredObjectStore) Get(ctx context.Context, target cloudobject.ObjectTarget, versionID string) ([]byte, ctrlapi.ObjectInfo, error) {
+	content, info, err := m.ObjectStore.Get(ctx, target, versionID)
+	if !errors.Is(err, ctrlapi.ErrNotFound) {
+		m.countError("get", err)
+	}
+	return content, info, err
+}
+
+func (m *meteredObjectStore) Copy(ctx context.Context, source, target cloudobject.ObjectTarget, metadata map[string]string) (ctrlapi.ObjectInfo, error) {
+	start := time.Now()
+	info, err := m.ObjectStore.Copy(ctx, source, target, metadata)
+	uploadDuration.WithLabelValues(m.provider).Observe(time.Since(start).Seconds())
+	uploadsTotal.WithLabelValues(resultLabel(err), m.provider).Inc()
+	m.countError("copy", err)
+	return info, err
+}
+
+func (m *meteredObjectStore) Delete(ctx context.Context, target cloudobject.ObjectTarget) error {
+	err := m.ObjectStore.Delete(ctx, target)
+	deletesTotal.WithLabelValues(resultLabel(err), m.provider).Inc()
+	m.countError("delete", err)
+	return err
+}
+
+func (m *meteredObjectStore) DeleteVersion(ctx context.Context, target cloudobject.ObjectTarget, versionID string) error {
+	err := m.ObjectStore.DeleteVersion(ctx, target, versionID)
+	deletesTotal.WithLabelValues(resultLabel(err), m.provider).Inc()
+	m.countError("delete", err)
+	return err
+}
+
+func (m *meteredObjectStore) CreateBucket(ctx context.Context, target cloudobject.ObjectTarget) error {
+	err := m.ObjectStore.CreateBucket(ctx, target)
+	m.countError("create_bucket", err)
+	return err
+}
+
+func (m *meteredObjectStore) countError(operation string, err error) {
+	if err != nil {
+		objectStoreErrorsTotal.WithLabelValues(operation, ctrlapi.ReasonOf(err), m.provider).Inc()
+	}
+}
+
+// syncTracker keeps the synced state of every known object to report the
+// number of objects by synced state
+type syncTracker struct {
+	mu     sync.Mutex
+	states map[types.NamespacedName]bool
+}
+
+var objectStates = &syncTracker{states: map[types.NamespacedName]bool{}}
+
+func (t *syncTracker) set(key types.NamespacedName, synced bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.states[key] = synced
+	t.publish()
+}
+
+func (t *syncTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.states, key)
+	t.publish()
+}
+
+func (t *syncTracker) publish() {
+	counts := map[bool]int{}
+	for _, synced := range t.states {
+		counts[synced]++
+	}
+	for _, synced := range []bool{true, false} {
+		objectsGauge.WithLabelValues(strconv.FormatBool(synced)).Set(float64(counts[synced]))
+	}
+}
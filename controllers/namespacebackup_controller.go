@@ -0,0 +1,301 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers/backup"
+)
+
+const (
+	// NamespaceBackupKind is the kind of namespace backups
+	NamespaceBackupKind = "NamespaceBackup"
+
+	BackedUp = "BackedUp"
+)
+
+// NamespaceBackupReconciler reconciles a NamespaceBackup object, archiving
+// the resources of its namespace with the settings of the ObjectReconciler
+type NamespaceBackupReconciler struct {
+	*ObjectReconciler
+}
+
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=namespacebackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=namespacebackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=namespacebackups/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=services,verbs=list
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=list
+
+func (r *NamespaceBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := startSpan(ctx, "ReconcileBackup",
+		attribute.String("object.namespace", req.Namespace),
+		attribute.String("object.name", req.Name),
+	)
+	defer span.End()
+
+	obj := &cloudobject.NamespaceBackup{}
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if err := r.applyTargetDefaults(&obj.Spec.Target, &obj.Spec.DeletionPolicy); err != nil {
+		return ctrl.Result{}, r.reportFailure(ctx, obj, Failed, err)
+	}
+
+	if !obj.GetDeletionTimestamp().IsZero() {
+		// the finalizer is retained until the backup is resumed
+		if !controllerutil.ContainsFinalizer(obj, ObjectFinalizer) || r.isBackupSuspended(ctx, obj) {
+			return ctrl.Result{}, nil
+		}
+		if deleteErr := r.deleteArchive(ctx, obj); deleteErr != nil {
+			if err := r.reportFailure(ctx, obj, failureReason(deleteErr, Failed), deleteErr); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, deleteErr
+		}
+		controllerutil.RemoveFinalizer(obj, ObjectFinalizer)
+		return ctrl.Result{}, r.Update(ctx, obj)
+	}
+
+	if !controllerutil.ContainsFinalizer(obj, ObjectFinalizer) {
+		controllerutil.AddFinalizer(obj, ObjectFinalizer)
+		if err := r.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if r.isBackupSuspended(ctx, obj) {
+		return ctrl.Result{}, nil
+	}
+
+	resyncAt, resync := obj.GetAnnotations()[ResyncAnnotation]
+	resync = resync && resyncAt != obj.Status.LastHandledResyncAt
+	if meta.IsStatusConditionTrue(obj.Status.Conditions, ConditionSynced) && obj.Status.ObservedGeneration == obj.GetGeneration() && !resync {
+		// backups are only taken again on spec changes or resync requests
+		return ctrl.Result{}, nil
+	}
+
+	if backupErr := r.backup(ctx, obj); backupErr != nil {
+		if err := r.reportFailure(ctx, obj, failureReason(backupErr, Failed), backupErr); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, backupErr
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		For(&cloudobject.NamespaceBackup{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+		))).
+		WithEventFilter(predicate.NewPredicateFuncs(r.Scope.Contains)).
+		Complete(r)
+}
+
+// backup stores the archive of the resources gathered from the namespace of
+// the backup
+func (r *NamespaceBackupReconciler) backup(ctx context.Context, obj *cloudobject.NamespaceBackup) error {
+	log := log.FromContext(ctx)
+
+	key, err := r.encryptionKey(ctx, obj)
+	if err != nil {
+		return err
+	}
+	resources, err := r.gather(ctx, obj)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	data, err := backup.Write(obj.Namespace, now, resources, key)
+	if err != nil {
+		return err
+	}
+
+	if r.DryRun {
+		msg := fmt.Sprintf("dry run: would %s %s", Store, storeReference(obj.Spec.Target))
+		r.Recorder.Event(obj, corev1.EventTypeNormal, DryRun, msg)
+		log.Info(msg, "size", len(data), "resources", len(resources))
+		return nil
+	}
+
+	objectStore, err := r.objectStore(ctx, obj.Spec.Credentials, obj.Spec.Target)
+	if err != nil {
+		return err
+	}
+	if _, err := r.store(ctx, objectStore, obj, obj.Spec.Target, data); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("archived %d resources into %s", len(resources), storeReference(obj.Spec.Target))
+	obj.Status.Reference = storeReference(obj.Spec.Target)
+	obj.Status.LastBackupTime = &metav1.Time{Time: now}
+	obj.Status.ResourceCount = len(resources)
+	obj.Status.Checksum = checksum(data)
+	obj.Status.ObservedGeneration = obj.GetGeneration()
+	if resyncAt, ok := obj.GetAnnotations()[ResyncAnnotation]; ok {
+		obj.Status.LastHandledResyncAt = resyncAt
+	}
+	setBackupSynced(obj, metav1.ConditionTrue, ReasonSucceeded, msg)
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return err
+	}
+
+	r.Recorder.Event(obj, corev1.EventTypeNormal, BackedUp, msg)
+	log.Info("successfully backed up namespace", "key", storeReference(obj.Spec.Target), "resources", len(resources))
+	return nil
+}
+
+// gather lists the resources of the backup in its namespace, Secrets first so
+// that they are restored before the resources referring to them. Resources
+// controlled by another resource are skipped, as they are recreated by their
+// controller.
+func (r *NamespaceBackupReconciler) gather(ctx context.Context, obj *cloudobject.NamespaceBackup) ([]unstructured.Unstructured, error) {
+	kinds := obj.Spec.Resources
+	if obj.Spec.IncludeSecrets {
+		kinds = append([]cloudobject.BackupResource{{APIVersion: "v1", Kind: backup.SecretKind}}, kinds...)
+	}
+
+	var resources []unstructured.Unstructured
+	for _, kind := range kinds {
+		gv, err := schema.ParseGroupVersion(kind.APIVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid apiVersion %s", kind.APIVersion)
+		}
+		selector := labels.Everything()
+		if kind.Selector != nil {
+			if selector, err = metav1.LabelSelectorAsSelector(kind.Selector); err != nil {
+				return nil, errors.Wrapf(err, "invalid selector of %s", kind.Kind)
+			}
+		}
+
+		// unstructured lists are read from the API server rather than the
+		// cache, so that any kind can be gathered
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gv.WithKind(kind.Kind + "List"))
+		if err := r.List(ctx, list, client.InNamespace(obj.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, errors.Wrapf(err, "unable to list %s", kind.Kind)
+		}
+		for _, item := range list.Items {
+			item.SetGroupVersionKind(gv.WithKind(kind.Kind))
+			if metav1.GetControllerOf(&item) != nil || isServiceAccountToken(item) {
+				continue
+			}
+			resources = append(resources, item)
+		}
+	}
+	return resources, nil
+}
+
+// encryptionKey returns the key the Secrets of the backup are encrypted with,
+// nil if they are not encrypted
+func (r *NamespaceBackupReconciler) encryptionKey(ctx context.Context, obj *cloudobject.NamespaceBackup) ([]byte, error) {
+	if obj.Spec.Encryption == nil {
+		return nil, nil
+	}
+	key, err := SecretKey(ctx, r, obj.Spec.Encryption.KeyReference)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read encryption key")
+	}
+	return key, backup.ValidateKey(key)
+}
+
+// deleteArchive removes the archive of the backup unless it is retained
+func (r *NamespaceBackupReconciler) deleteArchive(ctx context.Context, obj *cloudobject.NamespaceBackup) error {
+	log := log.FromContext(ctx)
+	if obj.Spec.DeletionPolicy == cloudobject.DeletionRetain || obj.Status.Reference == "" {
+		log.Info("retaining the archive in the object store")
+		return nil
+	}
+
+	if r.DryRun {
+		msg := fmt.Sprintf("dry run: would %s %s", Delete, storeReference(obj.Spec.Target))
+		r.Recorder.Event(obj, corev1.EventTypeNormal, DryRun, msg)
+		log.Info(msg)
+		return nil
+	}
+
+	objectStore, err := r.objectStore(ctx, obj.Spec.Credentials, obj.Spec.Target)
+	if err != nil {
+		return err
+	}
+	if err := objectStore.Delete(ctx, obj.Spec.Target); err != nil {
+		return err
+	}
+	log.Info("successfully deleted archive", "key", storeReference(obj.Spec.Target))
+	return nil
+}
+
+func (r *NamespaceBackupReconciler) isBackupSuspended(ctx context.Context, obj *cloudobject.NamespaceBackup) bool {
+	if obj.Spec.Suspend {
+		log.FromContext(ctx).Info("backup is suspended, skipping object store operations", "key", client.ObjectKeyFromObject(obj))
+	}
+	return obj.Spec.Suspend
+}
+
+// reportFailure records failure in the status and events of the backup
+func (r *NamespaceBackupReconciler) reportFailure(ctx context.Context, obj *cloudobject.NamespaceBackup, reason string, failure error) error {
+	log.FromContext(ctx).Error(failure, "failed to back up namespace")
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(failure)
+	span.SetStatus(codes.Error, failure.Error())
+
+	setBackupSynced(obj, metav1.ConditionFalse, reason, failure.Error())
+	r.Recorder.Event(obj, corev1.EventTypeWarning, reason, failure.Error())
+	return r.Status().Update(ctx, obj)
+}
+
+// isServiceAccountToken reports whether resource is a token Secret, which is
+// recreated for its service account
+func isServiceAccountToken(resource unstructured.Unstructured) bool {
+	secretType, _, _ := unstructured.NestedString(resource.Object, "type")
+	return resource.GetKind() == backup.SecretKind && secretType == string(corev1.SecretTypeServiceAccountToken)
+}
+
+func setBackupSynced(obj *cloudobject.NamespaceBackup, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+		Type:               ConditionSynced,
+		Status:             status,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             reason,
+		Message:            msg,
+	})
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers/backup"
+)
+
+var _ = Describe("NamespaceBackup controller", func() {
+	const (
+		SecretName = "backup-creds"
+		Namespace  = "backed-up"
+
+		timeout  = time.Second * 30
+		interval = time.Millisecond * 250
+	)
+
+	key := bytes.Repeat([]byte("k"), backup.KeySize)
+
+	// storedArchive returns the content last stored under key
+	storedArchive := func(key string) []byte {
+		for i := fakeObjectStore.StoreCallCount() - 1; i >= 0; i-- {
+			_, data, target, _ := fakeObjectStore.StoreArgsForCall(i)
+			if target.Key == key {
+				return data
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: Namespace}}
+		if err := k8sClient.Create(ctx, ns); !apierrors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace},
+			Data: map[string][]byte{
+				"creds-key":      []byte("c29tZS1kYXRh"),
+				"encryption-key": key,
+			},
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace}}
+		Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+	})
+
+	It("should archive the selected resources of its namespace and delete the archive with it", func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-settings", Namespace: Namespace, Labels: map[string]string{"app": "web"}},
+			Data:       map[string]string{"setting": "value"},
+		}
+		Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+		unselected := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-settings", Namespace: Namespace},
+		}
+		Expect(k8sClient.Create(ctx, unselected)).Should(Succeed())
+		controller := true
+		owned := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "owned-settings",
+				Namespace: Namespace,
+				Labels:    map[string]string{"app": "web"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Name:       cm.Name,
+					UID:        "1234",
+					Controller: &controller,
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, owned)).Should(Succeed())
+
+		obj := &cloudobj.NamespaceBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: Namespace},
+			Spec: cloudobj.NamespaceBackupSpec{
+				Resources: []cloudobj.BackupResource{{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				}},
+				IncludeSecrets: true,
+				Encryption: &cloudobj.BackupEncryption{
+					KeyReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Namespace: Namespace, Name: SecretName},
+						Key:             "encryption-key",
+					},
+				},
+				DeletionPolicy: cloudobj.DeletionDelete,
+				Target: cloudobj.ObjectTarget{
+					Region: "us-west-2",
+					Bucket: "backup-bucket",
+					Key:    "backed-up.tar.gz",
+				},
+				Credentials: cloudobj.Credentials{
+					Source: cloudobj.CredentialsSecret,
+					SecretReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Namespace: Namespace, Name: SecretName},
+						Key:             "creds-key",
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return false
+			}
+			return meta.IsStatusConditionTrue(obj.Status.Conditions, ConditionSynced)
+		}, timeout, interval).Should(BeTrue())
+		Expect(obj.Status.Reference).To(Equal("s3://backup-bucket/backed-up.tar.gz"))
+		Expect(obj.Status.ResourceCount).To(Equal(2))
+		Expect(obj.Status.LastBackupTime).NotTo(BeNil())
+
+		data := storedArchive("backed-up.tar.gz")
+		Expect(obj.Status.Checksum).To(Equal(checksum(data)))
+		index, resources, err := backup.Read(data, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index.Namespace).To(Equal(Namespace))
+		Expect(index.Resources).To(Equal([]backup.Entry{
+			{APIVersion: "v1", Kind: "Secret", Name: SecretName, Path: "resources/v1/Secret/backup-creds.yaml.enc", Encrypted: true},
+			{APIVersion: "v1", Kind: "ConfigMap", Name: "app-settings", Path: "resources/v1/ConfigMap/app-settings.yaml"},
+		}))
+		Expect(resources[1].Object["data"]).To(HaveKeyWithValue("setting", "value"))
+
+		_, _, _, metadata := fakeObjectStore.StoreArgsForCall(fakeObjectStore.StoreCallCount() - 1)
+		Expect(metadata).To(HaveKeyWithValue(OwnerMetadataKey, "NamespaceBackup/backed-up/nightly"))
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+		Eventually(func() bool {
+			for i := 0; i < fakeObjectStore.DeleteCallCount(); i++ {
+				if _, target := fakeObjectStore.DeleteArgsForCall(i); target.Key == "backed-up.tar.gz" {
+					return true
+				}
+			}
+			return false
+		}, timeout, interval).Should(BeTrue())
+
+		for _, cm := range []*corev1.ConfigMap{cm, unselected, owned} {
+			Expect(k8sClient.Delete(ctx, cm)).Should(Succeed())
+		}
+	})
+
+	It("should refuse secrets listed as resources and encryption without secrets", func() {
+		obj := &cloudobj.NamespaceBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: Namespace},
+			Spec: cloudobj.NamespaceBackupSpec{
+				Resources: []cloudobj.BackupResource{{APIVersion: "v1", Kind: "Secret"}},
+				Encryption: &cloudobj.BackupEncryption{
+					KeyReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Namespace: Namespace, Name: SecretName},
+						Key:             "encryption-key",
+					},
+				},
+				Target: cloudobj.ObjectTarget{Bucket: "backup-bucket", Key: "invalid.tar.gz"},
+				Credentials: cloudobj.Credentials{
+					SecretReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Namespace: Namespace, Name: SecretName},
+						Key:             "creds-key",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, obj)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(And(
+			ContainSubstring("Secrets are gathered with includeSecrets"),
+			ContainSubstring("encryption requires includeSecrets"),
+		))
+	})
+})
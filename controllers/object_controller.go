@@ -19,20 +19,33 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	cloudobject "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
 	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"dev.nimak.link/s3-copy-controller/controllers/format"
+	"dev.nimak.link/s3-copy-controller/controllers/remote"
 )
 
 // ObjectReconciler reconciles a Object object
@@ -41,20 +54,53 @@ type ObjectReconciler struct {
 	Scheme       *runtime.Scheme
 	Recorder     record.EventRecorder
 	StoreManager ctrlapi.StoreManager
+	// DryRun resolves sources and credentials of all objects
+	// without modifying the object store
+	DryRun bool
+	// DeletionTimeout bounds how long deleting a stored object is retried
+	// before the finalizer is removed regardless, zero retries forever
+	DeletionTimeout time.Duration
+	// MaxConcurrentReconciles is the number of objects reconciled in parallel
+	MaxConcurrentReconciles int
+	// Limits are the default pacing and retries of calls to object stores
+	Limits ctrlapi.Limits
+	// Scope restricts the objects handled by the controller
+	Scope Scope
+	// DefaultRegion of targets without a region
+	DefaultRegion string
+	// DefaultDeletionPolicy of objects without a deletion policy
+	DefaultDeletionPolicy cloudobject.DeletionPolicy
+	// Endpoints of the object stores, the default endpoints if empty
+	Endpoints ctrlapi.Endpoints
+	// Features enabled or disabled by name, unset features use their default
+	Features map[string]bool
+	// UploaderImage runs the upload jobs of PVC sources, PVC sources fail to
+	// sync if empty
+	UploaderImage string
+	// Cluster describes the cluster of the controller to templates
+	Cluster ClusterInfo
 }
 
 const (
 	ObjectFinalizer = "objstore.dev.nimak.link/finalizer"
-	Failed          = "Failed"
-	Synced          = "Synced"
-	Removed         = "Removed"
+	// ResyncAnnotation triggers a re-upload of the object whenever its value changes
+	ResyncAnnotation = "objstore.dev.nimak.link/resync-at"
+	Failed           = "Failed"
+	Synced           = "Synced"
+	Removed          = "Removed"
+	DryRun           = "DryRun"
+	BucketCreated    = "BucketCreated"
+
+	ConditionSynced = "Synced"
+	ReasonSucceeded = "Succeeded"
+	// ReasonConversionFailed is set when the source cannot be converted to
+	// the output format
+	ReasonConversionFailed = "ConversionFailed"
 
 	// switch elements
-	Delete    = "delete"
-	Retain    = "retain"
-	Local     = "local"
-	ConfigMap = "configmap"
-	Empty     = ""
+	Store  = "store"
+	Delete = "delete"
+	Empty  = ""
 )
 
 type Action int
@@ -70,32 +116,72 @@ const (
 //+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 
 func (r *ObjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	var obj cloudobject.Object
-	if err := r.Get(ctx, req.NamespacedName, &obj); err != nil {
+	return r.reconcile(ctx, req, &cloudobject.Object{})
+}
+
+// reconcile stores or deletes obj, the Object or ClusterObject named by req
+func (r *ObjectReconciler) reconcile(ctx context.Context, req ctrl.Request, obj cloudobject.Storable) (ctrl.Result, error) {
+	ctx, span := startSpan(ctx, "Reconcile",
+		attribute.String("object.namespace", req.Namespace),
+		attribute.String("object.name", req.Name),
+	)
+	defer span.End()
+
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			objectStates.forget(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if obj.ObjectMeta.DeletionTimestamp.IsZero() {
-		if !controllerutil.ContainsFinalizer(&obj, ObjectFinalizer) {
-			controllerutil.AddFinalizer(&obj, ObjectFinalizer)
-			if err := r.Update(ctx, &obj); err != nil {
+	if obj.GetDeletionTimestamp().IsZero() {
+		if !controllerutil.ContainsFinalizer(obj, ObjectFinalizer) {
+			controllerutil.AddFinalizer(obj, ObjectFinalizer)
+			if err := r.Update(ctx, obj); err != nil {
 				return ctrl.Result{}, err
 			}
 		}
+		if r.isSuspended(ctx, obj) {
+			return ctrl.Result{}, nil
+		}
+
 		// process object creation / update
-		if err := r.process(ctx, &obj, StoreAction); err != nil {
+		if err := r.process(ctx, obj, StoreAction); err != nil {
 			return ctrl.Result{}, err
 		}
+
+		// remote sources are polled for changes and failed uploads retried
+		interval := sourceInterval(obj.GetSpec().Source)
+		if retry := uploadRetryInterval(obj); retry > 0 {
+			interval = retry
+		}
+		return ctrl.Result{RequeueAfter: interval}, nil
 	} else {
-		if controllerutil.ContainsFinalizer(&obj, ObjectFinalizer) {
-			if err := r.process(ctx, &obj, DeleteAction); err != nil {
-				return ctrl.Result{}, err
+		if controllerutil.ContainsFinalizer(obj, ObjectFinalizer) {
+			// the finalizer is retained until the object is resumed
+			if r.isSuspended(ctx, obj) {
+				return ctrl.Result{}, nil
+			}
+
+			if err := r.process(ctx, obj, DeleteAction); err != nil {
+				abandon, abandonErr := r.abandonDeletion(ctx, obj, err)
+				if abandonErr != nil {
+					return ctrl.Result{}, abandonErr
+				}
+				if !abandon {
+					return ctrl.Result{}, err
+				}
+			} else if r.isDryRun(obj) {
+				// a dry run only reports the planned deletion, the
+				// finalizer is retained until dry run is turned off
+				return ctrl.Result{}, nil
 			}
 
-			controllerutil.RemoveFinalizer(&obj, ObjectFinalizer)
-			if err := r.Update(ctx, &obj); err != nil {
+			controllerutil.RemoveFinalizer(obj, ObjectFinalizer)
+			if err := r.Update(ctx, obj); err != nil {
 				return ctrl.Result{}, err
 			}
 		}
@@ -107,12 +193,30 @@ func (r *ObjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ObjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// owners of a target are looked up among both kinds, so both are
+	// indexed here
+	for _, obj := range []client.Object{&cloudobject.Object{}, &cloudobject.ClusterObject{}} {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), obj, targetIndexKey, indexTarget); err != nil {
+			return err
+		}
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), obj, templateInputIndexKey, indexTemplateInputs); err != nil {
+			return err
+		}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		For(&cloudobject.Object{}).
+		Owns(&batchv1.Job{}).
+		Watches(&source.Kind{Type: &cloudobject.Object{}}, handler.EnqueueRequestsFromMapFunc(r.objectsWithSameTarget)).
+		Watches(&source.Kind{Type: &cloudobject.ClusterObject{}}, handler.EnqueueRequestsFromMapFunc(r.objectsWithSameTarget)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.objectsWithConfigMapInput)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.objectsWithSecretInput)).
+		WithEventFilter(predicate.NewPredicateFuncs(r.Scope.Contains)).
 		Complete(r)
 }
 
-func (r *ObjectReconciler) process(ctx context.Context, obj *cloudobject.Object, action Action) (controllerError error) {
+func (r *ObjectReconciler) process(ctx context.Context, obj cloudobject.Storable, action Action) (controllerError error) {
 	var (
 		// we use err to capture non-controller errors and
 		// handle them separately for external operations
@@ -127,53 +231,211 @@ func (r *ObjectReconciler) process(ctx context.Context, obj *cloudobject.Object,
 	log.Info("processing resource", "key", client.ObjectKeyFromObject(obj), "action", action)
 
 	defer func() {
-		controllerError = r.processError(ctx, obj, action, &err)
+		if processingError := r.processError(ctx, obj, action, &err); processingError != nil {
+			controllerError = processingError
+		}
 	}()
 
-	if secretData, err = r.pullSecret(ctx, obj); err != nil {
+	if err = r.applyDefaults(obj); err != nil {
+		return
+	}
+
+	if secretData, err = PullSecret(ctx, r, obj); err != nil {
+		credentialFailuresTotal.Inc()
 		return
 	}
 
 	log.Info("fetching object store")
-	objectStore := r.StoreManager.Get(ctrlapi.ConfigData{Secret: secretData, Region: obj.Spec.Target.Region})
+	_, span := startSpan(ctx, "StoreManager.Get", attribute.String("objectstore.region", obj.GetSpec().Target.Region))
+	storeConfig := ctrlapi.ConfigData{
+		Secret:    secretData,
+		Region:    obj.GetSpec().Target.Region,
+		Limits:    r.limits(obj.GetSpec().Target),
+		Endpoints: r.Endpoints,
+	}
+	objectStore := withTracing(withMetrics(r.StoreManager.Get(storeConfig), ProviderAWS))
+	span.End()
 	switch action {
 	case StoreAction:
-		if objData, err = r.extractData(ctx, obj); err != nil {
+		if obj.GetSpec().Source.Type == cloudobject.SourcePVC {
+			// the content of a volume is uploaded by a job mounting it
+			return r.processVolume(ctx, objectStore, obj, storeConfig)
+		}
+
+		resync := r.resyncRequested(obj)
+		var (
+			revision, sourceRevision string
+			bucket                   *bucketCopy
+		)
+		if obj.GetStatus().Synced && obj.GetStatus().ObservedGeneration == obj.GetGeneration() && !resync {
+			revision = obj.GetStatus().SourceRevision
+		}
+		if obj.GetSpec().Source.Type == cloudobject.SourceS3 {
+			if objData, bucket, err = r.readBucket(ctx, obj, revision); bucket != nil {
+				sourceRevision = bucket.info.ETag
+			}
+		} else {
+			objData, sourceRevision, err = extractSource(ctx, r, obj, obj.GetSpec().Source, revision, r.Cluster)
+		}
+		if err != nil {
+			if errors.Is(err, remote.ErrNotModified) {
+				log.Info("remote source not modified, skipping upload", "key", printReference(obj), "revision", revision)
+				err = nil
+			}
 			return
 		}
 
-		if err = objectStore.Store(ctx, objData, obj.Spec.Target); err != nil {
+		// a server side copy never reads the content, its changes are
+		// tracked by the ETag of the source object
+		copied := bucket != nil && bucket.serverSide
+		var sum string
+		drifted := obj.GetStatus().SourceRevision != sourceRevision
+		if !copied {
+			if objData, err = convert(objData, obj.GetSpec().Output); err != nil {
+				return
+			}
+			sum = checksum(objData)
+			drifted = obj.GetStatus().Checksum != sum
+		}
+		if obj.GetStatus().Synced && obj.GetStatus().ObservedGeneration == obj.GetGeneration() && drifted {
+			// the source changed while the object spec did not
+			driftDetectionsTotal.Inc()
+			log.Info("source content drifted from the last synced content", "key", printReference(obj))
+		}
+
+		if obj.GetSpec().History != nil && obj.GetStatus().Synced && obj.GetStatus().Checksum == sum && !resync {
+			// with history enabled every upload creates a new version,
+			// so unchanged content is not uploaded again
+			log.Info("content unchanged, skipping upload", "key", printReference(obj))
 			return
 		}
 
-		obj.Status.Synced = true
-		obj.Status.Reference = fmt.Sprintf("s3://%s/%s", obj.Spec.Target.Bucket, obj.Spec.Target.Key)
+		if r.enabled(FeatureCredentialValidation) {
+			if err = r.validateAccess(ctx, obj, storeConfig); err != nil {
+				credentialFailuresTotal.Inc()
+				return
+			}
+		}
+
+		var reason, msg string
+		if reason, msg, err = r.checkOwnership(ctx, objectStore, obj); err != nil {
+			return
+		}
+		if reason != "" {
+			controllerError = r.reportConflict(ctx, obj, reason, msg)
+			return
+		}
+
+		if r.isDryRun(obj) {
+			size := len(objData)
+			if copied {
+				size = int(bucket.info.Size)
+			}
+			controllerError = r.reportDryRun(ctx, obj, &cloudobject.DryRunStatus{
+				Action:    Store,
+				Reference: storeReference(obj.GetSpec().Target),
+				Size:      size,
+				Checksum:  sum,
+			})
+			return
+		}
+
+		var info ctrlapi.ObjectInfo
+		if copied {
+			if info, objData, err = r.copy(ctx, objectStore, obj, bucket); err != nil {
+				return
+			}
+			if objData != nil {
+				sum = checksum(objData)
+			}
+			sourceRevision = bucket.info.ETag
+		} else if info, err = r.store(ctx, objectStore, obj, obj.GetSpec().Target, objData); err != nil {
+			return
+		}
+
+		if obj.GetSpec().History != nil {
+			if err = r.recordVersion(ctx, objectStore, obj, objData, info); err != nil {
+				return
+			}
+		}
+
+		obj.GetStatus().Synced = true
+		obj.GetStatus().Checksum = sum
+		obj.GetStatus().ETag = info.ETag
+		obj.GetStatus().SourceRevision = sourceRevision
+		obj.GetStatus().VersionedBucket = versionedBucket(obj.GetSpec().Target, info)
+		obj.GetStatus().ObservedGeneration = obj.GetGeneration()
+		setConflict(obj, metav1.ConditionFalse, ReasonNoConflict, "target is managed by this object")
+		setSynced(obj, metav1.ConditionTrue, ReasonSucceeded, fmt.Sprintf("object reference: %s", printReference(obj)))
+		if resync {
+			obj.GetStatus().LastHandledResyncAt = obj.GetAnnotations()[ResyncAnnotation]
+			log.Info("handled resync request", "resyncAt", obj.GetStatus().LastHandledResyncAt)
+		}
+		obj.GetStatus().Reference = storeReference(obj.GetSpec().Target)
+		obj.GetStatus().DryRun = nil
 		if controllerError = r.Status().Update(ctx, obj); controllerError != nil {
 			return
 		}
+		objectStates.set(client.ObjectKeyFromObject(obj), true)
 
 		r.Recorder.Event(obj, corev1.EventTypeNormal, Synced, fmt.Sprintf("object reference: %s", printReference(obj)))
 		log.Info("successfully synced resource", "key", printReference(obj))
 
 	case DeleteAction:
-		switch strings.ToLower(obj.Spec.DeletionPolicy) {
-		case Delete:
-			if err = objectStore.Delete(ctx, obj.Spec.Target); err != nil {
+		switch obj.GetSpec().DeletionPolicy {
+		case cloudobject.DeletionDelete, cloudobject.DeletionOrphanOnMismatch:
+			var reason, msg string
+			if reason, msg, err = r.verifyDeletion(ctx, objectStore, obj); err != nil {
+				return
+			}
+			deletable := reason == Empty
+			switch {
+			case reason == ReasonTargetInUse:
+				log.Info("not deleting resource managed by another object", "key", printReference(obj), "reason", msg)
+			case !deletable && obj.GetSpec().DeletionPolicy == cloudobject.DeletionOrphanOnMismatch:
+				r.Recorder.Event(obj, corev1.EventTypeWarning, Conflict, fmt.Sprintf("orphaning object in object store: %s", msg))
+				log.Info("orphaning resource in object store", "key", printReference(obj), "reason", msg)
+			case !deletable:
+				// keep the finalizer rather than destroying data
+				// this controller did not write
+				err = errors.Errorf("refusing to delete from object store: %s", msg)
+				return
+			}
+
+			if r.isDryRun(obj) {
+				if deletable {
+					controllerError = r.reportDryRun(ctx, obj, &cloudobject.DryRunStatus{
+						Action:    Delete,
+						Reference: storeReference(obj.GetSpec().Target),
+					})
+				}
+				return
+			}
+
+			if !deletable {
+				// previous versions are only removed along with the
+				// stored object
+				return
+			}
+			if err = objectStore.Delete(ctx, obj.GetSpec().Target); err != nil {
 				return
 			}
 			log.Info("successfully deleted resource", "key", printReference(obj))
-		case Retain:
+			if err = r.deleteVersions(ctx, objectStore, obj); err != nil {
+				return
+			}
+		case cloudobject.DeletionRetain:
 			log.Info("retaining the object in the object store")
 			// do nothing
 		default:
-			err = errors.Errorf("invalid deletionPolicy %s", obj.Spec.DeletionPolicy)
+			err = errors.Errorf("invalid deletionPolicy %s", obj.GetSpec().DeletionPolicy)
 		}
 	}
 
 	return nil
 }
 
-func (r *ObjectReconciler) processError(ctx context.Context, obj *cloudobject.Object, action Action, processingError *error) error {
+func (r *ObjectReconciler) processError(ctx context.Context, obj cloudobject.Storable, action Action, processingError *error) error {
 	pe := *processingError
 	if pe == nil {
 		return nil
@@ -182,12 +444,19 @@ func (r *ObjectReconciler) processError(ctx context.Context, obj *cloudobject.Ob
 	log := log.FromContext(ctx)
 	log.Error(pe, "failed to sync resource")
 
-	obj.Status.Synced = false
-	obj.Status.Reference = ""
-	r.Recorder.Event(obj, corev1.EventTypeWarning, Failed, pe.Error())
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(pe)
+	span.SetStatus(codes.Error, pe.Error())
+
+	reason := failureReason(pe, Failed)
+	obj.GetStatus().Synced = false
+	obj.GetStatus().Reference = ""
+	setSynced(obj, metav1.ConditionFalse, reason, pe.Error())
+	r.Recorder.Event(obj, corev1.EventTypeWarning, reason, pe.Error())
 	if err := r.Status().Update(ctx, obj); err != nil {
 		return err
 	}
+	objectStates.set(client.ObjectKeyFromObject(obj), false)
 
 	// fail reconciler and prevent resource deletion
 	// if along the way deleting the remote object fails
@@ -198,54 +467,253 @@ func (r *ObjectReconciler) processError(ctx context.Context, obj *cloudobject.Ob
 	return nil
 }
 
-func (r *ObjectReconciler) pullSecret(ctx context.Context, obj *cloudobject.Object) ([]byte, error) {
-	creds := obj.Spec.Credentials
+// objectStore returns the object store of target with the given credentials
+func (r *ObjectReconciler) objectStore(ctx context.Context, creds cloudobject.Credentials, target cloudobject.ObjectTarget) (ctrlapi.ObjectStore, error) {
+	secretData, err := PullCredentials(ctx, r, creds)
+	if err != nil {
+		credentialFailuresTotal.Inc()
+		return nil, err
+	}
+
+	storeConfig := ctrlapi.ConfigData{
+		Secret:    secretData,
+		Region:    target.Region,
+		Limits:    r.limits(target),
+		Endpoints: r.Endpoints,
+	}
+	return withTracing(withMetrics(r.StoreManager.Get(storeConfig), ProviderAWS)), nil
+}
+
+// limits returns the default limits overridden by the rate limit of the target
+func (r *ObjectReconciler) limits(target cloudobject.ObjectTarget) ctrlapi.Limits {
+	limits := r.Limits
+	rateLimit := target.RateLimit
+	if rateLimit == nil {
+		return limits
+	}
+
+	if rateLimit.RequestsPerSecond > 0 {
+		limits.RequestsPerSecond = float64(rateLimit.RequestsPerSecond)
+	}
+	if rateLimit.Burst > 0 {
+		limits.Burst = rateLimit.Burst
+	}
+	if rateLimit.MaxAttempts > 0 {
+		limits.MaxAttempts = rateLimit.MaxAttempts
+	}
+	if rateLimit.RetryMode != "" {
+		limits.RetryMode = rateLimit.RetryMode
+	}
+	return limits
+}
+
+// failureReason returns the stable reason classifying err, or fallback if
+// err was not classified by the object store
+func failureReason(err error, fallback string) string {
+	if errors.Is(err, format.ErrUnparseable) || errors.Is(err, format.ErrUnsupported) {
+		return ReasonConversionFailed
+	}
+	var upload *uploadError
+	if errors.As(err, &upload) {
+		return UploadFailed
+	}
+	if reason := ctrlapi.ReasonOf(err); reason != ctrlapi.ReasonUnknown {
+		return reason
+	}
+	return fallback
+}
+
+func setSynced(obj cloudobject.Storable, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&obj.GetStatus().Conditions, metav1.Condition{
+		Type:               ConditionSynced,
+		Status:             status,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             reason,
+		Message:            msg,
+	})
+}
+
+// PullSecret returns the credentials of the object from its secret
+func PullSecret(ctx context.Context, c client.Reader, obj cloudobject.Storable) ([]byte, error) {
+	return PullCredentials(ctx, c, obj.GetSpec().Credentials)
+}
+
+// PullCredentials returns the credentials of an object store from their secret
+func PullCredentials(ctx context.Context, c client.Reader, creds cloudobject.Credentials) (data []byte, err error) {
+	ctx, span := startSpan(ctx, "pullSecret")
+	defer func() { endSpan(span, err) }()
+
 	if creds.Source != "" && creds.Source != "Secret" {
 		return nil, errors.Errorf("wrong source %s", creds.Source)
 	}
+	return SecretKey(ctx, c, creds.SecretReference)
+}
 
+// SecretKey returns the value of a secret key
+func SecretKey(ctx context.Context, c client.Reader, ref cloudobject.SecretKeySelector) ([]byte, error) {
 	var secret corev1.Secret
-	secretRef := types.NamespacedName{Namespace: creds.SecretReference.Namespace, Name: creds.SecretReference.Name}
-	if err := r.Get(ctx, secretRef, &secret); err != nil {
-		return nil, errors.Errorf("%s %s:%s", err.Error(), creds.SecretReference.Namespace, creds.SecretReference.Name)
+	secretRef := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if err := c.Get(ctx, secretRef, &secret); err != nil {
+		return nil, errors.Errorf("%s %s:%s", err.Error(), ref.Namespace, ref.Name)
 	}
 
-	secretData, ok := secret.Data[creds.SecretReference.Key]
+	data, ok := secret.Data[ref.Key]
 	if !ok {
-		return nil, errors.Errorf("key not found %s", creds.SecretReference.Key)
+		return nil, errors.Errorf("key not found %s", ref.Key)
 	}
-	return secretData, nil
+	return data, nil
 }
 
-func (r *ObjectReconciler) extractData(ctx context.Context, obj *cloudobject.Object) ([]byte, error) {
-	src := obj.Spec.Source
-	switch strings.ToLower(src.Reference) {
-	case Local, Empty:
-		if src.Data == "" {
-			return nil, errors.New("data field required for a 'local' reference")
+// ExtractData returns the content of the object from its source, converted
+// to its output format. Templates are rendered with the given cluster info.
+func ExtractData(ctx context.Context, c client.Reader, obj cloudobject.Storable, cluster ClusterInfo) ([]byte, error) {
+	data, err := ExtractSource(ctx, c, obj, obj.GetSpec().Source, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return convert(data, obj.GetSpec().Output)
+}
+
+// ExtractSource returns the content of src, read on behalf of owner.
+// Templates are rendered with the given cluster info.
+func ExtractSource(ctx context.Context, c client.Reader, owner client.Object, src cloudobject.ObjectSource, cluster ClusterInfo) ([]byte, error) {
+	data, _, err := extractSource(ctx, c, owner, src, "", cluster)
+	return data, err
+}
+
+// extractSource returns the content of src along with its revision, read on
+// behalf of owner. Remote sources return remote.ErrNotModified while their
+// revision is unchanged, in-cluster sources have no revision.
+func extractSource(ctx context.Context, c client.Reader, owner client.Object, src cloudobject.ObjectSource, revision string, cluster ClusterInfo) (data []byte, newRevision string, err error) {
+	ctx, span := startSpan(ctx, "extractData", attribute.String("source.type", string(src.Type)))
+	defer func() { endSpan(span, err) }()
+
+	switch src.Type {
+	case cloudobject.SourceInline, Empty:
+		if src.Inline == nil || src.Inline.Data == "" {
+			return nil, Empty, errors.New("inline data required for an 'Inline' source")
+		}
+		if src.Inline.Template != nil {
+			data, err = renderTemplate(ctx, c, owner, src.Inline, cluster)
+			return data, Empty, err
 		}
-		return []byte(src.Data), nil
+		return []byte(src.Inline.Data), Empty, nil
 
-	case ConfigMap:
+	case cloudobject.SourceConfigMap:
+		if src.ConfigMap == nil {
+			return nil, Empty, errors.New("configMap required for a 'ConfigMap' source")
+		}
+		ref := src.ConfigMap
+		namespace, err := sourceNamespace(owner, ref.Namespace)
+		if err != nil {
+			return nil, Empty, err
+		}
 		var cm corev1.ConfigMap
-		dataRef := types.NamespacedName{Namespace: src.Namespace, Name: src.Name}
-		if err := r.Get(ctx, dataRef, &cm); err != nil {
-			return nil, errors.Errorf("unrecognized configmap %s:%s", src.Namespace, src.Name)
+		dataRef := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+		if err := c.Get(ctx, dataRef, &cm); err != nil {
+			return nil, Empty, errors.Errorf("unrecognized configmap %s:%s", dataRef.Namespace, dataRef.Name)
 		}
-		data, ok := cm.Data[src.Key]
-		if !ok || src.Key == "" {
-			return nil, errors.Errorf("key not found %s", src.Key)
+		data, ok := cm.Data[ref.Key]
+		if !ok || ref.Key == "" {
+			return nil, Empty, errors.Errorf("key not found %s", ref.Key)
 		}
-		return []byte(data), nil
+		return []byte(data), Empty, nil
+
+	case cloudobject.SourceResource:
+		if src.Resource == nil {
+			return nil, Empty, errors.New("resource required for a 'Resource' source")
+		}
+		data, err = resourceManifest(ctx, c, owner, src.Resource)
+		return data, Empty, err
+
+	case cloudobject.SourceHTTP:
+		if src.HTTP == nil {
+			return nil, Empty, errors.New("http required for an 'HTTP' source")
+		}
+		return fetchURL(ctx, c, owner, src.HTTP, revision)
+
+	case cloudobject.SourceOCI:
+		if src.OCI == nil {
+			return nil, Empty, errors.New("oci required for an 'OCI' source")
+		}
+		return pullArtifact(ctx, c, owner, src.OCI, revision)
+
+	case cloudobject.SourcePVC:
+		return nil, Empty, errors.New("pvc sources are only readable by their upload job")
+
+	case cloudobject.SourceS3:
+		return nil, Empty, errors.New("s3 sources are only readable through the object store")
 
 	default:
-		return nil, errors.Errorf("source invalid")
+		return nil, Empty, errors.Errorf("source invalid")
+	}
+}
+
+// store uploads the content of obj into target, creating the target bucket
+// first if it is missing and the target opted into it
+func (r *ObjectReconciler) store(ctx context.Context, objectStore ctrlapi.ObjectStore, obj client.Object, target cloudobject.ObjectTarget, data []byte) (ctrlapi.ObjectInfo, error) {
+	info, err := objectStore.Store(ctx, data, target, ownerMetadata(obj))
+	if !errors.Is(err, ctrlapi.ErrBucketNotFound) || !target.CreateBucketIfMissing || !r.enabled(FeatureBucketCreation) {
+		return info, err
+	}
+
+	if err := objectStore.CreateBucket(ctx, target); err != nil {
+		return info, err
+	}
+	r.Recorder.Event(obj, corev1.EventTypeNormal, BucketCreated, fmt.Sprintf("created bucket %s in %s", target.Bucket, target.Region))
+	log.FromContext(ctx).Info("created bucket", "bucket", target.Bucket, "region", target.Region)
+
+	return objectStore.Store(ctx, data, target, ownerMetadata(obj))
+}
+
+func (r *ObjectReconciler) isSuspended(ctx context.Context, obj cloudobject.Storable) bool {
+	if obj.GetSpec().Suspend {
+		log.FromContext(ctx).Info("object is suspended, skipping object store operations", "key", client.ObjectKeyFromObject(obj))
+	}
+	return obj.GetSpec().Suspend
+}
+
+// resyncRequested reports whether the resync annotation changed since it was last handled
+func (r *ObjectReconciler) resyncRequested(obj cloudobject.Storable) bool {
+	resyncAt, ok := obj.GetAnnotations()[ResyncAnnotation]
+	return ok && resyncAt != obj.GetStatus().LastHandledResyncAt
+}
+
+func (r *ObjectReconciler) isDryRun(obj cloudobject.Storable) bool {
+	return r.DryRun || obj.GetSpec().DryRun
+}
+
+// reportDryRun records the planned operation in the object status and events
+// instead of performing it
+func (r *ObjectReconciler) reportDryRun(ctx context.Context, obj cloudobject.Storable, plan *cloudobject.DryRunStatus) error {
+	obj.GetStatus().DryRun = plan
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("dry run: would %s %s", plan.Action, plan.Reference)
+	r.Recorder.Event(obj, corev1.EventTypeNormal, DryRun, msg)
+	log.FromContext(ctx).Info(msg, "size", plan.Size)
+	return nil
+}
+
+func storeReference(target cloudobject.ObjectTarget) string {
+	return fmt.Sprintf("s3://%s/%s", target.Bucket, target.Key)
+}
+
+// versionedBucket returns the target bucket if the stored object got a
+// version ID, the null version of a bucket with suspended versioning does
+// not count
+func versionedBucket(target cloudobject.ObjectTarget, info ctrlapi.ObjectInfo) string {
+	if info.VersionID == "" || info.VersionID == "null" {
+		return ""
 	}
+	return target.Bucket
 }
 
-func printReference(obj *cloudobject.Object) string {
+func printReference(obj cloudobject.Storable) string {
 	return fmt.Sprintf("%s -> %s:%s",
-		obj.Name,
-		obj.Spec.Target.Bucket, obj.Spec.Target.Key,
+		obj.GetName(),
+		obj.GetSpec().Target.Bucket, obj.GetSpec().Target.Key,
 	)
 }
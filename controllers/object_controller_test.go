@@ -18,15 +18,30 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	cloudobj "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 var _ = Describe("Object controller", func() {
@@ -48,15 +63,52 @@ var _ = Describe("Object controller", func() {
 		createdObject   *cloudobj.Object
 	)
 
+	createCredentialsSecret := func(extra map[string][]byte) {
+		data := map[string][]byte{
+			"creds-key": []byte("c29tZS1kYXRh"),
+		}
+		for key, value := range extra {
+			data[key] = value
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      SecretName,
+				Namespace: Namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+	}
+
+	deleteObject := func(key types.NamespacedName) {
+		obj := &cloudobj.Object{}
+		Expect(k8sClient.Get(ctx, key, obj)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, key, obj)
+			return err == nil
+		}, timeout, interval).Should(BeFalse())
+	}
+
+	deleteCredentialsSecret := func() {
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, secretLookupKey, secret)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+	}
+
+	deleteObjectAndSecret := func() {
+		deleteObject(objLookupKey)
+		deleteCredentialsSecret()
+	}
+
 	Context("with secret present", func() {
 		AfterEach(func() {
-			// delete object
+			// delete object, unless the test deleted it already
 			obj := &cloudobj.Object{}
-			Eventually(func() bool {
-				err := k8sClient.Get(ctx, objLookupKey, obj)
-				return err == nil
-			}, timeout, interval).Should(BeTrue())
-			Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+			if err := k8sClient.Get(ctx, objLookupKey, obj); err == nil {
+				_ = k8sClient.Delete(ctx, obj)
+			}
 			Eventually(func() bool {
 				err := k8sClient.Get(ctx, objLookupKey, obj)
 				return err == nil
@@ -98,7 +150,7 @@ var _ = Describe("Object controller", func() {
 			ctx = context.Background()
 			obj := &cloudobj.Object{
 				TypeMeta: metav1.TypeMeta{
-					APIVersion: "s3.aws.dev.nimak.link/v1alpha1",
+					APIVersion: "s3.aws.dev.nimak.link/v1beta1",
 					Kind:       "Object",
 				},
 				ObjectMeta: metav1.ObjectMeta{
@@ -113,7 +165,7 @@ var _ = Describe("Object controller", func() {
 						Key:    "test.key",
 					},
 					Source: cloudobj.ObjectSource{
-						Data: "test-data",
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
 					},
 					Credentials: cloudobj.Credentials{
 						Source: "Secret",
@@ -145,21 +197,37 @@ var _ = Describe("Object controller", func() {
 
 			By("uses ObjectStore to save content")
 			Eventually(fakeObjectStore.StoreCallCount, timeout, interval).Should(BeNumerically(">", 0))
-			Eventually(func() (string, error) {
-				_, data, _ := fakeObjectStore.StoreArgsForCall(0)
-				return string(data), nil
-			}, timeout, interval).Should(Equal("test-data"))
+			Eventually(func() []string {
+				return storedContents("test-bucket", "test.key")
+			}, timeout, interval).Should(ContainElement("test-data"))
 
 			By("retrieving the object after storing the data")
 			updatedObject := &cloudobj.Object{}
 			Eventually(func() bool {
 				err := k8sClient.Get(ctx, objLookupKey, updatedObject)
-				return err == nil
+				return err == nil && updatedObject.Status.Synced
 			}, timeout, interval).Should(BeTrue())
 
 			By("object status should reflect updates")
 			Expect(updatedObject.Status.Synced).To(BeTrue())
 			Expect(updatedObject.Status.Reference).Should(Equal("s3://test-bucket/test.key"))
+
+			By("recording upload metrics")
+			Expect(testutil.ToFloat64(uploadsTotal.WithLabelValues(resultSuccess, ProviderAWS))).To(BeNumerically(">", 0))
+			Expect(testutil.ToFloat64(uploadedBytesTotal.WithLabelValues(ProviderAWS))).To(BeNumerically(">=", len("test-data")))
+			// the gauge is published once the status is updated
+			Eventually(func() float64 {
+				return testutil.ToFloat64(objectsGauge.WithLabelValues("true"))
+			}, timeout, interval).Should(BeNumerically(">", 0))
+
+			By("tracing the reconciliation")
+			spanNames := map[string]bool{}
+			for _, span := range spanExporter.GetSpans() {
+				spanNames[span.Name] = true
+			}
+			for _, name := range []string{"Reconcile", "pullSecret", "StoreManager.Get", "extractData", "ObjectStore.Store"} {
+				Expect(spanNames).To(HaveKey(name))
+			}
 		})
 
 		It("should successfully try to delete the object", func() {
@@ -185,7 +253,7 @@ var _ = Describe("Object controller", func() {
 			ctx = context.Background()
 			obj := &cloudobj.Object{
 				TypeMeta: metav1.TypeMeta{
-					APIVersion: "s3.aws.dev.nimak.link/v1alpha1",
+					APIVersion: "s3.aws.dev.nimak.link/v1beta1",
 					Kind:       "Object",
 				},
 				ObjectMeta: metav1.ObjectMeta{
@@ -200,7 +268,7 @@ var _ = Describe("Object controller", func() {
 						Key:    "test.key",
 					},
 					Source: cloudobj.ObjectSource{
-						Data: "test-data",
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
 					},
 					Credentials: cloudobj.Credentials{
 						Source: "Secret",
@@ -236,71 +304,1537 @@ var _ = Describe("Object controller", func() {
 			By("uses ObjectStore to save content")
 			Eventually(fakeObjectStore.DeleteCallCount, timeout, interval).Should(BeNumerically(">", 0))
 			Eventually(func() bool {
-				_, target := fakeObjectStore.DeleteArgsForCall(0)
-				return target.Bucket == "test-bucket"
+				for i := 0; i < fakeObjectStore.DeleteCallCount(); i++ {
+					_, target := fakeObjectStore.DeleteArgsForCall(i)
+					if target.Bucket == "test-bucket" && target.Key == "test.key" {
+						return true
+					}
+				}
+				return false
 			}, timeout, interval).Should(BeTrue())
 		})
 	})
 
-	Context("without secret present", func() {
+	Context("with history enabled", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+		})
+
+		AfterEach(func() {
+			deleteObjectAndSecret()
+		})
+
+		It("should store versioned keys and prune old versions", func() {
+			By("submitting an object keeping a single version")
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "test-bucket",
+						Key:    "history.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: "first"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+					History: &cloudobj.ObjectHistory{
+						Mode:  cloudobj.HistoryKey,
+						Limit: 1,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+			By("recording the first version in the status")
+			var first cloudobj.ObjectVersion
+			Eventually(func() int {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return 0
+				}
+				if len(updated.Status.Versions) > 0 {
+					first = updated.Status.Versions[0]
+				}
+				return len(updated.Status.Versions)
+			}, timeout, interval).Should(Equal(1))
+			Expect(first.Key).To(HavePrefix("history.key."))
+			Expect(first.Checksum).NotTo(BeEmpty())
+
+			By("changing the content of the object")
+			Eventually(func() error {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return err
+				}
+				updated.Spec.Source.Inline.Data = "second"
+				return k8sClient.Update(ctx, updated)
+			}, timeout, interval).Should(Succeed())
+
+			By("pruning the first version")
+			Eventually(func() bool {
+				for i := 0; i < fakeObjectStore.DeleteCallCount(); i++ {
+					_, target := fakeObjectStore.DeleteArgsForCall(i)
+					if target.Key == first.Key {
+						return true
+					}
+				}
+				return false
+			}, timeout, interval).Should(BeTrue())
+
+			updated := &cloudobj.Object{}
+			Eventually(func() string {
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil || len(updated.Status.Versions) == 0 {
+					return ""
+				}
+				return updated.Status.Versions[0].Checksum
+			}, timeout, interval).ShouldNot(Equal(first.Checksum))
+			Expect(updated.Status.Versions).To(HaveLen(1))
+		})
+	})
+
+	Context("in dry run mode", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+		})
+
 		AfterEach(func() {
-			// delete object
+			By("not deleting the object from the object store")
+			for i := 0; i < fakeObjectStore.DeleteCallCount(); i++ {
+				_, target := fakeObjectStore.DeleteArgsForCall(i)
+				Expect(target.Key).NotTo(Equal("dryrun.key"))
+			}
+
+			By("releasing the object by removing its finalizer")
 			obj := &cloudobj.Object{}
+			Expect(k8sClient.Get(ctx, objLookupKey, obj)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, objLookupKey, obj); err != nil {
+					return err
+				}
+				controllerutil.RemoveFinalizer(obj, ObjectFinalizer)
+				return k8sClient.Update(ctx, obj)
+			}, timeout, interval).Should(Succeed())
 			Eventually(func() bool {
 				err := k8sClient.Get(ctx, objLookupKey, obj)
 				return err == nil
-			}, timeout, interval).Should(BeTrue())
-			Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+			}, timeout, interval).Should(BeFalse())
+
+			deleteCredentialsSecret()
 		})
 
-		It("should successfully try to store the object", func() {
-			By("having the new Object defined")
-			ctx = context.Background()
+		It("should report the planned upload without storing the object", func() {
+			By("submitting an object with dry run enabled")
 			obj := &cloudobj.Object{
-				TypeMeta: metav1.TypeMeta{
-					APIVersion: "s3.aws.dev.nimak.link/v1alpha1",
-					Kind:       "Object",
-				},
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      ObjName,
 					Namespace: Namespace,
 				},
 				Spec: cloudobj.ObjectSpec{
 					DeletionPolicy: "Delete",
+					DryRun:         true,
 					Target: cloudobj.ObjectTarget{
 						Region: "us-west-2",
 						Bucket: "test-bucket",
-						Key:    "test.key",
+						Key:    "dryrun.key",
 					},
 					Source: cloudobj.ObjectSource{
-						Data: "test-data",
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
 					},
 					Credentials: cloudobj.Credentials{
 						Source: "Secret",
 						SecretReference: cloudobj.SecretKeySelector{
 							SecretReference: cloudobj.SecretReference{
-								Namespace: "default",
-								Name:      "creds-name",
+								Namespace: Namespace,
+								Name:      SecretName,
 							},
 							Key: "creds-key",
 						},
 					},
 				},
 			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
 
-			By("submitting the new object")
+			By("reporting the plan in the status")
+			updated := &cloudobj.Object{}
+			Eventually(func() *cloudobj.DryRunStatus {
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return nil
+				}
+				return updated.Status.DryRun
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(updated.Status.DryRun.Action).To(Equal("store"))
+			Expect(updated.Status.DryRun.Reference).To(Equal("s3://test-bucket/dryrun.key"))
+			Expect(updated.Status.DryRun.Size).To(Equal(len("test-data")))
+			Expect(updated.Status.Synced).To(BeFalse())
+
+			By("not storing the object")
+			for i := 0; i < fakeObjectStore.StoreCallCount(); i++ {
+				_, _, target, _ := fakeObjectStore.StoreArgsForCall(i)
+				Expect(target.Key).NotTo(Equal("dryrun.key"))
+			}
+
+			By("reporting the planned deletion while keeping the finalizer")
+			Expect(k8sClient.Delete(ctx, updated)).Should(Succeed())
+			Eventually(func() string {
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil || updated.Status.DryRun == nil {
+					return ""
+				}
+				return updated.Status.DryRun.Action
+			}, timeout, interval).Should(Equal("delete"))
+			Consistently(func() []string {
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return nil
+				}
+				return updated.Finalizers
+			}, time.Second*2, interval).Should(ContainElement(ObjectFinalizer))
+		})
+	})
+
+	Context("when suspended", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+		})
+
+		AfterEach(func() {
+			deleteObjectAndSecret()
+		})
+
+		storeCalls := func(key string) int {
+			calls := 0
+			for i := 0; i < fakeObjectStore.StoreCallCount(); i++ {
+				_, _, target, _ := fakeObjectStore.StoreArgsForCall(i)
+				if target.Key == key {
+					calls++
+				}
+			}
+			return calls
+		}
+
+		It("should only store the object once resumed and on resync requests", func() {
+			By("submitting a suspended object")
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Suspend:        true,
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "test-bucket",
+						Key:    "suspend.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+					History: &cloudobj.ObjectHistory{},
+				},
+			}
 			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
 
-			By("waiting for the object to become available")
-			createdObject = &cloudobj.Object{}
+			By("retaining the finalizer without storing the object")
+			Eventually(func() []string {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return nil
+				}
+				return updated.Finalizers
+			}, timeout, interval).Should(ContainElement(ObjectFinalizer))
+			Consistently(func() int { return storeCalls("suspend.key") }, time.Second*2, interval).Should(BeZero())
+
+			By("resuming the object")
+			Eventually(func() error {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return err
+				}
+				updated.Spec.Suspend = false
+				return k8sClient.Update(ctx, updated)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() int { return storeCalls("suspend.key") }, timeout, interval).Should(Equal(1))
+
+			By("requesting a resync of the unchanged content")
+			Eventually(func() error {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return err
+				}
+				updated.Annotations = map[string]string{ResyncAnnotation: "2021-12-20T10:00:00Z"}
+				return k8sClient.Update(ctx, updated)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() int { return storeCalls("suspend.key") }, timeout, interval).Should(Equal(2))
+			Eventually(func() string {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return ""
+				}
+				return updated.Status.LastHandledResyncAt
+			}, timeout, interval).Should(Equal("2021-12-20T10:00:00Z"))
+		})
+	})
+
+	Context("with two objects targeting the same key", func() {
+		const OtherName = "other-obj"
+		otherLookupKey := types.NamespacedName{Name: OtherName, Namespace: Namespace}
+
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+		})
+
+		AfterEach(func() {
+			deleteObject(otherLookupKey)
+			deleteObjectAndSecret()
+		})
+
+		newObject := func(name string) *cloudobj.Object {
+			return &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "test-bucket",
+						Key:    "conflict.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: name},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+		}
+
+		It("should only let the first object store into the target", func() {
+			By("submitting the first object")
+			Expect(k8sClient.Create(ctx, newObject(ObjName))).Should(Succeed())
 			Eventually(func() bool {
-				err := k8sClient.Get(ctx, objLookupKey, createdObject)
+				obj := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, obj); err != nil {
+					return false
+				}
+				return obj.Status.Synced
+			}, timeout, interval).Should(BeTrue())
+
+			By("submitting a second object with the same target")
+			// creation timestamps have a resolution of a second
+			time.Sleep(time.Second)
+			Expect(k8sClient.Create(ctx, newObject(OtherName))).Should(Succeed())
+			var condition *metav1.Condition
+			Eventually(func() *metav1.Condition {
+				obj := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, otherLookupKey, obj); err != nil {
+					return nil
+				}
+				condition = meta.FindStatusCondition(obj.Status.Conditions, ConditionConflict)
+				return condition
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(ReasonTargetInUse))
+
+			By("never storing the content of the second object")
+			for i := 0; i < fakeObjectStore.StoreCallCount(); i++ {
+				_, _, _, metadata := fakeObjectStore.StoreArgsForCall(i)
+				Expect(metadata[OwnerMetadataKey]).NotTo(Equal(Namespace + "/" + OtherName))
+			}
+		})
+	})
+
+	Context("when the stored object was modified", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+
+			fakeObjectStore.StoreReturns(ctrlapi.ObjectInfo{ETag: `"stored"`, VersionID: "v1"}, nil)
+			fakeObjectStore.HeadCalls(func(c context.Context, target cloudobj.ObjectTarget) (ctrlapi.ObjectInfo, error) {
+				info, err := headLastStored(c, target)
+				info.ETag = `"overwritten"`
+				return info, err
+			})
+		})
+
+		AfterEach(func() {
+			fakeObjectStore.StoreReturns(ctrlapi.ObjectInfo{}, nil)
+			fakeObjectStore.HeadCalls(headLastStored)
+
+			deleteCredentialsSecret()
+		})
+
+		// deleteCalls counts the deletions of key and of its previous versions
+		deleteCalls := func(key string) int {
+			calls := 0
+			for i := 0; i < fakeObjectStore.DeleteCallCount(); i++ {
+				_, target := fakeObjectStore.DeleteArgsForCall(i)
+				if strings.HasPrefix(target.Key, key) {
+					calls++
+				}
+			}
+			return calls
+		}
+
+		It("should keep the stored object and only release it when orphaning on mismatch", func() {
+			By("submitting an object with the delete policy")
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Delete",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "test-bucket",
+						Key:    "mismatch.key",
+					},
+					History: &cloudobj.ObjectHistory{Mode: cloudobj.HistoryKey, Limit: 5},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+			Eventually(func() string {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return ""
+				}
+				if len(updated.Status.Versions) == 0 {
+					return ""
+				}
+				return updated.Status.ETag
+			}, timeout, interval).Should(Equal(`"stored"`))
+
+			By("recording the versioning of the bucket shown by the version ID")
+			Expect(k8sClient.Get(ctx, objLookupKey, obj)).Should(Succeed())
+			Expect(obj.Status.VersionedBucket).To(Equal("test-bucket"))
+
+			By("refusing to delete the overwritten object and its previous versions")
+			Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+			Consistently(func() error {
+				return k8sClient.Get(ctx, objLookupKey, &cloudobj.Object{})
+			}, time.Second*2, interval).Should(Succeed())
+			Expect(deleteCalls("mismatch.key")).To(BeZero())
+
+			By("orphaning the overwritten object and its previous versions")
+			Eventually(func() error {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return err
+				}
+				updated.Spec.DeletionPolicy = cloudobj.DeletionOrphanOnMismatch
+				return k8sClient.Update(ctx, updated)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, objLookupKey, &cloudobj.Object{})
 				return err == nil
+			}, timeout, interval).Should(BeFalse())
+			Expect(deleteCalls("mismatch.key")).To(BeZero())
+		})
+	})
+
+	Context("when the secret is gone before the object", func() {
+		It("should only remove the finalizer once force-delete is requested", func() {
+			By("submitting an object with its secret")
+			createCredentialsSecret(nil)
+
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Delete",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "test-bucket",
+						Key:    "stuck.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+			Eventually(func() bool {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return false
+				}
+				return updated.Status.Synced
 			}, timeout, interval).Should(BeTrue())
 
-			By("object status should reflect updates")
-			Expect(createdObject.Status.Synced).To(BeFalse())
-			Expect(createdObject.Status.Reference).Should(BeEmpty())
+			By("deleting the secret and then the object")
+			deleteCredentialsSecret()
+			Expect(k8sClient.Get(ctx, objLookupKey, obj)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+			Eventually(func() int {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return 0
+				}
+				return updated.Status.DeletionAttempts
+			}, timeout, interval).Should(BeNumerically(">", 0))
+			Expect(k8sClient.Get(ctx, objLookupKey, &cloudobj.Object{})).Should(Succeed())
+
+			By("requesting a forced deletion")
+			Eventually(func() error {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return err
+				}
+				updated.Annotations = map[string]string{ForceDeleteAnnotation: "true"}
+				return k8sClient.Update(ctx, updated)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, objLookupKey, &cloudobj.Object{})
+				return err == nil
+			}, timeout, interval).Should(BeFalse())
 		})
 	})
-})
+
+	Context("when the bucket is missing", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+
+			fakeObjectStore.StoreCalls(func(context.Context, []byte, cloudobj.ObjectTarget, map[string]string) (ctrlapi.ObjectInfo, error) {
+				if fakeObjectStore.CreateBucketCallCount() == 0 {
+					return ctrlapi.ObjectInfo{}, &ctrlapi.Error{Reason: ctrlapi.ReasonBucketNotFound, Message: "bucket missing-bucket not found"}
+				}
+				return ctrlapi.ObjectInfo{}, nil
+			})
+		})
+
+		AfterEach(func() {
+			fakeObjectStore.StoreReturns(ctrlapi.ObjectInfo{}, nil)
+
+			deleteObjectAndSecret()
+		})
+
+		It("should create the bucket once the object opts into it", func() {
+			By("submitting an object without bucket creation")
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "missing-bucket",
+						Key:    "test.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+			Consistently(func() bool {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return false
+				}
+				return updated.Status.Synced
+			}, time.Second*2, interval).Should(BeFalse())
+			Expect(fakeObjectStore.CreateBucketCallCount()).To(BeZero())
+
+			By("opting into bucket creation")
+			Eventually(func() error {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return err
+				}
+				updated.Spec.Target.CreateBucketIfMissing = true
+				return k8sClient.Update(ctx, updated)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return false
+				}
+				return updated.Status.Synced
+			}, timeout, interval).Should(BeTrue())
+			Expect(fakeObjectStore.CreateBucketCallCount()).To(Equal(1))
+			_, target := fakeObjectStore.CreateBucketArgsForCall(0)
+			Expect(target.Bucket).To(Equal("missing-bucket"))
+		})
+	})
+
+	Context("with an HTTP source", func() {
+		var (
+			server *httptest.Server
+			etag   atomic.Value
+		)
+
+		BeforeEach(func() {
+			createCredentialsSecret(map[string][]byte{"authorization": []byte("Bearer mirror-token")})
+
+			etag.Store(`"v1"`)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer mirror-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				current := etag.Load().(string)
+				if r.Header.Get("If-None-Match") == current {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", current)
+				_, _ = w.Write([]byte("content " + current))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+
+			deleteObjectAndSecret()
+		})
+
+		It("should only store the content when its ETag changes", func() {
+			storeCalls := len(storedContents("mirror-bucket", "test.key"))
+
+			By("submitting an object polling the URL every second")
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "mirror-bucket",
+						Key:    "test.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourceHTTP,
+						HTTP: &cloudobj.HTTPSource{
+							URL:           server.URL + "/app.yaml",
+							AuthSecretRef: &cloudobj.SourceSecretKeySelector{Name: SecretName, Key: "authorization"},
+							Interval:      &metav1.Duration{Duration: time.Second},
+						},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+			Eventually(func() string {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return ""
+				}
+				return updated.Status.SourceRevision
+			}, timeout, interval).Should(Equal(`"v1"`))
+			stored := storedContents("mirror-bucket", "test.key")
+			Expect(len(stored)).To(BeNumerically(">", storeCalls))
+			Expect(stored[len(stored)-1]).To(Equal(`content "v1"`))
+
+			By("polling the unchanged URL")
+			storeCalls = len(stored)
+			Consistently(func() []string { return storedContents("mirror-bucket", "test.key") }, time.Second*3, interval).Should(HaveLen(storeCalls))
+
+			By("changing the content served at the URL")
+			etag.Store(`"v2"`)
+			Eventually(func() string {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return ""
+				}
+				return updated.Status.SourceRevision
+			}, timeout, interval).Should(Equal(`"v2"`))
+			stored = storedContents("mirror-bucket", "test.key")
+			Expect(len(stored)).To(BeNumerically(">", storeCalls))
+			Expect(stored[len(stored)-1]).To(Equal(`content "v2"`))
+		})
+
+		It("should refuse to send a secret of another namespace", func() {
+			storeCalls := len(storedContents("mirror-bucket", "foreign.key"))
+
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "mirror-bucket",
+						Key:    "foreign.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourceHTTP,
+						HTTP: &cloudobj.HTTPSource{
+							URL:           server.URL + "/app.yaml",
+							AuthSecretRef: &cloudobj.SourceSecretKeySelector{Namespace: "kube-system", Name: SecretName, Key: "authorization"},
+						},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+			var condition *metav1.Condition
+			Eventually(func() *metav1.Condition {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return nil
+				}
+				condition = meta.FindStatusCondition(updated.Status.Conditions, ConditionSynced)
+				return condition
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("namespace kube-system is not allowed"))
+			Expect(storedContents("mirror-bucket", "foreign.key")).To(HaveLen(storeCalls))
+		})
+	})
+
+	Context("with an output format", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+		})
+
+		AfterEach(func() {
+			deleteObjectAndSecret()
+		})
+
+		newConvertedObject := func(data string) *cloudobj.Object {
+			return &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "converted-bucket",
+						Key:    "settings.json",
+					},
+					Source: cloudobj.ObjectSource{
+						Type:   cloudobj.SourceInline,
+						Inline: &cloudobj.InlineSource{Data: data},
+					},
+					Output: &cloudobj.ObjectOutput{Format: cloudobj.OutputJSON},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+		}
+
+		It("should store the source converted to the output format", func() {
+			storeCalls := len(storedContents("converted-bucket", "settings.json"))
+
+			Expect(k8sClient.Create(ctx, newConvertedObject("name: web\nreplicas: 3\n"))).Should(Succeed())
+			Eventually(func() bool {
+				obj := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, obj); err != nil {
+					return false
+				}
+				return obj.Status.Synced
+			}, timeout, interval).Should(BeTrue())
+
+			stored := storedContents("converted-bucket", "settings.json")
+			Expect(len(stored)).To(BeNumerically(">", storeCalls))
+			Expect(stored[len(stored)-1]).To(Equal("{\n  \"name\": \"web\",\n  \"replicas\": 3\n}\n"))
+		})
+
+		It("should report a source which cannot be parsed", func() {
+			storeCalls := len(storedContents("converted-bucket", "settings.json"))
+
+			Expect(k8sClient.Create(ctx, newConvertedObject("name: [web"))).Should(Succeed())
+			var condition *metav1.Condition
+			Eventually(func() *metav1.Condition {
+				obj := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, obj); err != nil {
+					return nil
+				}
+				condition = meta.FindStatusCondition(obj.Status.Conditions, ConditionSynced)
+				return condition
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(ReasonConversionFailed))
+			Expect(condition.Message).To(ContainSubstring("unable to convert the source to JSON"))
+			Expect(storedContents("converted-bucket", "settings.json")).To(HaveLen(storeCalls))
+		})
+	})
+
+	Context("with a templated inline source", func() {
+		configMapKey := types.NamespacedName{Name: "client-endpoints", Namespace: Namespace}
+
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      configMapKey.Name,
+					Namespace: configMapKey.Namespace,
+				},
+				Data: map[string]string{"api": "https://v1.example.com"},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			deleteObjectAndSecret()
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, configMapKey, configMap)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, configMap)).Should(Succeed())
+		})
+
+		It("should render the template again when an input changes", func() {
+			lastStored := func() string {
+				calls := fakeObjectStore.StoreCallCount()
+				for i := calls - 1; i >= 0; i-- {
+					_, data, target, _ := fakeObjectStore.StoreArgsForCall(i)
+					if target.Bucket == "template-bucket" {
+						return string(data)
+					}
+				}
+				return ""
+			}
+
+			By("submitting an object templating the configmap value")
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "template-bucket",
+						Key:    "client.yaml",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourceInline,
+						Inline: &cloudobj.InlineSource{
+							Data: "cluster: {{ .Cluster.Name }}\nendpoint: {{ .Values.api }}\n",
+							Template: &cloudobj.InlineTemplate{
+								Inputs: []cloudobj.TemplateInput{{
+									Name:            "api",
+									ConfigMapKeyRef: &cloudobj.ConfigMapSource{Name: configMapKey.Name, Key: "api"},
+								}},
+							},
+						},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+			Eventually(lastStored, timeout, interval).Should(Equal("cluster: test-cluster\nendpoint: https://v1.example.com\n"))
+
+			By("changing the configmap read by the template")
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, configMapKey, configMap)).Should(Succeed())
+			configMap.Data["api"] = "https://v2.example.com"
+			Expect(k8sClient.Update(ctx, configMap)).Should(Succeed())
+			Eventually(lastStored, timeout, interval).Should(Equal("cluster: test-cluster\nendpoint: https://v2.example.com\n"))
+		})
+
+		It("should reject a secret input of another namespace", func() {
+			storeCalls := len(storedContents("template-bucket", "foreign.yaml"))
+
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "template-bucket",
+						Key:    "foreign.yaml",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourceInline,
+						Inline: &cloudobj.InlineSource{
+							Data: "token: {{ .Values.token }}\n",
+							Template: &cloudobj.InlineTemplate{
+								Inputs: []cloudobj.TemplateInput{{
+									Name:         "token",
+									SecretKeyRef: &cloudobj.SourceSecretKeySelector{Namespace: "kube-system", Name: SecretName, Key: "creds-key"},
+								}},
+							},
+						},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+			var condition *metav1.Condition
+			Eventually(func() *metav1.Condition {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return nil
+				}
+				condition = meta.FindStatusCondition(updated.Status.Conditions, ConditionSynced)
+				return condition
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("namespace kube-system is not allowed"))
+			Expect(storedContents("template-bucket", "foreign.yaml")).To(HaveLen(storeCalls))
+		})
+	})
+
+	Context("with an S3 source", func() {
+		var sourceETag atomic.Value
+
+		BeforeEach(func() {
+			createCredentialsSecret(map[string][]byte{"source-creds-key": []byte("c291cmNlLWRhdGE=")})
+
+			sourceETag.Store(`"v1"`)
+			fakeObjectStore.HeadStub = func(ctx context.Context, target cloudobj.ObjectTarget) (ctrlapi.ObjectInfo, error) {
+				if target.Bucket == "source-bucket" {
+					return ctrlapi.ObjectInfo{ETag: sourceETag.Load().(string), Size: 7}, nil
+				}
+				return headLastStored(ctx, target)
+			}
+			fakeObjectStore.GetStub = func(_ context.Context, target cloudobj.ObjectTarget, _ string) ([]byte, ctrlapi.ObjectInfo, error) {
+				current := sourceETag.Load().(string)
+				return []byte("content " + current), ctrlapi.ObjectInfo{ETag: current}, nil
+			}
+		})
+
+		AfterEach(func() {
+			fakeObjectStore.HeadStub = headLastStored
+			fakeObjectStore.GetStub = nil
+			fakeObjectStore.CopyReturns(ctrlapi.ObjectInfo{}, nil)
+
+			deleteObjectAndSecret()
+		})
+
+		newBucketObject := func(creds *cloudobj.Credentials) *cloudobj.Object {
+			return &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "copy-bucket",
+						Key:    "test.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourceS3,
+						S3: &cloudobj.S3Source{
+							Bucket:      "source-bucket",
+							Key:         "app.yaml",
+							Region:      "us-east-1",
+							Credentials: creds,
+							Interval:    &metav1.Duration{Duration: time.Second},
+						},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+		}
+
+		sourceRevision := func() string {
+			updated := &cloudobj.Object{}
+			if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+				return ""
+			}
+			return updated.Status.SourceRevision
+		}
+
+		It("should copy the object server side when its ETag changes", func() {
+			copyCalls := fakeObjectStore.CopyCallCount()
+			storeCalls := len(storedContents("copy-bucket", "test.key"))
+
+			By("submitting an object copying from a bucket readable with its credentials")
+			Expect(k8sClient.Create(ctx, newBucketObject(nil))).Should(Succeed())
+			Eventually(sourceRevision, timeout, interval).Should(Equal(`"v1"`))
+			Expect(fakeObjectStore.CopyCallCount()).To(Equal(copyCalls + 1))
+			_, source, target, _ := fakeObjectStore.CopyArgsForCall(copyCalls)
+			Expect(source).To(Equal(cloudobj.ObjectTarget{Bucket: "source-bucket", Key: "app.yaml", Region: "us-east-1"}))
+			Expect(target.Bucket).To(Equal("copy-bucket"))
+
+			By("polling the unchanged source object")
+			Consistently(fakeObjectStore.CopyCallCount, time.Second*3, interval).Should(Equal(copyCalls + 1))
+
+			By("changing the source object")
+			sourceETag.Store(`"v2"`)
+			Eventually(sourceRevision, timeout, interval).Should(Equal(`"v2"`))
+			Expect(fakeObjectStore.CopyCallCount()).To(Equal(copyCalls + 2))
+			Expect(storedContents("copy-bucket", "test.key")).To(HaveLen(storeCalls))
+		})
+
+		It("should download and upload the object read with other credentials", func() {
+			copyCalls := fakeObjectStore.CopyCallCount()
+			storeCalls := len(storedContents("copy-bucket", "test.key"))
+
+			By("submitting an object copying from a bucket of another account")
+			Expect(k8sClient.Create(ctx, newBucketObject(&cloudobj.Credentials{
+				Source: "Secret",
+				SecretReference: cloudobj.SecretKeySelector{
+					SecretReference: cloudobj.SecretReference{
+						Namespace: Namespace,
+						Name:      SecretName,
+					},
+					Key: "source-creds-key",
+				},
+			}))).Should(Succeed())
+			Eventually(sourceRevision, timeout, interval).Should(Equal(`"v1"`))
+			Expect(fakeObjectStore.CopyCallCount()).To(Equal(copyCalls))
+			stored := storedContents("copy-bucket", "test.key")
+			Expect(len(stored)).To(BeNumerically(">", storeCalls))
+			Expect(stored[len(stored)-1]).To(Equal(`content "v1"`))
+		})
+
+		It("should fall back to downloading when the server side copy is denied", func() {
+			storeCalls := len(storedContents("copy-bucket", "test.key"))
+			fakeObjectStore.CopyReturns(ctrlapi.ObjectInfo{}, &ctrlapi.Error{
+				Reason:  ctrlapi.ReasonAccessDenied,
+				Message: "access denied",
+			})
+
+			Expect(k8sClient.Create(ctx, newBucketObject(nil))).Should(Succeed())
+			Eventually(sourceRevision, timeout, interval).Should(Equal(`"v1"`))
+			stored := storedContents("copy-bucket", "test.key")
+			Expect(len(stored)).To(BeNumerically(">", storeCalls))
+			Expect(stored[len(stored)-1]).To(Equal(`content "v1"`))
+		})
+	})
+
+	Context("with a PVC source", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+		})
+
+		AfterEach(func() {
+			deleteObjectAndSecret()
+
+			// failed jobs are kept, and are not collected by envtest
+			Expect(k8sClient.DeleteAllOf(ctx, &batchv1.Job{}, client.InNamespace(Namespace), client.HasLabels{UploadJobLabel},
+				client.PropagationPolicy(metav1.DeletePropagationBackground))).Should(Succeed())
+		})
+
+		It("should upload the volume through a job and clean it up", func() {
+			By("submitting an object reading a report from a claim")
+			fsGroup := int64(2000)
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "reports-bucket",
+						Key:    "reports/summary.csv",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourcePVC,
+						PVC:  &cloudobj.PVCSource{ClaimName: "reports", Path: "out/summary.csv", FSGroup: &fsGroup},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+			var job batchv1.Job
+			Eventually(func() error {
+				jobs := &batchv1.JobList{}
+				if err := k8sClient.List(ctx, jobs, client.InNamespace(Namespace), client.HasLabels{UploadJobLabel}); err != nil {
+					return err
+				}
+				if len(jobs.Items) != 1 {
+					return errors.Errorf("expected one upload job, got %d", len(jobs.Items))
+				}
+				job = jobs.Items[0]
+				return nil
+			}, timeout, interval).Should(Succeed())
+
+			pod := job.Spec.Template.Spec
+			Expect(pod.Containers).To(HaveLen(1))
+			Expect(pod.Containers[0].Image).To(Equal("controller:test"))
+			Expect(pod.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("reports"))
+			Expect(pod.Volumes[0].PersistentVolumeClaim.ReadOnly).To(BeTrue())
+			Expect(pod.Volumes[1].Secret.SecretName).To(Equal(SecretName))
+			Expect(pod.SecurityContext.FSGroup).To(Equal(&fsGroup))
+			Expect(metav1.IsControlledBy(&job, obj)).To(BeTrue())
+
+			var spec UploadSpec
+			Expect(json.Unmarshal([]byte(pod.Containers[0].Env[0].Value), &spec)).To(Succeed())
+			Expect(spec.Path).To(Equal("/data/out/summary.csv"))
+			Expect(spec.Target.Key).To(Equal("reports/summary.csv"))
+
+			Eventually(func() cloudobj.UploadPhase {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil || updated.Status.Upload == nil {
+					return ""
+				}
+				return updated.Status.Upload.Phase
+			}, timeout, interval).Should(Equal(cloudobj.UploadRunning))
+
+			By("running the uploader of the job")
+			dir, err := os.MkdirTemp("", "upload")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			spec.Path = filepath.Join(dir, "summary.csv")
+			spec.CredentialsFile = filepath.Join(dir, "credentials")
+			Expect(os.WriteFile(spec.Path, []byte("total,42"), 0600)).To(Succeed())
+			Expect(os.WriteFile(spec.CredentialsFile, []byte("c29tZS1kYXRh"), 0600)).To(Succeed())
+			_, err = Upload(ctx, fakeStoreManager, spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			now := metav1.Now()
+			job.Status.StartTime = &now
+			job.Status.CompletionTime = &now
+			job.Status.Succeeded = 1
+			job.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: now},
+			}
+			Expect(k8sClient.Status().Update(ctx, &job)).Should(Succeed())
+
+			By("recording the upload and deleting the job")
+			Eventually(func() bool {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return false
+				}
+				return updated.Status.Synced && updated.Status.Upload.Phase == cloudobj.UploadSucceeded
+			}, timeout, interval).Should(BeTrue())
+			updated := &cloudobj.Object{}
+			Expect(k8sClient.Get(ctx, objLookupKey, updated)).Should(Succeed())
+			Expect(updated.Status.Checksum).To(Equal(checksum([]byte("total,42"))))
+			Expect(updated.Status.Reference).To(Equal("s3://reports-bucket/reports/summary.csv"))
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(&job), &batchv1.Job{})
+				return apierrors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+			Consistently(func() int {
+				jobs := &batchv1.JobList{}
+				Expect(k8sClient.List(ctx, jobs, client.InNamespace(Namespace), client.HasLabels{UploadJobLabel})).To(Succeed())
+				return len(jobs.Items)
+			}, time.Second*2, interval).Should(BeZero())
+		})
+
+		It("should fail an upload job exceeding its deadline", func() {
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "reports-bucket",
+						Key:    "reports/pending.csv",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourcePVC,
+						PVC: &cloudobj.PVCSource{
+							ClaimName: "reports",
+							Path:      "out/pending.csv",
+							Timeout:   &metav1.Duration{Duration: 5 * time.Minute},
+						},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+			var job batchv1.Job
+			Eventually(func() error {
+				jobs := &batchv1.JobList{}
+				if err := k8sClient.List(ctx, jobs, client.InNamespace(Namespace), client.HasLabels{UploadJobLabel}); err != nil {
+					return err
+				}
+				if len(jobs.Items) != 1 {
+					return errors.Errorf("expected one upload job, got %d", len(jobs.Items))
+				}
+				job = jobs.Items[0]
+				return nil
+			}, timeout, interval).Should(Succeed())
+			Expect(job.Spec.ActiveDeadlineSeconds).NotTo(BeNil())
+			Expect(*job.Spec.ActiveDeadlineSeconds).To(Equal(int64(300)))
+
+			By("letting the job exceed its deadline while pending")
+			now := metav1.Now()
+			job.Status.StartTime = &now
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type:               batchv1.JobFailed,
+				Status:             corev1.ConditionTrue,
+				Reason:             "DeadlineExceeded",
+				Message:            "Job was active longer than specified deadline",
+				LastTransitionTime: now,
+			}}
+			Expect(k8sClient.Status().Update(ctx, &job)).Should(Succeed())
+
+			var condition *metav1.Condition
+			Eventually(func() *metav1.Condition {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil || updated.Status.Upload == nil {
+					return nil
+				}
+				if updated.Status.Upload.Phase != cloudobj.UploadFailed {
+					return nil
+				}
+				condition = meta.FindStatusCondition(updated.Status.Conditions, ConditionSynced)
+				return condition
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(UploadFailed))
+			Expect(condition.Message).To(ContainSubstring("longer than specified deadline"))
+
+			By("keeping the failed job until its retry delay elapsed")
+			Consistently(func() types.UID {
+				current := &batchv1.Job{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(&job), current); err != nil {
+					return ""
+				}
+				return current.UID
+			}, time.Second*2, interval).Should(Equal(job.UID))
+		})
+
+		It("should replace a failed upload job once its retry delay elapsed", func() {
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "reports-bucket",
+						Key:    "reports/retried.csv",
+					},
+					Source: cloudobj.ObjectSource{
+						Type: cloudobj.SourcePVC,
+						PVC:  &cloudobj.PVCSource{ClaimName: "reports", Path: "out/retried.csv"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+			var job batchv1.Job
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Namespace: Namespace, Name: uploadJobName(obj)}, &job)
+			}, timeout, interval).Should(Succeed())
+
+			By("failing the job longer ago than the retry delay")
+			failedAt := metav1.NewTime(time.Now().Add(-2 * uploadRetryDelay))
+			job.Status.StartTime = &failedAt
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type:               batchv1.JobFailed,
+				Status:             corev1.ConditionTrue,
+				Reason:             "BackoffLimitExceeded",
+				LastTransitionTime: failedAt,
+			}}
+			Expect(k8sClient.Status().Update(ctx, &job)).Should(Succeed())
+
+			By("starting a new job and counting the failure")
+			Eventually(func() bool {
+				current := &batchv1.Job{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(&job), current); err != nil {
+					return false
+				}
+				return current.UID != job.UID
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() cloudobj.UploadStatus {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil || updated.Status.Upload == nil {
+					return cloudobj.UploadStatus{}
+				}
+				return cloudobj.UploadStatus{Phase: updated.Status.Upload.Phase, Failures: updated.Status.Upload.Failures}
+			}, timeout, interval).Should(Equal(cloudobj.UploadStatus{Phase: cloudobj.UploadRunning, Failures: 1}))
+		})
+	})
+
+	Context("with credentials lacking access to the bucket", func() {
+		BeforeEach(func() {
+			createCredentialsSecret(nil)
+
+			fakeStoreManager.ValidateReturns(&ctrlapi.Error{
+				Reason:  ctrlapi.ReasonAccessDenied,
+				Message: "access denied on s3:ListBucket for bucket test-bucket",
+			})
+		})
+
+		AfterEach(func() {
+			fakeStoreManager.ValidateReturns(nil)
+
+			deleteObjectAndSecret()
+		})
+
+		It("should report the denied access before storing any data", func() {
+			obj := &cloudobj.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Retain",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "test-bucket",
+						Key:    "denied.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: Namespace,
+								Name:      SecretName,
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+			var condition *metav1.Condition
+			Eventually(func() *metav1.Condition {
+				updated := &cloudobj.Object{}
+				if err := k8sClient.Get(ctx, objLookupKey, updated); err != nil {
+					return nil
+				}
+				condition = meta.FindStatusCondition(updated.Status.Conditions, ConditionCredentialsValid)
+				return condition
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(ctrlapi.ReasonAccessDenied))
+			Expect(condition.Message).To(ContainSubstring("s3:ListBucket for bucket test-bucket"))
+
+			for i := 0; i < fakeObjectStore.StoreCallCount(); i++ {
+				_, _, target, _ := fakeObjectStore.StoreArgsForCall(i)
+				Expect(target.Key).NotTo(Equal("denied.key"))
+			}
+		})
+	})
+
+	Context("without secret present", func() {
+		AfterEach(func() {
+			// delete object, unless the test deleted it already
+			obj := &cloudobj.Object{}
+			if err := k8sClient.Get(ctx, objLookupKey, obj); err == nil {
+				_ = k8sClient.Delete(ctx, obj)
+			}
+		})
+
+		It("should successfully try to store the object", func() {
+			By("having the new Object defined")
+			ctx = context.Background()
+			obj := &cloudobj.Object{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "s3.aws.dev.nimak.link/v1beta1",
+					Kind:       "Object",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ObjName,
+					Namespace: Namespace,
+				},
+				Spec: cloudobj.ObjectSpec{
+					DeletionPolicy: "Delete",
+					Target: cloudobj.ObjectTarget{
+						Region: "us-west-2",
+						Bucket: "test-bucket",
+						Key:    "test.key",
+					},
+					Source: cloudobj.ObjectSource{
+						Inline: &cloudobj.InlineSource{Data: "test-data"},
+					},
+					Credentials: cloudobj.Credentials{
+						Source: "Secret",
+						SecretReference: cloudobj.SecretKeySelector{
+							SecretReference: cloudobj.SecretReference{
+								Namespace: "default",
+								Name:      "creds-name",
+							},
+							Key: "creds-key",
+						},
+					},
+				},
+			}
+
+			By("submitting the new object")
+			Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+			By("waiting for the object to become available")
+			createdObject = &cloudobj.Object{}
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, objLookupKey, createdObject)
+				return err == nil
+			}, timeout, interval).Should(BeTrue())
+
+			By("object status should reflect updates")
+			Expect(createdObject.Status.Synced).To(BeFalse())
+			Expect(createdObject.Status.Reference).Should(BeEmpty())
+		})
+	})
+})
+
+// storedContents returns the contents stored into bucket and key, in the order
+// of the calls; the other reconcilers of the suite share the fake store
+func storedContents(bucket, key string) []string {
+	var contents []string
+	for i := 0; i < fakeObjectStore.StoreCallCount(); i++ {
+		_, data, target, _ := fakeObjectStore.StoreArgsForCall(i)
+		if target.Bucket == bucket && target.Key == key {
+			contents = append(contents, string(data))
+		}
+	}
+	return contents
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package remote fetches the content of sources living outside of the
+// cluster: HTTP(S) URLs and layers of OCI artifacts.
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaxSize is the largest content fetched from a remote source, as the content
+// is held in memory until it is stored
+const MaxSize = 128 << 20
+
+// ErrNotModified is returned when the content of a source still has the
+// revision it was last fetched with
+var ErrNotModified = errors.New("source not modified")
+
+// DefaultClient is the client fetching remote sources
+var DefaultClient = &http.Client{Timeout: 5 * time.Minute}
+
+// A Result is the content of a remote source
+type Result struct {
+	Data []byte
+	// Revision identifies the content: the ETag of an HTTP source, or the
+	// manifest digest of an OCI artifact
+	Revision string
+}
+
+// Fetch returns the content served at url. The request carries authorization
+// as its Authorization header when set. The content is only returned when its
+// revision differs from revision, ErrNotModified is returned otherwise.
+// Servers without ETags are revisioned by the checksum of the content.
+func Fetch(ctx context.Context, client *http.Client, url, authorization, revision string) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "invalid url %s", url)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	if revision != "" && !strings.HasPrefix(revision, digestPrefix) {
+		req.Header.Set("If-None-Match", revision)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "unable to fetch %s", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return Result{}, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, errors.Errorf("unable to fetch %s: %s", url, resp.Status)
+	}
+
+	data, err := readAll(resp.Body)
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "unable to fetch %s", url)
+	}
+	result := Result{Data: data, Revision: resp.Header.Get("ETag")}
+	if result.Revision == "" {
+		result.Revision = digest(data)
+	}
+	if result.Revision == revision {
+		return Result{}, ErrNotModified
+	}
+	return result, nil
+}
+
+const digestPrefix = "sha256:"
+
+// digest returns the sha256 digest of data, in the format of OCI digests
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return digestPrefix + hex.EncodeToString(sum[:])
+}
+
+// readAll reads r up to MaxSize
+func readAll(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxSize {
+		return nil, errors.Errorf("content exceeds %d bytes", MaxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fetch", func() {
+	var (
+		server  *httptest.Server
+		content string
+		etag    string
+		auth    string
+	)
+
+	BeforeEach(func() {
+		content, etag, auth = "mirrored content", `"v1"`, ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if auth != "" && r.Header.Get("Authorization") != auth {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if etag != "" {
+				if r.Header.Get("If-None-Match") == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", etag)
+			}
+			_, _ = w.Write([]byte(content))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns the content with its ETag as revision", func() {
+		result, err := Fetch(context.Background(), server.Client(), server.URL, "", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Data)).To(Equal("mirrored content"))
+		Expect(result.Revision).To(Equal(`"v1"`))
+	})
+
+	It("returns ErrNotModified while the ETag is unchanged", func() {
+		_, err := Fetch(context.Background(), server.Client(), server.URL, "", `"v1"`)
+		Expect(err).To(MatchError(ErrNotModified))
+
+		etag = `"v2"`
+		result, err := Fetch(context.Background(), server.Client(), server.URL, "", `"v1"`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Revision).To(Equal(`"v2"`))
+	})
+
+	It("revisions content without ETag by its checksum", func() {
+		etag = ""
+		result, err := Fetch(context.Background(), server.Client(), server.URL, "", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Revision).To(Equal(digest([]byte("mirrored content"))))
+
+		_, err = Fetch(context.Background(), server.Client(), server.URL, "", result.Revision)
+		Expect(err).To(MatchError(ErrNotModified))
+	})
+
+	It("sends the Authorization header", func() {
+		auth = "Bearer secret"
+		_, err := Fetch(context.Background(), server.Client(), server.URL, "", "")
+		Expect(err).To(MatchError(ContainSubstring("401")))
+
+		result, err := Fetch(context.Background(), server.Client(), server.URL, "Bearer secret", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Data)).To(Equal("mirrored content"))
+	})
+})
@@ -0,0 +1,418 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MediaTypeOCIManifest is the media type of OCI image manifests
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	// MediaTypeDockerManifest is the media type of Docker image manifests
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	// AnnotationTitle holds the file name of a layer
+	AnnotationTitle = "org.opencontainers.image.title"
+
+	dockerHub         = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+	dockerHubAuth     = "https://index.docker.io/v1/"
+)
+
+// An Artifact refers to a layer of an OCI artifact
+type Artifact struct {
+	// Reference of the artifact, registry/repository[:tag][@digest]
+	Reference string
+	// MediaType of the layer, the layer must be unique if empty
+	MediaType string
+	// File name of the layer, as set by the title annotation
+	File string
+	// Insecure pulls over plain HTTP
+	Insecure bool
+	// DockerConfig holds the credentials of the registry, pulls are
+	// anonymous if empty
+	DockerConfig []byte
+}
+
+// A Descriptor describes a blob of an artifact
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// A Manifest lists the layers of an artifact
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Pull returns the content of the layer of artifact. The content is only
+// returned when the manifest digest of the artifact differs from revision,
+// ErrNotModified is returned otherwise.
+func Pull(ctx context.Context, client *http.Client, artifact Artifact, revision string) (Result, error) {
+	ref, err := ParseReference(artifact.Reference)
+	if err != nil {
+		return Result{}, err
+	}
+	username, password, err := dockerCredentials(artifact.DockerConfig, ref.Registry)
+	if err != nil {
+		return Result{}, err
+	}
+
+	scheme := "https"
+	if artifact.Insecure {
+		scheme = "http"
+	}
+	host := ref.Registry
+	if host == dockerHub {
+		host = dockerHubRegistry
+	}
+	r := &registry{
+		client:     client,
+		base:       scheme + "://" + host + "/v2/" + ref.Repository,
+		repository: ref.Repository,
+		insecure:   artifact.Insecure,
+		username:   username,
+		password:   password,
+	}
+
+	body, err := r.get(ctx, "/manifests/"+ref.Identifier(), MediaTypeOCIManifest+", "+MediaTypeDockerManifest)
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "unable to get manifest of %s", artifact.Reference)
+	}
+	manifestDigest := digest(body)
+	if ref.Digest != "" && ref.Digest != manifestDigest {
+		return Result{}, errors.Errorf("manifest of %s has digest %s", artifact.Reference, manifestDigest)
+	}
+	if manifestDigest == revision {
+		return Result{}, ErrNotModified
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return Result{}, errors.Wrapf(err, "invalid manifest of %s", artifact.Reference)
+	}
+	layer, err := selectLayer(manifest.Layers, artifact.MediaType, artifact.File)
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "unable to select layer of %s", artifact.Reference)
+	}
+	if layer.Size > MaxSize {
+		return Result{}, errors.Errorf("layer %s of %s exceeds %d bytes", layer.Digest, artifact.Reference, MaxSize)
+	}
+	if !strings.HasPrefix(layer.Digest, digestPrefix) {
+		return Result{}, errors.Errorf("unsupported digest algorithm of layer %s of %s, only sha256 digests are supported", layer.Digest, artifact.Reference)
+	}
+
+	data, err := r.get(ctx, "/blobs/"+layer.Digest, "")
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "unable to get layer %s of %s", layer.Digest, artifact.Reference)
+	}
+	if digest(data) != layer.Digest {
+		return Result{}, errors.Errorf("layer of %s does not match its digest %s", artifact.Reference, layer.Digest)
+	}
+	return Result{Data: data, Revision: manifestDigest}, nil
+}
+
+// selectLayer returns the only layer matching mediaType and file, empty
+// values matching every layer
+func selectLayer(layers []Descriptor, mediaType, file string) (Descriptor, error) {
+	var matches []Descriptor
+	for _, layer := range layers {
+		if mediaType != "" && layer.MediaType != mediaType {
+			continue
+		}
+		if file != "" && layer.Annotations[AnnotationTitle] != file {
+			continue
+		}
+		matches = append(matches, layer)
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return Descriptor{}, errors.Errorf("no layer matches media type %q and file %q", mediaType, file)
+	default:
+		return Descriptor{}, errors.Errorf("%d layers match media type %q and file %q", len(matches), mediaType, file)
+	}
+}
+
+// A Reference locates an artifact in a registry
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses a reference of the form registry/repository[:tag][@digest],
+// defaulting to Docker Hub and the latest tag like the docker CLI does
+func ParseReference(s string) (Reference, error) {
+	var ref Reference
+	name := s
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !strings.HasPrefix(ref.Digest, digestPrefix) {
+			return Reference{}, errors.Errorf("invalid reference %s: only sha256 digests are supported", s)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+
+	ref.Registry, ref.Repository = dockerHub, name
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry, ref.Repository = host, name[i+1:]
+		}
+	}
+	if ref.Registry == dockerHub && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Repository == "" || strings.HasSuffix(s, ":") {
+		return Reference{}, errors.Errorf("invalid reference %s", s)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Identifier returns the digest of the reference, or its tag when it has no
+// digest
+func (r Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// registry pulls from a repository of a registry, authenticating with the
+// token or basic auth flow of the distribution spec
+type registry struct {
+	client     *http.Client
+	base       string
+	repository string
+	// insecure allows sending credentials over plain HTTP
+	insecure bool
+	username string
+	password string
+
+	authorization string
+}
+
+// get returns the body at path of the repository, authenticating once the
+// registry challenges the request
+func (r *registry) get(ctx context.Context, path, accept string) ([]byte, error) {
+	resp, err := r.do(ctx, path, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := r.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = r.do(ctx, path, accept); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return readAll(resp.Body)
+}
+
+func (r *registry) do(ctx context.Context, path, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if r.authorization != "" {
+		req.Header.Set("Authorization", r.authorization)
+	}
+	return r.client.Do(req)
+}
+
+// authenticate answers the WWW-Authenticate challenge of the registry
+func (r *registry) authenticate(ctx context.Context, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if r.username == "" {
+			return errors.New("registry requires credentials")
+		}
+		r.authorization = "Basic " + basicAuth(r.username, r.password)
+		return nil
+
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return errors.Errorf("invalid token realm %q", params["realm"])
+		}
+		query := realm.Query()
+		if service := params["service"]; service != "" {
+			query.Set("service", service)
+		}
+		scope := params["scope"]
+		if scope == "" {
+			scope = "repository:" + r.repository + ":pull"
+		}
+		query.Set("scope", scope)
+		realm.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return err
+		}
+		if r.username != "" {
+			// the realm is named by the registry, never leak the
+			// credentials in plain text unless asked to
+			if realm.Scheme != "https" && !r.insecure {
+				return errors.Errorf("refusing to send credentials to token realm %s over %s", realm.Redacted(), realm.Scheme)
+			}
+			req.SetBasicAuth(r.username, r.password)
+		}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "unable to get registry token")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.Errorf("unable to get registry token: %s", resp.Status)
+		}
+
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+			return errors.Wrap(err, "invalid registry token")
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		if token.Token == "" {
+			return errors.New("registry returned an empty token")
+		}
+		r.authorization = "Bearer " + token.Token
+		return nil
+
+	default:
+		return errors.Errorf("unsupported registry challenge %q", challenge)
+	}
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",service="registry"`
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest := challenge, ""
+	if i := strings.Index(challenge, " "); i >= 0 {
+		scheme, rest = challenge[:i], challenge[i+1:]
+	}
+
+	params := map[string]string{}
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		i := strings.Index(rest, "=")
+		if i < 0 {
+			break
+		}
+		key, value := strings.ToLower(strings.TrimSpace(rest[:i])), ""
+		rest = rest[i+1:]
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if end := strings.Index(rest, ","); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+	}
+	return scheme, params
+}
+
+// dockerCredentials returns the credentials of registry found in a docker
+// config json, or empty credentials if config is empty
+func dockerCredentials(config []byte, registry string) (string, string, error) {
+	if len(config) == 0 {
+		return "", "", nil
+	}
+
+	var dockerConfig struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(config, &dockerConfig); err != nil {
+		return "", "", errors.Wrap(err, "invalid docker config")
+	}
+
+	keys := []string{registry, "https://" + registry, "http://" + registry}
+	if registry == dockerHub {
+		keys = append(keys, dockerHubAuth, dockerHubRegistry)
+	}
+	for _, key := range keys {
+		auth, ok := dockerConfig.Auths[key]
+		if !ok {
+			continue
+		}
+		if auth.Auth == "" {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "invalid auth of %s in docker config", registry)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", errors.Errorf("invalid auth of %s in docker config", registry)
+		}
+		return parts[0], parts[1], nil
+	}
+	return "", "", errors.Errorf("no credentials for %s in docker config", registry)
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRegistry serves the artifacts of a single repository, requiring a
+// bearer token obtained with basic credentials when username is set
+type fakeRegistry struct {
+	repository string
+	manifests  map[string][]byte
+	blobs      map[string][]byte
+	username   string
+	password   string
+	// realm is the token realm of the challenge, http on the registry host
+	// if empty
+	realm         string
+	tokenRequests int
+}
+
+func (f *fakeRegistry) push(tag string, layers map[string]string) string {
+	manifest := Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest}
+	for file, content := range layers {
+		f.blobs[digest([]byte(content))] = []byte(content)
+		manifest.Layers = append(manifest.Layers, Descriptor{
+			MediaType:   "text/plain",
+			Digest:      digest([]byte(content)),
+			Size:        int64(len(content)),
+			Annotations: map[string]string{AnnotationTitle: file},
+		})
+	}
+	body, _ := json.Marshal(manifest)
+	f.manifests[tag] = body
+	f.manifests[digest(body)] = body
+	return digest(body)
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		f.tokenRequests++
+		if username, password, _ := r.BasicAuth(); username != f.username || password != f.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("scope") != "repository:"+f.repository+":pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"pull-token"}`))
+		return
+	}
+	if f.username != "" && r.Header.Get("Authorization") != "Bearer pull-token" {
+		realm := f.realm
+		if realm == "" {
+			realm = fmt.Sprintf("http://%s/token", r.Host)
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q,service="fake"`, realm))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	prefix := "/v2/" + f.repository
+	switch {
+	case strings.HasPrefix(r.URL.Path, prefix+"/manifests/"):
+		body, ok := f.manifests[strings.TrimPrefix(r.URL.Path, prefix+"/manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeOCIManifest)
+		_, _ = w.Write(body)
+	case strings.HasPrefix(r.URL.Path, prefix+"/blobs/"):
+		body, ok := f.blobs[strings.TrimPrefix(r.URL.Path, prefix+"/blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("Pull", func() {
+	var (
+		fake   *fakeRegistry
+		server *httptest.Server
+		host   string
+	)
+
+	BeforeEach(func() {
+		fake = &fakeRegistry{
+			repository: "team/config",
+			manifests:  map[string][]byte{},
+			blobs:      map[string][]byte{},
+		}
+		server = httptest.NewServer(fake)
+		host = strings.TrimPrefix(server.URL, "http://")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("pulls the layer matching the file", func() {
+		revision := fake.push("v1", map[string]string{"app.yaml": "app: v1", "db.yaml": "db: v1"})
+
+		result, err := Pull(context.Background(), server.Client(), Artifact{
+			Reference: host + "/team/config:v1",
+			File:      "db.yaml",
+			Insecure:  true,
+		}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Data)).To(Equal("db: v1"))
+		Expect(result.Revision).To(Equal(revision))
+	})
+
+	It("requires a unique layer", func() {
+		fake.push("v1", map[string]string{"app.yaml": "app: v1", "db.yaml": "db: v1"})
+
+		_, err := Pull(context.Background(), server.Client(), Artifact{
+			Reference: host + "/team/config:v1",
+			Insecure:  true,
+		}, "")
+		Expect(err).To(MatchError(ContainSubstring("2 layers match")))
+	})
+
+	It("returns ErrNotModified while the manifest digest is unchanged", func() {
+		artifact := Artifact{Reference: host + "/team/config:latest", Insecure: true}
+		revision := fake.push("latest", map[string]string{"app.yaml": "app: v1"})
+
+		_, err := Pull(context.Background(), server.Client(), artifact, revision)
+		Expect(err).To(MatchError(ErrNotModified))
+
+		fake.push("latest", map[string]string{"app.yaml": "app: v2"})
+		result, err := Pull(context.Background(), server.Client(), artifact, revision)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Data)).To(Equal("app: v2"))
+		Expect(result.Revision).NotTo(Equal(revision))
+	})
+
+	It("verifies pinned manifest digests", func() {
+		revision := fake.push("v1", map[string]string{"app.yaml": "app: v1"})
+
+		result, err := Pull(context.Background(), server.Client(), Artifact{
+			Reference: host + "/team/config@" + revision,
+			Insecure:  true,
+		}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Data)).To(Equal("app: v1"))
+
+		fake.manifests[revision] = fake.manifests["v1"][1:]
+		_, err = Pull(context.Background(), server.Client(), Artifact{
+			Reference: host + "/team/config@" + revision,
+			Insecure:  true,
+		}, "")
+		Expect(err).To(MatchError(ContainSubstring("has digest")))
+	})
+
+	It("authenticates with the credentials of the docker config", func() {
+		fake.username, fake.password = "puller", "s3cr3t"
+		fake.push("v1", map[string]string{"app.yaml": "app: v1"})
+		artifact := Artifact{Reference: host + "/team/config:v1", Insecure: true}
+
+		_, err := Pull(context.Background(), server.Client(), artifact, "")
+		Expect(err).To(MatchError(ContainSubstring("401")))
+
+		artifact.DockerConfig = []byte(fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, host, basicAuth("puller", "s3cr3t")))
+		result, err := Pull(context.Background(), server.Client(), artifact, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Data)).To(Equal("app: v1"))
+	})
+
+	It("only sends credentials to a plain HTTP token realm when insecure", func() {
+		tlsServer := httptest.NewTLSServer(fake)
+		defer tlsServer.Close()
+		tlsHost := strings.TrimPrefix(tlsServer.URL, "https://")
+		fake.username, fake.password = "puller", "s3cr3t"
+		fake.realm = server.URL + "/token"
+		fake.push("v1", map[string]string{"app.yaml": "app: v1"})
+		artifact := Artifact{
+			Reference:    tlsHost + "/team/config:v1",
+			DockerConfig: []byte(fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, tlsHost, basicAuth("puller", "s3cr3t"))),
+		}
+
+		_, err := Pull(context.Background(), tlsServer.Client(), artifact, "")
+		Expect(err).To(MatchError(ContainSubstring("refusing to send credentials")))
+		Expect(fake.tokenRequests).To(BeZero())
+
+		fake.realm = tlsServer.URL + "/token"
+		result, err := Pull(context.Background(), tlsServer.Client(), artifact, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result.Data)).To(Equal("app: v1"))
+	})
+
+	It("rejects layers with an unsupported digest algorithm", func() {
+		body, _ := json.Marshal(Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest, Layers: []Descriptor{{
+			MediaType: "text/plain",
+			Digest:    "sha512:" + strings.Repeat("0", 128),
+			Size:      7,
+		}}})
+		fake.manifests["v1"] = body
+
+		_, err := Pull(context.Background(), server.Client(), Artifact{
+			Reference: host + "/team/config:v1",
+			Insecure:  true,
+		}, "")
+		Expect(err).To(MatchError(ContainSubstring("unsupported digest algorithm")))
+	})
+})
+
+var _ = Describe("ParseReference", func() {
+	DescribeTable("parses references",
+		func(s string, expected Reference) {
+			ref, err := ParseReference(s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ref).To(Equal(expected))
+		},
+		Entry("docker hub image", "alpine", Reference{Registry: "docker.io", Repository: "library/alpine", Tag: "latest"}),
+		Entry("registry with port", "localhost:5000/team/config:v1", Reference{Registry: "localhost:5000", Repository: "team/config", Tag: "v1"}),
+		Entry("digest", "ghcr.io/org/app@sha256:abc", Reference{Registry: "ghcr.io", Repository: "org/app", Digest: "sha256:abc"}),
+	)
+
+	It("rejects invalid references", func() {
+		_, err := ParseReference("ghcr.io/org/app:")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseReference("ghcr.io/org/app@md5:abc")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,380 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	// ScheduledObjectKind is the kind of scheduled objects
+	ScheduledObjectKind = "ScheduledObject"
+
+	DefaultSnapshotsLimit = 7
+
+	// maxMissedRuns bounds the missed runs scanned for the most recent one,
+	// as CronJob does for its missed start times
+	maxMissedRuns = 100
+
+	SnapshotStored = "SnapshotStored"
+	RunSkipped     = "RunSkipped"
+
+	ConditionScheduled    = "Scheduled"
+	ReasonInvalidSchedule = "InvalidSchedule"
+)
+
+// ScheduledObjectReconciler reconciles a ScheduledObject object, storing a
+// snapshot of its source on every scheduled run with the settings of the
+// ObjectReconciler
+type ScheduledObjectReconciler struct {
+	*ObjectReconciler
+	// Clock returns the current time, time.Now if nil
+	Clock func() time.Time
+}
+
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=scheduledobjects,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=scheduledobjects/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=s3.aws.dev.nimak.link,resources=scheduledobjects/finalizers,verbs=update
+
+func (r *ScheduledObjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := startSpan(ctx, "ReconcileSchedule",
+		attribute.String("object.namespace", req.Namespace),
+		attribute.String("object.name", req.Name),
+	)
+	defer span.End()
+
+	obj := &cloudobject.ScheduledObject{}
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if err := r.applyTargetDefaults(&obj.Spec.Target, &obj.Spec.DeletionPolicy); err != nil {
+		return ctrl.Result{}, r.reportFailure(ctx, obj, Failed, err)
+	}
+
+	if !obj.GetDeletionTimestamp().IsZero() {
+		if !controllerutil.ContainsFinalizer(obj, ObjectFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if deleteErr := r.deleteSnapshots(ctx, obj); deleteErr != nil {
+			if err := r.reportFailure(ctx, obj, failureReason(deleteErr, Failed), deleteErr); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, deleteErr
+		}
+		controllerutil.RemoveFinalizer(obj, ObjectFinalizer)
+		return ctrl.Result{}, r.Update(ctx, obj)
+	}
+
+	if !controllerutil.ContainsFinalizer(obj, ObjectFinalizer) {
+		controllerutil.AddFinalizer(obj, ObjectFinalizer)
+		if err := r.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	schedule, err := cron.ParseStandard(obj.Spec.Schedule)
+	if err != nil {
+		// retrying does not help until the spec changes
+		return ctrl.Result{}, r.reportFailure(ctx, obj, ReasonInvalidSchedule, errors.Wrapf(err, "invalid schedule %s", obj.Spec.Schedule))
+	}
+
+	if obj.Spec.Suspend {
+		log.FromContext(ctx).Info("scheduled object is suspended, skipping scheduled runs", "key", client.ObjectKeyFromObject(obj))
+		obj.Status.NextScheduleTime = nil
+		return ctrl.Result{}, r.Status().Update(ctx, obj)
+	}
+
+	now := r.now()
+	if scheduled, ok := mostRecentRun(schedule, r.lastScheduled(obj), now); ok {
+		// runs missed while the controller was down or the object was
+		// suspended are collapsed into the most recent one
+		var skipped bool
+		obj.Status.LastScheduleTime = &metav1.Time{Time: scheduled}
+		obj.Status.Active, skipped = scheduleRun(obj.Status.Active, *obj.Status.LastScheduleTime, obj.Spec.ConcurrencyPolicy)
+		if skipped {
+			scheduledRunsTotal.WithLabelValues(resultSkipped).Inc()
+			r.Recorder.Event(obj, corev1.EventTypeWarning, RunSkipped, fmt.Sprintf("skipped run scheduled at %s, previous runs not completed", scheduled.UTC().Format(time.RFC3339)))
+		}
+	}
+	next := schedule.Next(now)
+	obj.Status.NextScheduleTime = &metav1.Time{Time: next}
+
+	if runErr := r.run(ctx, obj); runErr != nil {
+		if err := r.reportFailure(ctx, obj, failureReason(runErr, Failed), runErr); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, runErr
+	}
+
+	setScheduled(obj, metav1.ConditionTrue, ReasonSucceeded, fmt.Sprintf("next run at %s", next.UTC().Format(time.RFC3339)))
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ScheduledObjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		// runs are triggered by requeueing at the next scheduled time, so
+		// the status updates of the runs are ignored
+		For(&cloudobject.ScheduledObject{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithEventFilter(predicate.NewPredicateFuncs(r.Scope.Contains)).
+		Complete(r)
+}
+
+// run stores a snapshot for every active run, then prunes the snapshots
+// beyond the limit
+func (r *ScheduledObjectReconciler) run(ctx context.Context, obj *cloudobject.ScheduledObject) error {
+	if len(obj.Status.Active) == 0 {
+		return nil
+	}
+
+	objectStore, err := r.objectStore(ctx, obj.Spec.Credentials, obj.Spec.Target)
+	if err != nil {
+		return err
+	}
+	for len(obj.Status.Active) > 0 {
+		err := r.snapshot(ctx, objectStore, obj, obj.Status.Active[0].Time)
+		scheduledRunsTotal.WithLabelValues(resultLabel(err)).Inc()
+		if err != nil {
+			return err
+		}
+		obj.Status.Active = obj.Status.Active[1:]
+	}
+	return r.prune(ctx, objectStore, obj)
+}
+
+// snapshot stores the current content of the source under the key of the run
+// scheduled at the given time
+func (r *ScheduledObjectReconciler) snapshot(ctx context.Context, objectStore ctrlapi.ObjectStore, obj *cloudobject.ScheduledObject, scheduled time.Time) error {
+	log := log.FromContext(ctx)
+
+	var (
+		data []byte
+		err  error
+	)
+	if obj.Spec.Source.Type == cloudobject.SourceS3 {
+		data, err = r.downloadBucket(ctx, obj.Spec.Credentials, obj.Spec.Target, obj.Spec.Source.S3)
+	} else {
+		data, _, err = extractSource(ctx, r, obj, obj.Spec.Source, "", r.Cluster)
+	}
+	if err != nil {
+		return err
+	}
+
+	target := obj.Spec.Target
+	target.Key = versionedKey(target.Key, scheduled)
+	if r.DryRun {
+		msg := fmt.Sprintf("dry run: would %s %s", Store, storeReference(target))
+		r.Recorder.Event(obj, corev1.EventTypeNormal, DryRun, msg)
+		log.Info(msg, "size", len(data))
+		return nil
+	}
+
+	// the snapshot is recorded before it is stored, so that it is pruned
+	// even if the status update after storing it fails
+	index := snapshotIndex(obj.Status.Snapshots, target.Key)
+	if index < 0 {
+		planned := cloudobject.ObjectVersion{
+			Key:       target.Key,
+			Timestamp: metav1.NewTime(scheduled),
+		}
+		obj.Status.Snapshots = append([]cloudobject.ObjectVersion{planned}, obj.Status.Snapshots...)
+		if err := r.Status().Update(ctx, obj); err != nil {
+			return err
+		}
+		index = 0
+	}
+
+	info, err := r.store(ctx, objectStore, obj, target, data)
+	if err != nil {
+		return err
+	}
+
+	obj.Status.Snapshots[index].VersionID = info.VersionID
+	obj.Status.Snapshots[index].Checksum = checksum(data)
+	obj.Status.LastSuccessfulTime = &metav1.Time{Time: r.now()}
+
+	r.Recorder.Event(obj, corev1.EventTypeNormal, SnapshotStored, fmt.Sprintf("object reference: %s", storeReference(target)))
+	log.Info("stored snapshot", "key", target.Key)
+	return nil
+}
+
+// snapshotIndex returns the index of the snapshot stored under key, -1 if
+// there is none
+func snapshotIndex(snapshots []cloudobject.ObjectVersion, key string) int {
+	for i, snapshot := range snapshots {
+		if snapshot.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// prune deletes the oldest snapshots beyond the limit of the object
+func (r *ScheduledObjectReconciler) prune(ctx context.Context, objectStore ctrlapi.ObjectStore, obj *cloudobject.ScheduledObject) error {
+	limit := obj.Spec.SnapshotsLimit
+	if limit <= 0 {
+		limit = DefaultSnapshotsLimit
+	}
+	for len(obj.Status.Snapshots) > limit {
+		oldest := obj.Status.Snapshots[len(obj.Status.Snapshots)-1]
+		if err := deleteVersion(ctx, objectStore, obj.Spec.Target, oldest); err != nil {
+			return err
+		}
+		obj.Status.Snapshots = obj.Status.Snapshots[:len(obj.Status.Snapshots)-1]
+		log.FromContext(ctx).Info("pruned snapshot", "key", oldest.Key, "versionId", oldest.VersionID)
+	}
+	return nil
+}
+
+// deleteSnapshots removes all snapshots of the object unless they are retained
+func (r *ScheduledObjectReconciler) deleteSnapshots(ctx context.Context, obj *cloudobject.ScheduledObject) error {
+	log := log.FromContext(ctx)
+	if obj.Spec.DeletionPolicy == cloudobject.DeletionRetain || len(obj.Status.Snapshots) == 0 {
+		log.Info("retaining the snapshots in the object store")
+		return nil
+	}
+
+	if r.DryRun {
+		msg := fmt.Sprintf("dry run: would %s %d snapshots of %s", Delete, len(obj.Status.Snapshots), storeReference(obj.Spec.Target))
+		r.Recorder.Event(obj, corev1.EventTypeNormal, DryRun, msg)
+		log.Info(msg)
+		return nil
+	}
+
+	objectStore, err := r.objectStore(ctx, obj.Spec.Credentials, obj.Spec.Target)
+	if err != nil {
+		return err
+	}
+	for len(obj.Status.Snapshots) > 0 {
+		if err := deleteVersion(ctx, objectStore, obj.Spec.Target, obj.Status.Snapshots[0]); err != nil {
+			return err
+		}
+		obj.Status.Snapshots = obj.Status.Snapshots[1:]
+	}
+	log.Info("successfully deleted snapshots", "key", storeReference(obj.Spec.Target))
+	return nil
+}
+
+// reportFailure records failure in the status and events of the object
+func (r *ScheduledObjectReconciler) reportFailure(ctx context.Context, obj *cloudobject.ScheduledObject, reason string, failure error) error {
+	log.FromContext(ctx).Error(failure, "failed to run schedule")
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(failure)
+	span.SetStatus(codes.Error, failure.Error())
+
+	setScheduled(obj, metav1.ConditionFalse, reason, failure.Error())
+	r.Recorder.Event(obj, corev1.EventTypeWarning, reason, failure.Error())
+	return r.Status().Update(ctx, obj)
+}
+
+// lastScheduled returns the time runs are scheduled from
+func (r *ScheduledObjectReconciler) lastScheduled(obj *cloudobject.ScheduledObject) time.Time {
+	if obj.Status.LastScheduleTime != nil {
+		return obj.Status.LastScheduleTime.Time
+	}
+	return obj.GetCreationTimestamp().Time
+}
+
+func (r *ScheduledObjectReconciler) now() time.Time {
+	if r.Clock != nil {
+		return r.Clock()
+	}
+	return time.Now()
+}
+
+// mostRecentRun returns the latest time the schedule fired after since and
+// until now, if it fired at all
+func mostRecentRun(schedule cron.Schedule, since, now time.Time) (time.Time, bool) {
+	last, previous, complete := scanRuns(schedule, since, now)
+	if !complete {
+		// too many runs were missed, the scan restarts maxMissedRuns
+		// intervals before now, the interval taken from the last two runs
+		if restart := now.Add(-maxMissedRuns * last.Sub(previous)); restart.After(last) {
+			if recent, _, _ := scanRuns(schedule, restart, now); !recent.IsZero() {
+				last = recent
+			}
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// scanRuns returns the last two times the schedule fired after since and
+// until now, and whether the scan completed within maxMissedRuns runs
+func scanRuns(schedule cron.Schedule, since, now time.Time) (last, previous time.Time, complete bool) {
+	runs := 0
+	// Next returns the zero time for schedules that never fire
+	for t := schedule.Next(since); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		if runs++; runs > maxMissedRuns {
+			return last, previous, false
+		}
+		previous, last = last, t
+	}
+	return last, previous, true
+}
+
+// scheduleRun adds the run scheduled at the given time to the active runs
+// following the concurrency policy, and reports whether the run was skipped
+func scheduleRun(active []metav1.Time, scheduled metav1.Time, policy cloudobject.ConcurrencyPolicy) ([]metav1.Time, bool) {
+	if len(active) == 0 {
+		return []metav1.Time{scheduled}, false
+	}
+
+	switch policy {
+	case cloudobject.ConcurrencyAllow:
+		return append(active, scheduled), false
+	case cloudobject.ConcurrencyReplace:
+		return []metav1.Time{scheduled}, false
+	default:
+		return active, true
+	}
+}
+
+func setScheduled(obj *cloudobject.ScheduledObject, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+		Type:               ConditionScheduled,
+		Status:             status,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             reason,
+		Message:            msg,
+	})
+}
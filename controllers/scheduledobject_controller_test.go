@@ -0,0 +1,307 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"dev.nimak.link/s3-copy-controller/controllers/api/apifakes"
+)
+
+var _ = Describe("ScheduledObject controller", func() {
+	const (
+		SecretName = "scheduled-creds"
+		Namespace  = "default"
+
+		timeout  = time.Second * 30
+		interval = time.Millisecond * 250
+	)
+
+	// storedSnapshot returns the key and content of the last snapshot
+	// stored under the key prefix
+	storedSnapshot := func(prefix string) (string, string) {
+		for i := fakeObjectStore.StoreCallCount() - 1; i >= 0; i-- {
+			_, data, target, _ := fakeObjectStore.StoreArgsForCall(i)
+			if strings.HasPrefix(target.Key, prefix+".") {
+				return target.Key, string(data)
+			}
+		}
+		return "", ""
+	}
+
+	newScheduledObject := func(name string, source cloudobj.ObjectSource) *cloudobj.ScheduledObject {
+		return &cloudobj.ScheduledObject{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: Namespace},
+			Spec: cloudobj.ScheduledObjectSpec{
+				Schedule:       "* * * * *",
+				DeletionPolicy: cloudobj.DeletionDelete,
+				Target: cloudobj.ObjectTarget{
+					Region: "us-west-2",
+					Bucket: "snapshot-bucket",
+					Key:    name + ".yaml",
+				},
+				Source: source,
+				Credentials: cloudobj.Credentials{
+					Source: cloudobj.CredentialsSecret,
+					SecretReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Namespace: Namespace, Name: SecretName},
+						Key:             "creds-key",
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace},
+			Data:       map[string][]byte{"creds-key": []byte("c29tZS1kYXRh")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: SecretName, Namespace: Namespace}}
+		Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+	})
+
+	It("should store a timestamped snapshot on schedule and delete it with the object", func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "scheduled-config", Namespace: Namespace},
+			Data:       map[string]string{"config.yaml": "setting: nightly"},
+		}
+		Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+
+		obj := newScheduledObject("nightly-config", cloudobj.ObjectSource{
+			Type:      cloudobj.SourceConfigMap,
+			ConfigMap: &cloudobj.ConfigMapSource{Name: cm.Name, Key: "config.yaml"},
+		})
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() []cloudobj.ObjectVersion {
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return nil
+			}
+			if obj.Status.LastSuccessfulTime == nil {
+				return nil
+			}
+			return obj.Status.Snapshots
+		}, timeout, interval).Should(HaveLen(1))
+
+		key, content := storedSnapshot("nightly-config.yaml")
+		Expect(content).To(Equal("setting: nightly"))
+		Expect(obj.Status.Snapshots[0].Key).To(Equal(key))
+		Expect(obj.Status.Snapshots[0].Checksum).To(Equal(checksum([]byte(content))))
+		Expect(obj.Status.LastScheduleTime).NotTo(BeNil())
+		Expect(key).To(Equal(versionedKey("nightly-config.yaml", obj.Status.LastScheduleTime.Time)))
+		Expect(obj.Status.LastSuccessfulTime).NotTo(BeNil())
+		Expect(obj.Status.NextScheduleTime).NotTo(BeNil())
+		Expect(obj.Status.NextScheduleTime.After(obj.Status.LastScheduleTime.Time)).To(BeTrue())
+		Expect(obj.Status.Active).To(BeEmpty())
+		Expect(meta.IsStatusConditionTrue(obj.Status.Conditions, ConditionScheduled)).To(BeTrue())
+
+		_, _, _, metadata := fakeObjectStore.StoreArgsForCall(fakeObjectStore.StoreCallCount() - 1)
+		Expect(metadata).To(HaveKeyWithValue(OwnerMetadataKey, "ScheduledObject/default/nightly-config"))
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+		Eventually(func() bool {
+			for i := 0; i < fakeObjectStore.DeleteCallCount(); i++ {
+				if _, target := fakeObjectStore.DeleteArgsForCall(i); target.Key == key {
+					return true
+				}
+			}
+			return false
+		}, timeout, interval).Should(BeTrue())
+
+		Expect(k8sClient.Delete(ctx, cm)).Should(Succeed())
+	})
+
+	It("should snapshot resources in its own namespace only", func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "scheduled-manifest", Namespace: Namespace},
+			Data:       map[string]string{"setting": "manifest"},
+		}
+		Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+
+		obj := newScheduledObject("nightly-manifest", cloudobj.ObjectSource{
+			Type:     cloudobj.SourceResource,
+			Resource: &cloudobj.ResourceSource{APIVersion: "v1", Kind: "ConfigMap", Name: cm.Name},
+		})
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() string {
+			_, content := storedSnapshot("nightly-manifest.yaml")
+			return content
+		}, timeout, interval).Should(And(
+			ContainSubstring("kind: ConfigMap"),
+			ContainSubstring("setting: manifest"),
+			Not(ContainSubstring("resourceVersion")),
+		))
+
+		clusterScoped := newScheduledObject("nightly-namespace", cloudobj.ObjectSource{
+			Type:     cloudobj.SourceResource,
+			Resource: &cloudobj.ResourceSource{APIVersion: "v1", Kind: "Namespace", Name: Namespace},
+		})
+		Expect(k8sClient.Create(ctx, clusterScoped)).Should(Succeed())
+
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterScoped), clusterScoped); err != nil {
+				return false
+			}
+			return meta.IsStatusConditionFalse(clusterScoped.Status.Conditions, ConditionScheduled)
+		}, timeout, interval).Should(BeTrue())
+		Expect(clusterScoped.Status.Active).To(HaveLen(1))
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, clusterScoped)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, cm)).Should(Succeed())
+	})
+
+	It("should report an invalid schedule", func() {
+		obj := newScheduledObject("invalid-schedule", cloudobj.ObjectSource{
+			Type:   cloudobj.SourceInline,
+			Inline: &cloudobj.InlineSource{Data: "content"},
+		})
+		obj.Spec.Schedule = "every night"
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() string {
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return ""
+			}
+			condition := meta.FindStatusCondition(obj.Status.Conditions, ConditionScheduled)
+			if condition == nil {
+				return ""
+			}
+			return condition.Reason
+		}, timeout, interval).Should(Equal(ReasonInvalidSchedule))
+		Expect(obj.Status.Snapshots).To(BeEmpty())
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+	})
+
+	It("should record a snapshot before storing it so that a failed run is pruned", func() {
+		fakeObjectStore.StoreCalls(func(_ context.Context, _ []byte, target cloudobj.ObjectTarget, _ map[string]string) (ctrlapi.ObjectInfo, error) {
+			if strings.HasPrefix(target.Key, "unstored.yaml.") {
+				return ctrlapi.ObjectInfo{}, &ctrlapi.Error{Reason: ctrlapi.ReasonAccessDenied, Message: "access denied"}
+			}
+			return ctrlapi.ObjectInfo{}, nil
+		})
+		defer fakeObjectStore.StoreReturns(ctrlapi.ObjectInfo{}, nil)
+
+		obj := newScheduledObject("unstored", cloudobj.ObjectSource{
+			Type:   cloudobj.SourceInline,
+			Inline: &cloudobj.InlineSource{Data: "content"},
+		})
+		Expect(k8sClient.Create(ctx, obj)).Should(Succeed())
+
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return false
+			}
+			return meta.IsStatusConditionFalse(obj.Status.Conditions, ConditionScheduled) && len(obj.Status.Snapshots) > 0
+		}, timeout, interval).Should(BeTrue())
+		key := obj.Status.Snapshots[0].Key
+		Expect(key).To(HavePrefix("unstored.yaml."))
+		Expect(obj.Status.Snapshots[0].Checksum).To(BeEmpty())
+		Expect(obj.Status.LastSuccessfulTime).To(BeNil())
+
+		Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+		Eventually(func() bool {
+			for i := 0; i < fakeObjectStore.DeleteCallCount(); i++ {
+				if _, target := fakeObjectStore.DeleteArgsForCall(i); target.Key == key {
+					return true
+				}
+			}
+			return false
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("should collapse missed runs into the most recent one", func() {
+		schedule, err := cron.ParseStandard("0 * * * *")
+		Expect(err).NotTo(HaveOccurred())
+		since := time.Date(2021, 10, 1, 8, 30, 0, 0, time.UTC)
+
+		_, ok := mostRecentRun(schedule, since, since.Add(20*time.Minute))
+		Expect(ok).To(BeFalse())
+
+		run, ok := mostRecentRun(schedule, since, since.Add(3*time.Hour))
+		Expect(ok).To(BeTrue())
+		Expect(run).To(Equal(time.Date(2021, 10, 1, 11, 0, 0, 0, time.UTC)))
+
+		By("scanning a bounded number of runs after a long outage")
+		every, err := cron.ParseStandard("* * * * *")
+		Expect(err).NotTo(HaveOccurred())
+		run, ok = mostRecentRun(every, since, since.Add(365*24*time.Hour+30*time.Second))
+		Expect(ok).To(BeTrue())
+		Expect(run).To(Equal(since.Add(365 * 24 * time.Hour)))
+	})
+
+	It("should apply the concurrency policy to new runs", func() {
+		previous := metav1.NewTime(time.Date(2021, 10, 1, 8, 0, 0, 0, time.UTC))
+		scheduled := metav1.NewTime(time.Date(2021, 10, 1, 9, 0, 0, 0, time.UTC))
+
+		active, skipped := scheduleRun(nil, scheduled, cloudobj.ConcurrencyForbid)
+		Expect(skipped).To(BeFalse())
+		Expect(active).To(Equal([]metav1.Time{scheduled}))
+
+		active, skipped = scheduleRun([]metav1.Time{previous}, scheduled, cloudobj.ConcurrencyForbid)
+		Expect(skipped).To(BeTrue())
+		Expect(active).To(Equal([]metav1.Time{previous}))
+
+		active, skipped = scheduleRun([]metav1.Time{previous}, scheduled, cloudobj.ConcurrencyAllow)
+		Expect(skipped).To(BeFalse())
+		Expect(active).To(Equal([]metav1.Time{previous, scheduled}))
+
+		active, skipped = scheduleRun([]metav1.Time{previous}, scheduled, cloudobj.ConcurrencyReplace)
+		Expect(skipped).To(BeFalse())
+		Expect(active).To(Equal([]metav1.Time{scheduled}))
+	})
+
+	It("should prune the snapshots beyond the limit", func() {
+		store := &apifakes.FakeObjectStore{}
+		obj := newScheduledObject("pruned", cloudobj.ObjectSource{})
+		obj.Spec.SnapshotsLimit = 2
+		obj.Status.Snapshots = []cloudobj.ObjectVersion{
+			{Key: "pruned.yaml.3"},
+			{Key: "pruned.yaml.2"},
+			{Key: "pruned.yaml.1", VersionID: "v1"},
+		}
+
+		r := &ScheduledObjectReconciler{ObjectReconciler: &ObjectReconciler{}}
+		Expect(r.prune(context.TODO(), store, obj)).To(Succeed())
+		Expect(obj.Status.Snapshots).To(HaveLen(2))
+		Expect(obj.Status.Snapshots[1].Key).To(Equal("pruned.yaml.2"))
+
+		Expect(store.DeleteVersionCallCount()).To(Equal(1))
+		_, target, versionID := store.DeleteVersionArgsForCall(0)
+		Expect(target.Key).To(Equal("pruned.yaml.1"))
+		Expect(versionID).To(Equal("v1"))
+	})
+})
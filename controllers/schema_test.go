@@ -0,0 +1,261 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudobjv1alpha1 "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+// the CEL rules of the CRD schema are enforced by the API server alone, no
+// webhook is running in the test environment
+var _ = Describe("Object schema validation", func() {
+	const (
+		Namespace = "default"
+
+		timeout  = time.Second * 30
+		interval = time.Millisecond * 250
+	)
+
+	var count int
+
+	newObject := func() *cloudobj.Object {
+		count++
+		return &cloudobj.Object{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("schema-obj-%d", count),
+				Namespace: Namespace,
+			},
+			Spec: cloudobj.ObjectSpec{
+				DeletionPolicy: cloudobj.DeletionRetain,
+				Target: cloudobj.ObjectTarget{
+					Region: "us-west-2",
+					Bucket: "schema-bucket",
+					Key:    fmt.Sprintf("schema-%d.key", count),
+				},
+				Source: cloudobj.ObjectSource{
+					Type:   cloudobj.SourceInline,
+					Inline: &cloudobj.InlineSource{Data: "test-data"},
+				},
+				Credentials: cloudobj.Credentials{
+					Source: cloudobj.CredentialsSecret,
+					SecretReference: cloudobj.SecretKeySelector{
+						SecretReference: cloudobj.SecretReference{Namespace: Namespace, Name: "creds-name"},
+						Key:             "creds-key",
+					},
+				},
+			},
+		}
+	}
+
+	expectRejected := func(obj client.Object, message string) {
+		err := k8sClient.Create(ctx, obj)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue(), "expected an invalid error, got %v", err)
+		Expect(err.Error()).To(ContainSubstring(message))
+	}
+
+	Context("v1beta1", func() {
+		It("should reject an Inline source without inline data", func() {
+			obj := newObject()
+			obj.Spec.Source.Inline = nil
+			expectRejected(obj, "an Inline source requires inline and no other member")
+		})
+
+		It("should reject an Inline source with empty data", func() {
+			obj := newObject()
+			obj.Spec.Source.Inline.Data = ""
+			expectRejected(obj, "spec.source.inline.data")
+		})
+
+		It("should reject a ConfigMap source without a configmap", func() {
+			obj := newObject()
+			obj.Spec.Source = cloudobj.ObjectSource{Type: cloudobj.SourceConfigMap}
+			expectRejected(obj, "a ConfigMap source requires configMap and no other member")
+		})
+
+		It("should reject a source with both members set", func() {
+			obj := newObject()
+			obj.Spec.Source.ConfigMap = &cloudobj.ConfigMapSource{Name: "app-config", Key: "settings.yaml"}
+			expectRejected(obj, "an Inline source requires inline and no other member")
+		})
+
+		It("should reject an HTTP source with another member", func() {
+			obj := newObject()
+			obj.Spec.Source.Type = cloudobj.SourceHTTP
+			obj.Spec.Source.HTTP = &cloudobj.HTTPSource{URL: "https://example.com/app.yaml"}
+			expectRejected(obj, "an HTTP source requires http and no other member")
+		})
+
+		It("should reject an HTTP source with another scheme", func() {
+			obj := newObject()
+			obj.Spec.Source = cloudobj.ObjectSource{
+				Type: cloudobj.SourceHTTP,
+				HTTP: &cloudobj.HTTPSource{URL: "ftp://example.com/app.yaml"},
+			}
+			expectRejected(obj, "spec.source.http.url")
+		})
+
+		It("should reject a PVC path outside of the volume", func() {
+			obj := newObject()
+			obj.Spec.Source = cloudobj.ObjectSource{
+				Type: cloudobj.SourcePVC,
+				PVC:  &cloudobj.PVCSource{ClaimName: "reports", Path: "out/../../etc"},
+			}
+			expectRejected(obj, "path must be relative to the root of the volume")
+		})
+
+		It("should reject a template input reading both a configmap and a secret", func() {
+			obj := newObject()
+			obj.Spec.Source.Inline.Template = &cloudobj.InlineTemplate{
+				Inputs: []cloudobj.TemplateInput{{
+					Name:            "api",
+					ConfigMapKeyRef: &cloudobj.ConfigMapSource{Name: "endpoints", Key: "api"},
+					SecretKeyRef:    &cloudobj.SourceSecretKeySelector{Name: "client", Key: "token"},
+				}},
+			}
+			expectRejected(obj, "an input requires exactly one of configMapKeyRef and secretKeyRef")
+		})
+
+		It("should reject an S3 source with another member", func() {
+			obj := newObject()
+			obj.Spec.Source.Type = cloudobj.SourceS3
+			obj.Spec.Source.S3 = &cloudobj.S3Source{Bucket: "source-bucket", Key: "app.yaml"}
+			expectRejected(obj, "an S3 source requires s3 and no other member")
+		})
+
+		It("should reject history with a PVC source", func() {
+			obj := newObject()
+			obj.Spec.Source = cloudobj.ObjectSource{
+				Type: cloudobj.SourcePVC,
+				PVC:  &cloudobj.PVCSource{ClaimName: "reports", Path: "out"},
+			}
+			obj.Spec.History = &cloudobj.ObjectHistory{}
+			expectRejected(obj, "history is not supported with PVC sources")
+		})
+
+		It("should reject an output format with a PVC source", func() {
+			obj := newObject()
+			obj.Spec.Source = cloudobj.ObjectSource{
+				Type: cloudobj.SourcePVC,
+				PVC:  &cloudobj.PVCSource{ClaimName: "reports", Path: "out"},
+			}
+			obj.Spec.Output = &cloudobj.ObjectOutput{Format: cloudobj.OutputJSON}
+			expectRejected(obj, "output conversion is not supported with PVC sources")
+		})
+
+		It("should reject bucket settings on a bucket it does not create", func() {
+			obj := newObject()
+			obj.Spec.Target.BucketSettings = &cloudobj.BucketSettings{Versioning: true}
+			expectRejected(obj, "bucketSettings only apply with createBucketIfMissing")
+		})
+
+		It("should reject a KMS key without aws:kms encryption", func() {
+			obj := newObject()
+			obj.Spec.Target.CreateBucketIfMissing = true
+			obj.Spec.Target.BucketSettings = &cloudobj.BucketSettings{Encryption: "AES256", KMSKeyID: "key"}
+			expectRejected(obj, "kmsKeyId requires aws:kms encryption")
+		})
+
+		It("should keep the target bucket immutable unless versioning is enabled", func() {
+			obj := newObject()
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+					return err
+				}
+				obj.Spec.Target.Bucket = "other-bucket"
+				return k8sClient.Update(ctx, obj)
+			}, timeout, interval).Should(WithTransform(apierrors.IsInvalid, BeTrue()))
+
+			By("requesting versioning for a bucket that may already exist")
+			requested := newObject()
+			requested.Spec.Target.CreateBucketIfMissing = true
+			requested.Spec.Target.BucketSettings = &cloudobj.BucketSettings{Versioning: true}
+			Expect(k8sClient.Create(ctx, requested)).To(Succeed())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(requested), requested); err != nil {
+					return err
+				}
+				requested.Spec.Target.Bucket = "other-bucket"
+				return k8sClient.Update(ctx, requested)
+			}, timeout, interval).Should(WithTransform(apierrors.IsInvalid, BeTrue()))
+
+			By("observing versioning on the bucket")
+			versioned := newObject()
+			Expect(k8sClient.Create(ctx, versioned)).To(Succeed())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(versioned), versioned); err != nil {
+					return err
+				}
+				versioned.Status.VersionedBucket = versioned.Spec.Target.Bucket
+				return k8sClient.Status().Update(ctx, versioned)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(versioned), versioned); err != nil {
+					return err
+				}
+				versioned.Spec.Target.Bucket = "other-bucket"
+				return k8sClient.Update(ctx, versioned)
+			}, timeout, interval).Should(Succeed())
+		})
+	})
+
+	Context("v1alpha1", func() {
+		newAlphaObject := func(source cloudobjv1alpha1.ObjectSource) *cloudobjv1alpha1.Object {
+			count++
+			return &cloudobjv1alpha1.Object{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("schema-obj-%d", count),
+					Namespace: Namespace,
+				},
+				Spec: cloudobjv1alpha1.ObjectSpec{
+					Target: cloudobjv1alpha1.ObjectTarget{Bucket: "schema-bucket", Key: "schema-alpha.key"},
+					Source: source,
+					Credentials: cloudobjv1alpha1.Credentials{
+						SecretReference: cloudobjv1alpha1.SecretKeySelector{
+							SecretReference: cloudobjv1alpha1.SecretReference{Namespace: Namespace, Name: "creds-name"},
+							Key:             "creds-key",
+						},
+					},
+				},
+			}
+		}
+
+		It("should reject a local reference without data", func() {
+			expectRejected(newAlphaObject(cloudobjv1alpha1.ObjectSource{Reference: "Local"}),
+				"data is required for a local reference")
+		})
+
+		It("should reject a configmap reference without a name and key", func() {
+			expectRejected(newAlphaObject(cloudobjv1alpha1.ObjectSource{Reference: "configmap", Name: "app-config"}),
+				"name and key are required for a configmap reference")
+		})
+	})
+})
@@ -0,0 +1,178 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+// Scope restricts the objects handled by the controller, so that several
+// controller instances can split a cluster between them
+type Scope struct {
+	// Namespaces watched, all namespaces if empty
+	Namespaces []string
+	// ExcludedNamespaces are never watched
+	ExcludedNamespaces []string
+	// Selector restricts the watched objects by label, all objects if nil
+	Selector labels.Selector
+}
+
+// NewScope returns the scope of the given namespaces and label selector
+func NewScope(namespaces, excludedNamespaces []string, selector string) (Scope, error) {
+	scope := Scope{
+		Namespaces:         namespaces,
+		ExcludedNamespaces: excludedNamespaces,
+	}
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return Scope{}, err
+		}
+		scope.Selector = parsed
+	}
+	if len(scope.Namespaces) > 0 && len(scope.namespaces()) == 0 {
+		return Scope{}, errors.New("all watched namespaces are excluded")
+	}
+	return scope, nil
+}
+
+// namespaces returns the watched namespaces without the excluded ones
+func (s Scope) namespaces() []string {
+	var namespaces []string
+	for _, ns := range s.Namespaces {
+		if !contains(s.ExcludedNamespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// Contains reports whether obj is handled by the controller
+func (s Scope) Contains(obj client.Object) bool {
+	ns := obj.GetNamespace()
+	if ns == "" {
+		// cluster-scoped objects are only handled by controllers watching
+		// every namespace
+		if len(s.Namespaces) > 0 {
+			return false
+		}
+	} else {
+		if len(s.Namespaces) > 0 && !contains(s.Namespaces, ns) {
+			return false
+		}
+		if contains(s.ExcludedNamespaces, ns) {
+			return false
+		}
+	}
+	if isStored(obj) && s.Selector != nil {
+		return s.Selector.Matches(labels.Set(obj.GetLabels()))
+	}
+	return true
+}
+
+// isStored reports whether obj is one of the kinds stored by the controller,
+// which the label selector applies to
+func isStored(obj client.Object) bool {
+	switch obj.(type) {
+	case cloudobject.Storable, *cloudobject.ScheduledObject, *cloudobject.NamespaceBackup:
+		return true
+	}
+	return false
+}
+
+// Uncached returns the kinds read from the API server rather than the cache.
+// Credentials and sources may live outside of the watched namespaces, which a
+// cache restricted to the scope cannot read, so secrets and config maps
+// bypass it whenever the namespaces are restricted
+func (s Scope) Uncached() []client.Object {
+	if len(s.Namespaces) == 0 && len(s.ExcludedNamespaces) == 0 {
+		return nil
+	}
+	return []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}}
+}
+
+// NewCache returns a cache builder that only lists and watches the objects,
+// secrets, config maps and upload jobs in the scope, to limit the memory used on large
+// clusters. Secrets and config maps are only watched through it, see Uncached
+func (s Scope) NewCache() cache.NewCacheFunc {
+	var excluded []fields.Selector
+	for _, ns := range s.ExcludedNamespaces {
+		excluded = append(excluded, fields.OneTermNotEqualSelector("metadata.namespace", ns))
+	}
+
+	objectSelector := labels.Everything()
+	if s.Selector != nil {
+		objectSelector = s.Selector
+	}
+
+	// only the upload jobs of the controller are cached
+	uploadJobs, _ := labels.NewRequirement(UploadJobLabel, selection.Exists, nil)
+	jobSelector := labels.NewSelector().Add(*uploadJobs)
+
+	selectors := cache.SelectorsByObject{
+		&cloudobject.Object{}:          {Label: objectSelector},
+		&cloudobject.ClusterObject{}:   {Label: objectSelector},
+		&cloudobject.ScheduledObject{}: {Label: objectSelector},
+		&cloudobject.NamespaceBackup{}: {Label: objectSelector},
+		&batchv1.Job{}:                 {Label: jobSelector},
+	}
+	if len(excluded) > 0 {
+		namespaceSelector := fields.AndSelectors(excluded...)
+		selectors = cache.SelectorsByObject{
+			&cloudobject.Object{}:          {Label: objectSelector, Field: namespaceSelector},
+			&cloudobject.ClusterObject{}:   {Label: objectSelector},
+			&cloudobject.ScheduledObject{}: {Label: objectSelector, Field: namespaceSelector},
+			&cloudobject.NamespaceBackup{}: {Label: objectSelector, Field: namespaceSelector},
+			&corev1.Secret{}:               {Field: namespaceSelector},
+			&corev1.ConfigMap{}:            {Field: namespaceSelector},
+			&batchv1.Job{}:                 {Label: jobSelector, Field: namespaceSelector},
+		}
+	}
+
+	namespaces := s.namespaces()
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.SelectorsByObject = selectors
+		switch len(namespaces) {
+		case 0:
+			return cache.New(config, opts)
+		case 1:
+			opts.Namespace = namespaces[0]
+			return cache.New(config, opts)
+		default:
+			return cache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+		}
+	}
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
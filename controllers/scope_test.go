@@ -0,0 +1,140 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+var _ = Describe("Scope", func() {
+	object := func(namespace string, labels map[string]string) *cloudobj.Object {
+		return &cloudobj.Object{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: namespace, Labels: labels}}
+	}
+
+	It("should contain every object without restrictions", func() {
+		scope, err := NewScope(nil, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Contains(object("default", nil))).To(BeTrue())
+	})
+
+	It("should only contain objects in the watched namespaces", func() {
+		scope, err := NewScope([]string{"team-a", "team-b"}, []string{"team-b"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.namespaces()).To(Equal([]string{"team-a"}))
+
+		Expect(scope.Contains(object("team-a", nil))).To(BeTrue())
+		Expect(scope.Contains(object("team-b", nil))).To(BeFalse())
+		Expect(scope.Contains(object("default", nil))).To(BeFalse())
+	})
+
+	It("should not contain objects in excluded namespaces", func() {
+		scope, err := NewScope(nil, []string{"kube-system"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Contains(object("kube-system", nil))).To(BeFalse())
+		Expect(scope.Contains(object("default", nil))).To(BeTrue())
+	})
+
+	It("should only apply the label selector to objects", func() {
+		scope, err := NewScope(nil, nil, "tenant=a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Contains(object("default", map[string]string{"tenant": "a"}))).To(BeTrue())
+		Expect(scope.Contains(object("default", map[string]string{"tenant": "b"}))).To(BeFalse())
+		Expect(scope.Contains(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}})).To(BeTrue())
+	})
+
+	It("should only contain cluster objects without watched namespaces", func() {
+		clusterObject := &cloudobj.ClusterObject{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: map[string]string{"tenant": "a"}}}
+
+		scope, err := NewScope(nil, []string{"kube-system"}, "tenant=a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Contains(clusterObject)).To(BeTrue())
+
+		scope, err = NewScope(nil, nil, "tenant=b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Contains(clusterObject)).To(BeFalse())
+
+		scope, err = NewScope([]string{"team-a"}, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Contains(clusterObject)).To(BeFalse())
+	})
+
+	It("should only bypass the cache for restricted namespaces", func() {
+		scope, err := NewScope(nil, nil, "tenant=a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Uncached()).To(BeEmpty())
+
+		scope, err = NewScope(nil, []string{"kube-system"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scope.Uncached()).To(ConsistOf(&corev1.Secret{}, &corev1.ConfigMap{}))
+	})
+
+	It("should read credentials outside of the watched namespaces", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "scope-creds", Namespace: "default"},
+			Data:       map[string][]byte{"creds-key": []byte("c29tZS1kYXRh")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+		}()
+
+		By("building the client of a controller watching another namespace")
+		scope, err := NewScope([]string{"team-a", "team-b"}, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+		scopedCache, err := scope.NewCache()(cfg, cache.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+		cacheCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		go func() {
+			defer GinkgoRecover()
+			Expect(scopedCache.Start(cacheCtx)).To(Succeed())
+		}()
+		Expect(scopedCache.WaitForCacheSync(cacheCtx)).To(BeTrue())
+		scopedClient, err := cluster.DefaultNewClient(scopedCache, cfg, client.Options{Scheme: scheme.Scheme}, scope.Uncached()...)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("pulling the credentials from the default namespace")
+		data, err := PullCredentials(ctx, scopedClient, cloudobj.Credentials{
+			Source: "Secret",
+			SecretReference: cloudobj.SecretKeySelector{
+				SecretReference: cloudobj.SecretReference{Namespace: "default", Name: "scope-creds"},
+				Key:             "creds-key",
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("c29tZS1kYXRh"))
+	})
+
+	It("should reject invalid scopes", func() {
+		_, err := NewScope(nil, nil, "tenant in (a")
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewScope([]string{"team-a"}, []string{"team-a"}, "")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,216 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	"dev.nimak.link/s3-copy-controller/controllers/backup"
+	"dev.nimak.link/s3-copy-controller/controllers/format"
+	"dev.nimak.link/s3-copy-controller/controllers/remote"
+)
+
+// DefaultSourceInterval is the interval remote sources are fetched again at
+// when their spec does not set one
+const DefaultSourceInterval = 10 * time.Minute
+
+// sourceNamespace returns the namespace a source of obj is read from.
+// Namespaced owners default to their own namespace, while ClusterObjects may
+// only read from their allowed namespaces.
+func sourceNamespace(obj client.Object, namespace string) (string, error) {
+	cluster, ok := obj.(*cloudobject.ClusterObject)
+	if !ok {
+		if namespace == "" {
+			namespace = obj.GetNamespace()
+		}
+		return namespace, nil
+	}
+
+	if namespace == "" {
+		return "", errors.New("a namespace is required for a namespaced source of a ClusterObject")
+	}
+	if !contains(cluster.Spec.AllowedNamespaces, namespace) {
+		return "", errors.Errorf("namespace %s is not allowed for ClusterObject %s", namespace, cluster.Name)
+	}
+	return namespace, nil
+}
+
+// inputNamespace returns the namespace the secrets and template inputs of
+// obj are read from. Namespaced owners may only read them in their own
+// namespace, as their values are sent to a server or stored in a bucket of
+// their choosing, while ClusterObjects may read from their allowed namespaces.
+func inputNamespace(obj client.Object, namespace string) (string, error) {
+	if _, ok := obj.(*cloudobject.ClusterObject); ok {
+		return sourceNamespace(obj, namespace)
+	}
+	if namespace != "" && namespace != obj.GetNamespace() {
+		return "", errors.Errorf("namespace %s is not allowed for %s, secrets and template inputs are only read from its own namespace", namespace, client.ObjectKeyFromObject(obj))
+	}
+	return obj.GetNamespace(), nil
+}
+
+// resourceManifest returns the manifest of the resource without its status
+// and the metadata populated by the API server, so that it only changes with
+// the resource spec and can be applied again. ScheduledObjects may only read
+// resources in their own namespace.
+func resourceManifest(ctx context.Context, c client.Reader, obj client.Object, ref *cloudobject.ResourceSource) ([]byte, error) {
+	key := types.NamespacedName{Name: ref.Name}
+	switch obj.(type) {
+	case *cloudobject.ClusterObject:
+		if ref.Namespace != "" {
+			namespace, err := sourceNamespace(obj, ref.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			key.Namespace = namespace
+		}
+	case *cloudobject.ScheduledObject:
+		if ref.Namespace != "" && ref.Namespace != obj.GetNamespace() {
+			return nil, errors.Errorf("namespace %s is not allowed for ScheduledObject %s", ref.Namespace, client.ObjectKeyFromObject(obj))
+		}
+		key.Namespace = obj.GetNamespace()
+	default:
+		return nil, errors.New("resource sources are only supported by ClusterObject and ScheduledObject")
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid apiVersion %s", ref.APIVersion)
+	}
+	resource := &unstructured.Unstructured{}
+	resource.SetGroupVersionKind(gv.WithKind(ref.Kind))
+	if err := c.Get(ctx, key, resource); err != nil {
+		return nil, errors.Wrapf(err, "unable to get %s %s", ref.Kind, key)
+	}
+	if key.Namespace != "" && resource.GetNamespace() != key.Namespace {
+		// the namespace is ignored when getting a cluster-scoped resource
+		return nil, errors.Errorf("%s %s is not a namespaced resource", ref.Kind, ref.Name)
+	}
+
+	backup.Clean(resource)
+	return yaml.Marshal(resource.Object)
+}
+
+// fetchURL returns the content served at the URL of src with its ETag,
+// verifying the content against the checksum pinned by src
+func fetchURL(ctx context.Context, c client.Reader, obj client.Object, src *cloudobject.HTTPSource, revision string) ([]byte, string, error) {
+	var authorization string
+	if src.AuthSecretRef != nil {
+		value, err := sourceSecret(ctx, c, obj, src.AuthSecretRef)
+		if err != nil {
+			return nil, "", err
+		}
+		authorization = strings.TrimSpace(string(value))
+	}
+
+	result, err := remote.Fetch(ctx, remote.DefaultClient, src.URL, authorization, revision)
+	if err != nil {
+		return nil, "", err
+	}
+	if src.SHA256 != "" && checksum(result.Data) != src.SHA256 {
+		return nil, "", errors.Errorf("content of %s does not match sha256 %s", src.URL, src.SHA256)
+	}
+	return result.Data, result.Revision, nil
+}
+
+// pullArtifact returns the content of the layer of the artifact referenced by
+// src with the digest of its manifest
+func pullArtifact(ctx context.Context, c client.Reader, obj client.Object, src *cloudobject.OCISource, revision string) ([]byte, string, error) {
+	artifact := remote.Artifact{
+		Reference: src.Reference,
+		MediaType: src.MediaType,
+		File:      src.File,
+		Insecure:  src.Insecure,
+	}
+	if src.PullSecretRef != nil {
+		config, err := sourceSecret(ctx, c, obj, src.PullSecretRef)
+		if err != nil {
+			return nil, "", err
+		}
+		artifact.DockerConfig = config
+	}
+
+	result, err := remote.Pull(ctx, remote.DefaultClient, artifact, revision)
+	if err != nil {
+		return nil, "", err
+	}
+	return result.Data, result.Revision, nil
+}
+
+// sourceSecret returns the value of the secret key holding the credentials of
+// a remote source of obj, read from the namespaces allowed for its secrets
+func sourceSecret(ctx context.Context, c client.Reader, obj client.Object, ref *cloudobject.SourceSecretKeySelector) ([]byte, error) {
+	namespace, err := inputNamespace(obj, ref.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return SecretKey(ctx, c, cloudobject.SecretKeySelector{
+		SecretReference: cloudobject.SecretReference{Namespace: namespace, Name: ref.Name},
+		Key:             ref.Key,
+	})
+}
+
+// sourceInterval returns the interval src is fetched again at, zero for
+// in-cluster sources which are not polled
+func sourceInterval(src cloudobject.ObjectSource) time.Duration {
+	var interval *metav1.Duration
+	switch {
+	case src.Type == cloudobject.SourceHTTP && src.HTTP != nil:
+		interval = src.HTTP.Interval
+	case src.Type == cloudobject.SourceOCI && src.OCI != nil:
+		interval = src.OCI.Interval
+	case src.Type == cloudobject.SourceS3 && src.S3 != nil:
+		interval = src.S3.Interval
+	default:
+		return 0
+	}
+
+	if interval == nil || interval.Duration <= 0 {
+		return DefaultSourceInterval
+	}
+	return interval.Duration
+}
+
+// convert returns data in the output format of an object, as is if it has no
+// output
+func convert(data []byte, output *cloudobject.ObjectOutput) ([]byte, error) {
+	if output == nil {
+		return data, nil
+	}
+	converted, err := format.Convert(data, string(output.Format))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert the source to %s", output.Format)
+	}
+	return converted, nil
+}
+
+// converts reports whether the content stored with output differs from the
+// content of the source
+func converts(output *cloudobject.ObjectOutput) bool {
+	return output != nil && output.Format != "" && output.Format != cloudobject.OutputRaw
+}
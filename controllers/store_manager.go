@@ -17,16 +17,70 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
 	awshelper "dev.nimak.link/s3-copy-controller/controllers/aws"
 )
 
-type storeManager struct{}
+const (
+	// how long validation results are reused for the same credentials
+	validationTTL       = 10 * time.Minute
+	failedValidationTTL = 30 * time.Second
+)
+
+type validation struct {
+	err     error
+	expires time.Time
+}
+
+type storeManager struct {
+	limiters *awshelper.Limiters
+
+	mu          sync.Mutex
+	validations map[string]validation
+	validate    func(context.Context, ctrlapi.ConfigData, string) error
+}
 
 func NewStoreManager() ctrlapi.StoreManager {
-	return &storeManager{}
+	return &storeManager{
+		limiters:    awshelper.NewLimiters(),
+		validations: map[string]validation{},
+		validate:    awshelper.ValidateAccess,
+	}
+}
+
+func (s *storeManager) Get(cfg ctrlapi.ConfigData) ctrlapi.ObjectStore {
+	return awshelper.NewS3ObjectStore(cfg, s.limiters)
 }
 
-func (s storeManager) Get(cfg ctrlapi.ConfigData) ctrlapi.ObjectStore {
-	return awshelper.NewS3ObjectStore(cfg)
+// Validate checks the credentials against the bucket, reusing the result of
+// a previous check of the same credentials, region and bucket
+func (s *storeManager) Validate(ctx context.Context, cfg ctrlapi.ConfigData, bucket string) error {
+	key := checksum(cfg.Secret) + "/" + cfg.Region + "/" + bucket
+
+	s.mu.Lock()
+	cached, ok := s.validations[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.err
+	}
+
+	err := s.validate(ctx, cfg, bucket)
+	ttl := validationTTL
+	if err != nil {
+		ttl = failedValidationTTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.validations {
+		if time.Now().After(v.expires) {
+			delete(s.validations, k)
+		}
+	}
+	s.validations[key] = validation{err: err, expires: time.Now().Add(ttl)}
+	return err
 }
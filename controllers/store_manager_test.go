@@ -0,0 +1,51 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+var _ = Describe("Store manager", func() {
+	It("should reuse validation results per credentials, region and bucket", func() {
+		calls := 0
+		manager := &storeManager{
+			validations: map[string]validation{},
+			validate: func(context.Context, ctrlapi.ConfigData, string) error {
+				calls++
+				return nil
+			},
+		}
+		cfg := ctrlapi.ConfigData{Secret: []byte("creds"), Region: "us-west-2"}
+
+		Expect(manager.Validate(ctx, cfg, "test-bucket")).To(Succeed())
+		Expect(manager.Validate(ctx, cfg, "test-bucket")).To(Succeed())
+		Expect(calls).To(Equal(1))
+
+		Expect(manager.Validate(ctx, cfg, "other-bucket")).To(Succeed())
+		Expect(calls).To(Equal(2))
+
+		cfg.Secret = []byte("rotated-creds")
+		Expect(manager.Validate(ctx, cfg, "test-bucket")).To(Succeed())
+		Expect(calls).To(Equal(3))
+	})
+})
@@ -18,11 +18,20 @@ package controllers
 
 import (
 	"context"
+	"io/ioutil"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -31,8 +40,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
 
-	cloudobj "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	cloudobjv1alpha1 "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
 	"dev.nimak.link/s3-copy-controller/controllers/api/apifakes"
 	//+kubebuilder:scaffold:imports
 )
@@ -44,6 +56,9 @@ var ctx context.Context
 var cancel context.CancelFunc
 var fakeStoreManager *apifakes.FakeStoreManager
 var fakeObjectStore *apifakes.FakeObjectStore
+var spanExporter *tracetest.InMemoryExporter
+
+const scheduleClockSkew = 2 * time.Minute
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -58,18 +73,24 @@ var _ = BeforeSuite(func() {
 
 	ctx, cancel = context.WithCancel(context.TODO())
 
+	spanExporter = tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+
 	By("bootstrapping test environment")
-	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
-		ErrorIfCRDPathMissing: true,
-	}
+	testEnv = &envtest.Environment{}
 
-	cfg, err := testEnv.Start()
+	var err error
+	cfg, err = testEnv.Start()
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
+	err = installCRDs(cfg, filepath.Join("..", "config", "crd", "bases"))
+	Expect(err).NotTo(HaveOccurred())
+
 	err = cloudobj.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = cloudobjv1alpha1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:scheme
 
@@ -84,15 +105,29 @@ var _ = BeforeSuite(func() {
 
 	fakeStoreManager = &apifakes.FakeStoreManager{}
 	fakeObjectStore = &apifakes.FakeObjectStore{}
+	fakeObjectStore.HeadStub = headLastStored
 	fakeStoreManager.GetReturns(fakeObjectStore)
 
-	err = (&ObjectReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		Recorder:     mgr.GetEventRecorderFor("object-controller"),
-		StoreManager: fakeStoreManager,
+	objectReconciler := &ObjectReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("object-controller"),
+		StoreManager:  fakeStoreManager,
+		UploaderImage: "controller:test",
+		Cluster:       ClusterInfo{Name: "test-cluster", Version: "v1.22.1"},
+	}
+	err = objectReconciler.SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+	err = (&ClusterObjectReconciler{ObjectReconciler: objectReconciler}).SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+	err = (&ScheduledObjectReconciler{
+		ObjectReconciler: objectReconciler,
+		// every schedule firing at least once per minute is due right away
+		Clock: func() time.Time { return time.Now().Add(scheduleClockSkew) },
 	}).SetupWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
+	err = (&NamespaceBackupReconciler{ObjectReconciler: objectReconciler}).SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
 
 	go func() {
 		defer GinkgoRecover()
@@ -102,6 +137,60 @@ var _ = BeforeSuite(func() {
 
 }, 60)
 
+// headLastStored emulates a bucket by answering with the metadata of the
+// last object stored into the target
+func headLastStored(_ context.Context, target cloudobj.ObjectTarget) (ctrlapi.ObjectInfo, error) {
+	for i := fakeObjectStore.StoreCallCount() - 1; i >= 0; i-- {
+		_, _, stored, metadata := fakeObjectStore.StoreArgsForCall(i)
+		if stored.Bucket == target.Bucket && stored.Key == target.Key {
+			return ctrlapi.ObjectInfo{Metadata: metadata}, nil
+		}
+	}
+	return ctrlapi.ObjectInfo{}, ctrlapi.ErrNotFound
+}
+
+// installCRDs creates the CRDs of dir as unstructured objects, the typed CRDs
+// envtest installs predate x-kubernetes-validations and would drop the CEL
+// rules of the schemas
+func installCRDs(cfg *rest.Config, dir string) error {
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.Errorf("no CRDs found in %s", dir)
+	}
+
+	var crds []apiextensionsv1.CustomResourceDefinition
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		crd := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(data, &crd.Object); err != nil {
+			return errors.Wrapf(err, "cannot decode %s", file)
+		}
+		if err := c.Create(context.Background(), crd); err != nil {
+			return errors.Wrapf(err, "cannot create the CRD of %s", file)
+		}
+
+		var typed apiextensionsv1.CustomResourceDefinition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(crd.Object, &typed); err != nil {
+			return err
+		}
+		crds = append(crds, typed)
+	}
+	return envtest.WaitForCRDs(cfg, crds, envtest.CRDInstallOptions{
+		MaxTime:      10 * time.Second,
+		PollInterval: 100 * time.Millisecond,
+	})
+}
+
 var _ = AfterSuite(func() {
 	cancel()
 	By("tearing down the test environment")
@@ -0,0 +1,234 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+// templateInputIndexKey indexes objects by the configmaps and secrets read by
+// their templates
+const templateInputIndexKey = "spec.source.inline.template.inputs"
+
+// A ClusterInfo describes the cluster of the controller to templates
+type ClusterInfo struct {
+	// Name of the cluster, as configured on the controller
+	Name string
+	// Version of the Kubernetes API server, e.g. v1.22.1
+	Version string
+}
+
+// TemplateData is the data templates are rendered with
+type TemplateData struct {
+	// Object is the metadata of the object rendering the template
+	Object TemplateObject
+	// Cluster describes the cluster of the controller
+	Cluster ClusterInfo
+	// Values of the inputs of the template by name
+	Values map[string]string
+}
+
+// TemplateObject is the metadata of an object available to templates
+type TemplateObject struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// templateFuncs are the functions available to templates on top of the
+// builtin ones
+var templateFuncs = template.FuncMap{
+	"b64enc": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec": func(s string) (string, error) {
+		data, err := base64.StdEncoding.DecodeString(s)
+		return string(data), err
+	},
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"required": func(msg, value string) (string, error) {
+		if value == "" {
+			return "", errors.New(msg)
+		}
+		return value, nil
+	},
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+	},
+	"quote": func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data)
+	},
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"toJson": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"toYaml": func(v interface{}) (string, error) {
+		data, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(data), "\n"), err
+	},
+}
+
+// renderTemplate renders the data of src as a Go template on behalf of owner,
+// failing on references to missing values
+func renderTemplate(ctx context.Context, c client.Reader, owner client.Object, src *cloudobject.InlineSource, cluster ClusterInfo) ([]byte, error) {
+	tmpl, err := template.New("data").Option("missingkey=error").Funcs(templateFuncs).Parse(src.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid template")
+	}
+
+	values, err := templateValues(ctx, c, owner, src.Template.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	data := TemplateData{
+		Object: TemplateObject{
+			Name:        owner.GetName(),
+			Namespace:   owner.GetNamespace(),
+			Labels:      owner.GetLabels(),
+			Annotations: owner.GetAnnotations(),
+		},
+		Cluster: cluster,
+		Values:  values,
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, errors.Wrap(err, "unable to render template")
+	}
+	return out.Bytes(), nil
+}
+
+// templateValues reads the values of the inputs of a template, from the
+// namespaces allowed for the inputs of owner
+func templateValues(ctx context.Context, c client.Reader, owner client.Object, inputs []cloudobject.TemplateInput) (map[string]string, error) {
+	values := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		switch {
+		case input.ConfigMapKeyRef != nil:
+			ref := input.ConfigMapKeyRef
+			namespace, err := inputNamespace(owner, ref.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			var cm corev1.ConfigMap
+			key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+			if err := c.Get(ctx, key, &cm); err != nil {
+				return nil, errors.Wrapf(err, "unable to read input %s", input.Name)
+			}
+			value, ok := cm.Data[ref.Key]
+			if !ok {
+				return nil, errors.Errorf("key %s not found in configmap %s for input %s", ref.Key, key, input.Name)
+			}
+			values[input.Name] = value
+
+		case input.SecretKeyRef != nil:
+			value, err := sourceSecret(ctx, c, owner, input.SecretKeyRef)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read input %s", input.Name)
+			}
+			values[input.Name] = string(value)
+
+		default:
+			return nil, errors.Errorf("input %s requires a configMapKeyRef or a secretKeyRef", input.Name)
+		}
+	}
+	return values, nil
+}
+
+// templateInputIndexValue is the index value of a configmap or secret read by
+// templates
+func templateInputIndexValue(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// indexTemplateInputs returns the configmaps and secrets read by the
+// template of o, if any
+func indexTemplateInputs(o client.Object) []string {
+	src := o.(cloudobject.Storable).GetSpec().Source
+	if src.Type != cloudobject.SourceInline || src.Inline == nil || src.Inline.Template == nil {
+		return nil
+	}
+
+	var values []string
+	for _, input := range src.Inline.Template.Inputs {
+		switch {
+		case input.ConfigMapKeyRef != nil:
+			namespace, err := inputNamespace(o, input.ConfigMapKeyRef.Namespace)
+			if err == nil {
+				values = append(values, templateInputIndexValue("ConfigMap", namespace, input.ConfigMapKeyRef.Name))
+			}
+		case input.SecretKeyRef != nil:
+			namespace, err := inputNamespace(o, input.SecretKeyRef.Namespace)
+			if err == nil {
+				values = append(values, templateInputIndexValue("Secret", namespace, input.SecretKeyRef.Name))
+			}
+		}
+	}
+	return values
+}
+
+// objectsWithConfigMapInput enqueues the objects whose template reads the
+// configmap o
+func (r *ObjectReconciler) objectsWithConfigMapInput(o client.Object) []reconcile.Request {
+	return r.requestsForInput(templateInputIndexValue("ConfigMap", o.GetNamespace(), o.GetName()), &cloudobject.ObjectList{})
+}
+
+// objectsWithSecretInput enqueues the objects whose template reads the
+// secret o
+func (r *ObjectReconciler) objectsWithSecretInput(o client.Object) []reconcile.Request {
+	return r.requestsForInput(templateInputIndexValue("Secret", o.GetNamespace(), o.GetName()), &cloudobject.ObjectList{})
+}
+
+// requestsForInput enqueues the items of the list whose template reads the
+// input indexed by value
+func (r *ObjectReconciler) requestsForInput(value string, list client.ObjectList) []reconcile.Request {
+	if err := r.List(context.Background(), list, client.MatchingFields{templateInputIndexKey: value}); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	_ = meta.EachListItem(list, func(item runtime.Object) error {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(item.(client.Object))})
+		return nil
+	})
+	return requests
+}
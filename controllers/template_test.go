@@ -0,0 +1,112 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+)
+
+var _ = Describe("Template", func() {
+	var (
+		c     client.Client
+		owner *cloudobj.Object
+		src   *cloudobj.InlineSource
+	)
+
+	BeforeEach(func() {
+		c = fake.NewClientBuilder().WithObjects(
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "endpoints", Namespace: "apps"},
+				Data:       map[string]string{"api": "https://api.example.com"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "client", Namespace: "apps"},
+				Data:       map[string][]byte{"token": []byte("s3cr3t")},
+			},
+		).Build()
+		owner = &cloudobj.Object{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "client-config",
+				Namespace: "apps",
+				Labels:    map[string]string{"team": "payments"},
+			},
+		}
+		src = &cloudobj.InlineSource{
+			Template: &cloudobj.InlineTemplate{
+				Inputs: []cloudobj.TemplateInput{
+					{Name: "api", ConfigMapKeyRef: &cloudobj.ConfigMapSource{Name: "endpoints", Key: "api"}},
+					{Name: "token", SecretKeyRef: &cloudobj.SourceSecretKeySelector{Name: "client", Key: "token"}},
+				},
+			},
+		}
+	})
+
+	It("should render the object metadata, the cluster info and the input values", func() {
+		src.Data = "endpoint: {{ .Values.api }}\n" +
+			"token: {{ .Values.token | b64enc }}\n" +
+			"team: {{ .Object.Labels.team }}\n" +
+			"cluster: {{ .Cluster.Name }}/{{ .Object.Namespace }}/{{ .Object.Name }}\n"
+
+		data, err := renderTemplate(context.Background(), c, owner, src, ClusterInfo{Name: "prod"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("endpoint: https://api.example.com\n" +
+			"token: czNjcjN0\n" +
+			"team: payments\n" +
+			"cluster: prod/apps/client-config\n"))
+	})
+
+	It("should fail on a reference to a missing value", func() {
+		src.Data = "{{ .Values.missing }}"
+
+		_, err := renderTemplate(context.Background(), c, owner, src, ClusterInfo{})
+		Expect(err).To(MatchError(ContainSubstring("missing")))
+	})
+
+	It("should fail on a missing input", func() {
+		src.Data = "{{ .Values.api }}"
+		src.Template.Inputs[0].ConfigMapKeyRef.Key = "web"
+
+		_, err := renderTemplate(context.Background(), c, owner, src, ClusterInfo{})
+		Expect(err).To(MatchError(ContainSubstring("key web not found")))
+	})
+
+	It("should only read inputs in the namespace of the object", func() {
+		src.Data = "{{ .Values.token }}"
+		src.Template.Inputs[1].SecretKeyRef.Namespace = "kube-system"
+
+		_, err := renderTemplate(context.Background(), c, owner, src, ClusterInfo{})
+		Expect(err).To(MatchError(ContainSubstring("namespace kube-system is not allowed")))
+
+		owner.Spec.Source = cloudobj.ObjectSource{Type: cloudobj.SourceInline, Inline: src}
+		Expect(indexTemplateInputs(owner)).To(ConsistOf("ConfigMap/apps/endpoints"))
+	})
+
+	It("should index the inputs of a template", func() {
+		owner.Spec.Source = cloudobj.ObjectSource{Type: cloudobj.SourceInline, Inline: src}
+
+		Expect(indexTemplateInputs(owner)).To(ConsistOf("ConfigMap/apps/endpoints", "Secret/apps/client"))
+	})
+})
@@ -0,0 +1,172 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	tracerName  = "dev.nimak.link/s3-copy-controller/controllers"
+	serviceName = "s3-copy-controller"
+)
+
+// TracingOptions configures the export of traces over OTLP
+type TracingOptions struct {
+	// Endpoint of the OTLP gRPC collector, tracing is disabled if empty
+	Endpoint string
+	// Insecure disables TLS towards the collector
+	Insecure bool
+	// SampleRatio is the fraction of traces sampled, between 0 and 1
+	SampleRatio float64
+}
+
+// SetupTracing installs a global tracer provider exporting spans to the
+// configured OTLP endpoint. The returned function flushes and stops the
+// exporter.
+func SetupTracing(ctx context.Context, opts TracingOptions) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewTracerProvider(exporter, opts.SampleRatio)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates a tracer provider sending sampled spans to the
+// given exporter
+func NewTracerProvider(exporter sdktrace.SpanExporter, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func targetAttributes(target cloudobject.ObjectTarget) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("objectstore.bucket", target.Bucket),
+		attribute.String("objectstore.key", target.Key),
+		attribute.String("objectstore.region", target.Region),
+	}
+}
+
+// tracedObjectStore records a span for every call made to the wrapped ObjectStore
+type tracedObjectStore struct {
+	ctrlapi.ObjectStore
+}
+
+func withTracing(store ctrlapi.ObjectStore) ctrlapi.ObjectStore {
+	return &tracedObjectStore{ObjectStore: store}
+}
+
+func (t *tracedObjectStore) Store(ctx context.Context, content []byte, target cloudobject.ObjectTarget, metadata map[string]string) (ctrlapi.ObjectInfo, error) {
+	ctx, span := startSpan(ctx, "ObjectStore.Store", append(targetAttributes(target), attribute.Int("objectstore.size", len(content)))...)
+	info, err := t.ObjectStore.Store(ctx, content, target, metadata)
+	endSpan(span, err)
+	return info, err
+}
+
+func (t *tracedObjectStore) Head(ctx context.Context, target cloudobject.ObjectTarget) (ctrlapi.ObjectInfo, error) {
+	ctx, span := startSpan(ctx, "ObjectStore.Head", targetAttributes(target)...)
+	info, err := t.ObjectStore.Head(ctx, target)
+	if errors.Is(err, ctrlapi.ErrNotFound) {
+		// a missing object is an expected answer
+		endSpan(span, nil)
+		return info, err
+	}
+	endSpan(span, err)
+	return info, err
+}
+
+func (t *tracedObjectStore) Get(ctx context.Context, target cloudobject.ObjectTarget, versionID string) ([]byte, ctrlapi.ObjectInfo, error) {
+	ctx, span := startSpan(ctx, "ObjectStore.Get", append(targetAttributes(target), attribute.String("objectstore.version_id", versionID))...)
+	content, info, err := t.ObjectStore.Get(ctx, target, versionID)
+	if errors.Is(err, ctrlapi.ErrNotFound) {
+		endSpan(span, nil)
+		return content, info, err
+	}
+	endSpan(span, err)
+	return content, info, err
+}
+
+func (t *tracedObjectStore) Copy(ctx context.Context, source, target cloudobject.ObjectTarget, metadata map[string]string) (ctrlapi.ObjectInfo, error) {
+	ctx, span := startSpan(ctx, "ObjectStore.Copy", append(targetAttributes(target),
+		attribute.String("objectstore.source_bucket", source.Bucket),
+		attribute.String("objectstore.source_key", source.Key))...)
+	info, err := t.ObjectStore.Copy(ctx, source, target, metadata)
+	endSpan(span, err)
+	return info, err
+}
+
+func (t *tracedObjectStore) Delete(ctx context.Context, target cloudobject.ObjectTarget) error {
+	ctx, span := startSpan(ctx, "ObjectStore.Delete", targetAttributes(target)...)
+	err := t.ObjectStore.Delete(ctx, target)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedObjectStore) DeleteVersion(ctx context.Context, target cloudobject.ObjectTarget, versionID string) error {
+	ctx, span := startSpan(ctx, "ObjectStore.DeleteVersion", append(targetAttributes(target), attribute.String("objectstore.version_id", versionID))...)
+	err := t.ObjectStore.DeleteVersion(ctx, target, versionID)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedObjectStore) CreateBucket(ctx context.Context, target cloudobject.ObjectTarget) error {
+	ctx, span := startSpan(ctx, "ObjectStore.CreateBucket", targetAttributes(target)...)
+	err := t.ObjectStore.CreateBucket(ctx, target)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	// UploadCommand runs the manager binary as the uploader of an upload job
+	UploadCommand = "upload"
+	// UploadSpecEnv holds the UploadSpec of an upload job as json
+	UploadSpecEnv = "S3COPY_UPLOAD_SPEC"
+	// ChecksumMetadataKey holds the sha256 checksum of the content stored by
+	// an upload job, read back by the controller once the job succeeded
+	ChecksumMetadataKey = "s3copy-checksum"
+)
+
+// An UploadSpec tells an upload job what to store where
+type UploadSpec struct {
+	// Path of the file or directory to store
+	Path string `json:"path"`
+	// CredentialsFile holds the credentials of the object store
+	CredentialsFile string `json:"credentialsFile"`
+	// Target the content is stored into
+	Target cloudobject.ObjectTarget `json:"target"`
+	// Metadata stamped on the stored object
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Limits pace and retry the calls to the object store
+	Limits ctrlapi.Limits `json:"limits"`
+	// Endpoints of the object store
+	Endpoints ctrlapi.Endpoints `json:"endpoints"`
+	// CreateBucket creates the target bucket if it is missing
+	CreateBucket bool `json:"createBucket,omitempty"`
+}
+
+// RunUpload runs the uploader of an upload job, reading its spec from the
+// environment
+func RunUpload(ctx context.Context) error {
+	var spec UploadSpec
+	if err := json.Unmarshal([]byte(os.Getenv(UploadSpecEnv)), &spec); err != nil {
+		return errors.Wrapf(err, "invalid %s", UploadSpecEnv)
+	}
+	_, err := Upload(ctx, NewStoreManager(), spec)
+	return err
+}
+
+// Upload stores the file or directory of spec into its target
+func Upload(ctx context.Context, manager ctrlapi.StoreManager, spec UploadSpec) (ctrlapi.ObjectInfo, error) {
+	secret, err := os.ReadFile(spec.CredentialsFile)
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, errors.Wrap(err, "unable to read credentials")
+	}
+	data, err := readPath(spec.Path)
+	if err != nil {
+		return ctrlapi.ObjectInfo{}, errors.Wrapf(err, "unable to read %s", spec.Path)
+	}
+
+	metadata := map[string]string{ChecksumMetadataKey: checksum(data)}
+	for k, v := range spec.Metadata {
+		metadata[k] = v
+	}
+	objectStore := manager.Get(ctrlapi.ConfigData{
+		Secret:    secret,
+		Region:    spec.Target.Region,
+		Limits:    spec.Limits,
+		Endpoints: spec.Endpoints,
+	})
+
+	info, err := objectStore.Store(ctx, data, spec.Target, metadata)
+	if !errors.Is(err, ctrlapi.ErrBucketNotFound) || !spec.CreateBucket {
+		return info, err
+	}
+	if err := objectStore.CreateBucket(ctx, spec.Target); err != nil {
+		return info, err
+	}
+	return objectStore.Store(ctx, data, spec.Target, metadata)
+}
+
+// readPath returns the content of a file, or a gzipped tarball of the files
+// of a directory named relative to the directory
+func readPath(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.ReadFile(path)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			// sockets, devices and links are not archived
+			return nil
+		}
+		name, err := filepath.Rel(path, file)
+		if err != nil || name == "." {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
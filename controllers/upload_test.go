@@ -0,0 +1,125 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	cloudobj "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+	"dev.nimak.link/s3-copy-controller/controllers/api/apifakes"
+)
+
+var _ = Describe("Upload", func() {
+	var (
+		dir         string
+		manager     *apifakes.FakeStoreManager
+		objectStore *apifakes.FakeObjectStore
+		spec        UploadSpec
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "upload")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "credentials"), []byte("c29tZS1kYXRh"), 0600)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(dir, "reports", "daily"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "reports", "summary.csv"), []byte("a,b"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "reports", "daily", "monday.csv"), []byte("c,d"), 0600)).To(Succeed())
+
+		objectStore = &apifakes.FakeObjectStore{}
+		objectStore.StoreReturns(ctrlapi.ObjectInfo{ETag: "etag"}, nil)
+		manager = &apifakes.FakeStoreManager{}
+		manager.GetReturns(objectStore)
+		spec = UploadSpec{
+			CredentialsFile: filepath.Join(dir, "credentials"),
+			Target:          cloudobj.ObjectTarget{Bucket: "reports-bucket", Region: "us-west-2", Key: "reports"},
+			Metadata:        map[string]string{OwnerMetadataKey: "default/reports"},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("stores a file with its checksum", func() {
+		spec.Path = filepath.Join(dir, "reports", "summary.csv")
+		info, err := Upload(context.Background(), manager, spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.ETag).To(Equal("etag"))
+
+		Expect(string(manager.GetArgsForCall(0).Secret)).To(Equal("c29tZS1kYXRh"))
+		_, data, target, metadata := objectStore.StoreArgsForCall(0)
+		Expect(string(data)).To(Equal("a,b"))
+		Expect(target.Key).To(Equal("reports"))
+		Expect(metadata).To(Equal(map[string]string{
+			OwnerMetadataKey:    "default/reports",
+			ChecksumMetadataKey: checksum([]byte("a,b")),
+		}))
+	})
+
+	It("stores a directory as a gzipped tarball", func() {
+		spec.Path = filepath.Join(dir, "reports")
+		_, err := Upload(context.Background(), manager, spec)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, data, _, _ := objectStore.StoreArgsForCall(0)
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		Expect(err).NotTo(HaveOccurred())
+		tr := tar.NewReader(gz)
+		files := map[string]string{}
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			content, err := io.ReadAll(tr)
+			Expect(err).NotTo(HaveOccurred())
+			files[header.Name] = string(content)
+		}
+		Expect(files).To(Equal(map[string]string{
+			"daily/":           "",
+			"daily/monday.csv": "c,d",
+			"summary.csv":      "a,b",
+		}))
+	})
+
+	It("creates the missing bucket when allowed", func() {
+		spec.Path = filepath.Join(dir, "reports", "summary.csv")
+		objectStore.StoreReturnsOnCall(0, ctrlapi.ObjectInfo{}, &ctrlapi.Error{Reason: ctrlapi.ReasonBucketNotFound, Message: "bucket not found"})
+		_, err := Upload(context.Background(), manager, spec)
+		Expect(err).To(MatchError(ctrlapi.ErrBucketNotFound))
+		Expect(objectStore.CreateBucketCallCount()).To(BeZero())
+
+		spec.CreateBucket = true
+		objectStore.StoreReturnsOnCall(1, ctrlapi.ObjectInfo{}, &ctrlapi.Error{Reason: ctrlapi.ReasonBucketNotFound, Message: "bucket not found"})
+		_, err = Upload(context.Background(), manager, spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objectStore.CreateBucketCallCount()).To(Equal(1))
+		Expect(objectStore.StoreCallCount()).To(Equal(3))
+	})
+})
@@ -0,0 +1,65 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	ConditionCredentialsValid = "CredentialsValid"
+
+	// condition reasons
+	ReasonValidated        = "Validated"
+	ReasonValidationFailed = "ValidationFailed"
+)
+
+// validateAccess checks the credentials of the object against its target
+// bucket before any data is stored and records the outcome in the
+// CredentialsValid condition
+func (r *ObjectReconciler) validateAccess(ctx context.Context, obj cloudobject.Storable, cfg ctrlapi.ConfigData) (err error) {
+	ctx, span := startSpan(ctx, "StoreManager.Validate", targetAttributes(obj.GetSpec().Target)...)
+	defer func() { endSpan(span, err) }()
+
+	err = r.StoreManager.Validate(ctx, cfg, obj.GetSpec().Target.Bucket)
+	if errors.Is(err, ctrlapi.ErrBucketNotFound) {
+		// the credentials are fine, storing reports or creates the bucket
+		err = nil
+	}
+
+	condition := metav1.Condition{
+		Type:               ConditionCredentialsValid,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             ReasonValidated,
+		Message:            "credentials grant access to the bucket",
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = failureReason(err, ReasonValidationFailed)
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&obj.GetStatus().Conditions, condition)
+	return err
+}
@@ -0,0 +1,460 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cloudobject "dev.nimak.link/s3-copy-controller/api/v1beta1"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
+)
+
+const (
+	// UploadJobLabel marks the upload jobs of the controller, its value
+	// identifying their Object
+	UploadJobLabel = "objstore.dev.nimak.link/upload-for"
+	// GenerationAnnotation records the generation of the Object an upload
+	// job uploads
+	GenerationAnnotation = "objstore.dev.nimak.link/generation"
+
+	UploadStarted = "UploadStarted"
+	UploadFailed  = "UploadFailed"
+
+	uploadBackoffLimit    = 2
+	uploadTimeout         = time.Hour
+	uploadRetryDelay      = time.Minute
+	uploadDataPath        = "/data"
+	uploadCredentialsPath = "/var/run/s3copy"
+	uploadCredentialsFile = "credentials"
+)
+
+// uploadError is the failure reported by an upload job
+type uploadError struct {
+	job     string
+	failure string
+}
+
+func (e *uploadError) Error() string {
+	return fmt.Sprintf("upload job %s failed: %s", e.job, e.failure)
+}
+
+// processVolume stores the content of a PVC source through a job mounting the
+// claim, as the volume is only readable from a pod. The job is started when
+// the object is out of sync and tracked in the object status until it
+// finishes, a succeeded job being deleted once its result is recorded and a
+// failed job being kept until the next upload.
+func (r *ObjectReconciler) processVolume(ctx context.Context, objectStore ctrlapi.ObjectStore, obj cloudobject.Storable, storeConfig ctrlapi.ConfigData) (controllerError error) {
+	var err error
+	defer func() {
+		if processingError := r.processError(ctx, obj, StoreAction, &err); processingError != nil {
+			controllerError = processingError
+		}
+	}()
+
+	owner, ok := obj.(*cloudobject.Object)
+	if !ok {
+		err = errors.New("pvc sources are only supported by Object")
+		return
+	}
+	if owner.Spec.Source.PVC == nil {
+		err = errors.New("pvc required for a 'PVC' source")
+		return
+	}
+	if r.UploaderImage == "" {
+		err = errors.New("pvc sources require the controller to run with an uploader image")
+		return
+	}
+	if owner.Spec.Credentials.SecretReference.Namespace != owner.Namespace {
+		// the job mounts the secret, which is only possible in its own
+		// namespace
+		err = errors.New("pvc sources require credentials in the namespace of the object")
+		return
+	}
+
+	job := &batchv1.Job{}
+	jobKey := types.NamespacedName{Namespace: owner.Namespace, Name: uploadJobName(owner)}
+	if getErr := r.Get(ctx, jobKey, job); getErr == nil {
+		return r.trackUpload(ctx, objectStore, owner, job, &err)
+	} else if !apierrors.IsNotFound(getErr) {
+		return getErr
+	}
+
+	if owner.Status.Synced && owner.Status.ObservedGeneration == owner.Generation && !r.resyncRequested(owner) {
+		return nil
+	}
+
+	if r.enabled(FeatureCredentialValidation) {
+		if err = r.validateAccess(ctx, owner, storeConfig); err != nil {
+			credentialFailuresTotal.Inc()
+			return
+		}
+	}
+
+	var reason, msg string
+	if reason, msg, err = r.checkOwnership(ctx, objectStore, owner); err != nil {
+		return
+	}
+	if reason != "" {
+		return r.reportConflict(ctx, owner, reason, msg)
+	}
+
+	if r.isDryRun(owner) {
+		return r.reportDryRun(ctx, owner, &cloudobject.DryRunStatus{
+			Action:    Store,
+			Reference: storeReference(owner.Spec.Target),
+		})
+	}
+
+	if job, err = r.uploadJob(owner, jobKey, storeConfig); err != nil {
+		return
+	}
+	if controllerError = r.Create(ctx, job); controllerError != nil {
+		return
+	}
+
+	failures := 0
+	if upload := owner.Status.Upload; upload != nil && upload.Phase == cloudobject.UploadFailed {
+		// the job replaces a failed job of the same upload
+		failures = upload.Failures
+	}
+	now := metav1.Now()
+	owner.Status.Upload = &cloudobject.UploadStatus{
+		JobName:   job.Name,
+		Phase:     cloudobject.UploadRunning,
+		StartTime: &now,
+		Failures:  failures,
+	}
+	if controllerError = r.Status().Update(ctx, owner); controllerError != nil {
+		return
+	}
+
+	r.Recorder.Event(owner, corev1.EventTypeNormal, UploadStarted, fmt.Sprintf("started upload job %s", job.Name))
+	log.FromContext(ctx).Info("started upload job", "job", job.Name, "key", printReference(owner))
+	return nil
+}
+
+// trackUpload records the progress of an existing upload job of obj, replacing
+// jobs started for a previous generation or resync request, and failed jobs
+// once their retry delay elapsed
+func (r *ObjectReconciler) trackUpload(ctx context.Context, objectStore ctrlapi.ObjectStore, obj *cloudobject.Object, job *batchv1.Job, processingError *error) error {
+	log := log.FromContext(ctx)
+	upload := obj.Status.Upload
+	recorded := upload != nil && upload.JobName == job.Name
+
+	if !job.DeletionTimestamp.IsZero() {
+		// a new job is started once the deletion went through
+		return nil
+	}
+	if !isCurrentUpload(obj, job) {
+		log.Info("replacing outdated upload job", "job", job.Name)
+		if recorded && upload.Failures > 0 {
+			// failures of a previous upload do not delay the next one
+			upload.Failures = 0
+			if err := r.Status().Update(ctx, obj); err != nil {
+				return err
+			}
+		}
+		return client.IgnoreNotFound(r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+	}
+
+	finished, failure := jobFinished(job)
+	switch {
+	case !finished:
+		if recorded && upload.Phase == cloudobject.UploadRunning {
+			return nil
+		}
+		obj.Status.Upload = &cloudobject.UploadStatus{
+			JobName:   job.Name,
+			Phase:     cloudobject.UploadRunning,
+			StartTime: job.Status.StartTime,
+		}
+		if recorded {
+			obj.Status.Upload.Failures = upload.Failures
+		}
+		return r.Status().Update(ctx, obj)
+
+	case failure != "":
+		if recorded && upload.Phase == cloudobject.UploadFailed {
+			if uploadRetryInterval(obj) > 0 {
+				// kept for its logs until it is retried
+				return nil
+			}
+			log.Info("retrying failed upload job", "job", job.Name, "failures", upload.Failures)
+			return client.IgnoreNotFound(r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+		}
+		failures := 1
+		if recorded {
+			failures = upload.Failures + 1
+		}
+		obj.Status.Upload = &cloudobject.UploadStatus{
+			JobName:        job.Name,
+			Phase:          cloudobject.UploadFailed,
+			StartTime:      job.Status.StartTime,
+			CompletionTime: jobCompletionTime(job),
+			Message:        failure,
+			Failures:       failures,
+		}
+		*processingError = &uploadError{job: job.Name, failure: failure}
+		return nil
+
+	case recorded && upload.Phase == cloudobject.UploadSucceeded:
+		// the result is recorded, so that deleting the job does not start
+		// the upload again
+		return client.IgnoreNotFound(r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+	}
+
+	info, err := objectStore.Head(ctx, obj.Spec.Target)
+	if err != nil {
+		*processingError = err
+		return nil
+	}
+
+	generation, _ := strconv.ParseInt(job.Annotations[GenerationAnnotation], 10, 64)
+	obj.Status.Synced = true
+	obj.Status.Checksum = info.Metadata[ChecksumMetadataKey]
+	obj.Status.ETag = info.ETag
+	obj.Status.VersionedBucket = versionedBucket(obj.Spec.Target, info)
+	obj.Status.ObservedGeneration = generation
+	obj.Status.Reference = storeReference(obj.Spec.Target)
+	obj.Status.DryRun = nil
+	if resyncAt, ok := job.Annotations[ResyncAnnotation]; ok {
+		obj.Status.LastHandledResyncAt = resyncAt
+	}
+	obj.Status.Upload = &cloudobject.UploadStatus{
+		JobName:        job.Name,
+		Phase:          cloudobject.UploadSucceeded,
+		StartTime:      job.Status.StartTime,
+		CompletionTime: jobCompletionTime(job),
+	}
+	setConflict(obj, metav1.ConditionFalse, ReasonNoConflict, "target is managed by this object")
+	setSynced(obj, metav1.ConditionTrue, ReasonSucceeded, fmt.Sprintf("object reference: %s", printReference(obj)))
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return err
+	}
+	objectStates.set(client.ObjectKeyFromObject(obj), true)
+
+	r.Recorder.Event(obj, corev1.EventTypeNormal, Synced, fmt.Sprintf("object reference: %s", printReference(obj)))
+	log.Info("successfully synced resource", "key", printReference(obj), "job", job.Name)
+	return nil
+}
+
+// uploadJob returns the job uploading the PVC source of obj
+func (r *ObjectReconciler) uploadJob(obj *cloudobject.Object, key types.NamespacedName, storeConfig ctrlapi.ConfigData) (*batchv1.Job, error) {
+	src := obj.Spec.Source.PVC
+	if strings.HasPrefix(src.Path, "/") || strings.HasPrefix(path.Clean(src.Path), "..") {
+		return nil, errors.Errorf("path %s must be relative to the root of the volume", src.Path)
+	}
+
+	spec, err := json.Marshal(UploadSpec{
+		Path:            path.Join(uploadDataPath, src.Path),
+		CredentialsFile: path.Join(uploadCredentialsPath, uploadCredentialsFile),
+		Target:          obj.Spec.Target,
+		Metadata:        ownerMetadata(obj),
+		Limits:          storeConfig.Limits,
+		Endpoints:       storeConfig.Endpoints,
+		CreateBucket:    obj.Spec.Target.CreateBucketIfMissing && r.enabled(FeatureBucketCreation),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{GenerationAnnotation: strconv.FormatInt(obj.Generation, 10)}
+	if resyncAt, ok := obj.Annotations[ResyncAnnotation]; ok {
+		annotations[ResyncAnnotation] = resyncAt
+	}
+	labels := map[string]string{UploadJobLabel: uploadJobLabelValue(obj)}
+	backoffLimit := int32(uploadBackoffLimit)
+	deadline := int64(uploadTimeout.Seconds())
+	if src.Timeout != nil {
+		deadline = int64(src.Timeout.Seconds())
+	}
+	automountToken := false
+	runAsNonRoot := true
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        key.Name,
+			Namespace:   key.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			// a job whose pod cannot be scheduled, e.g. while a
+			// ReadWriteOnce claim is attached to another node, fails
+			// rather than blocking later uploads
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automountToken,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot: &runAsNonRoot,
+						FSGroup:      src.FSGroup,
+					},
+					Containers: []corev1.Container{{
+						Name:    "upload",
+						Image:   r.UploaderImage,
+						Command: []string{"/manager", UploadCommand},
+						Env:     []corev1.EnvVar{{Name: UploadSpecEnv, Value: string(spec)}},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "data", MountPath: uploadDataPath, ReadOnly: true},
+							{Name: "credentials", MountPath: uploadCredentialsPath, ReadOnly: true},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{
+							Name: "data",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: src.ClaimName,
+									ReadOnly:  true,
+								},
+							},
+						},
+						{
+							Name: "credentials",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: obj.Spec.Credentials.SecretReference.Name,
+									Items: []corev1.KeyToPath{{
+										Key:  obj.Spec.Credentials.SecretReference.Key,
+										Path: uploadCredentialsFile,
+									}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(obj, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// isCurrentUpload reports whether job uploads the current generation and
+// resync request of obj
+func isCurrentUpload(obj *cloudobject.Object, job *batchv1.Job) bool {
+	resyncAt, resync := obj.Annotations[ResyncAnnotation]
+	jobResyncAt, jobResync := job.Annotations[ResyncAnnotation]
+	return job.Annotations[GenerationAnnotation] == strconv.FormatInt(obj.Generation, 10) &&
+		resync == jobResync && resyncAt == jobResyncAt
+}
+
+// uploadRetryInterval returns the time left until the failed upload job of
+// obj is replaced, zero if its upload did not fail or is due. The delay
+// doubles with every failure, up to the timeout of a job.
+func uploadRetryInterval(obj cloudobject.Storable) time.Duration {
+	upload := obj.GetStatus().Upload
+	if upload == nil || upload.Phase != cloudobject.UploadFailed {
+		return 0
+	}
+
+	failedAt := upload.CompletionTime
+	if failedAt == nil {
+		failedAt = upload.StartTime
+	}
+	if failedAt == nil {
+		return 0
+	}
+
+	delay := uploadTimeout
+	if failures := upload.Failures; failures < 7 {
+		if failures < 1 {
+			failures = 1
+		}
+		delay = uploadRetryDelay << (failures - 1)
+	}
+	if delay > uploadTimeout {
+		delay = uploadTimeout
+	}
+	if left := time.Until(failedAt.Add(delay)); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// jobFinished reports whether job finished, along with the reason of its
+// failure if it failed
+func jobFinished(job *batchv1.Job) (bool, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, ""
+		case batchv1.JobFailed:
+			msg := condition.Message
+			if msg == "" {
+				msg = condition.Reason
+			}
+			return true, msg
+		}
+	}
+	return false, ""
+}
+
+func jobCompletionTime(job *batchv1.Job) *metav1.Time {
+	if job.Status.CompletionTime != nil {
+		return job.Status.CompletionTime
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return &condition.LastTransitionTime
+		}
+	}
+	return nil
+}
+
+// uploadJobName returns the name of the upload job of obj, which stays below
+// the 63 characters of the job-name label of its pods
+func uploadJobName(obj client.Object) string {
+	name := obj.GetName()
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	return fmt.Sprintf("%s-upload-%s", strings.TrimSuffix(name, "-"), uploadJobLabelValue(obj)[:8])
+}
+
+// uploadJobLabelValue returns a label value identifying obj
+func uploadJobLabelValue(obj client.Object) string {
+	sum := sha256.Sum256([]byte(obj.GetUID()))
+	return hex.EncodeToString(sum[:])[:16]
+}
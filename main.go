@@ -17,8 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -26,16 +31,26 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	ctrlconfig "dev.nimak.link/s3-copy-controller/api/config/v1alpha1"
 	s3awsnimakinfov1alpha1 "dev.nimak.link/s3-copy-controller/api/v1alpha1"
+	s3awsnimakinfov1beta1 "dev.nimak.link/s3-copy-controller/api/v1beta1"
 	"dev.nimak.link/s3-copy-controller/controllers"
+	ctrlapi "dev.nimak.link/s3-copy-controller/controllers/api"
 	//+kubebuilder:scaffold:imports
 )
 
+const defaultLeaderElectionID = "f13742af.dev.nimak.link"
+
+// defaultUploaderImage is the image the manager was built into, set at build
+// time
+var defaultUploaderImage string
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -45,18 +60,67 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(s3awsnimakinfov1alpha1.AddToScheme(scheme))
+	utilruntime.Must(s3awsnimakinfov1beta1.AddToScheme(scheme))
+	utilruntime.Must(ctrlconfig.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
-	var metricsAddr string
+	if len(os.Args) > 1 && os.Args[1] == controllers.UploadCommand {
+		// run as the uploader of an upload job
+		if err := controllers.RunUpload(ctrl.SetupSignalHandler()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var configFile string
 	var enableLeaderElection bool
-	var probeAddr string
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	var leaderElectionID string
+	var ctrlConfig ctrlconfig.ControllerConfig
+	var tracingOpts controllers.TracingOptions
+	flag.StringVar(&configFile, "config", "",
+		"The controller will load its initial configuration from this file. "+
+			"Omit this flag to use the default configuration values. "+
+			"Command-line flags override configuration from this file.")
+	flag.StringVar(&ctrlConfig.Metrics.BindAddress, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&ctrlConfig.Health.HealthProbeBindAddress, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "",
+		"The name of the leader election lock, "+defaultLeaderElectionID+" if empty. "+
+			"Controller instances with different scopes need different names.")
+	flag.Var(listFlag{&ctrlConfig.Scope.Namespaces}, "namespaces", "Comma separated namespaces watched by the controller. All namespaces if empty.")
+	flag.Var(listFlag{&ctrlConfig.Scope.ExcludedNamespaces}, "exclude-namespaces", "Comma separated namespaces never watched by the controller.")
+	flag.StringVar(&ctrlConfig.Scope.ObjectSelector, "object-selector", "",
+		"Label selector of the objects handled by the controller, e.g. tenant=a. All objects if empty.")
+	flag.StringVar(&ctrlConfig.DefaultRegion, "default-region", "", "The region of targets without a region.")
+	flag.StringVar(&ctrlConfig.DefaultDeletionPolicy, "default-deletion-policy", "Retain",
+		"The deletion policy of objects without a deletion policy: Delete, Retain or OrphanOnMismatch.")
+	flag.StringVar(&ctrlConfig.Endpoints.S3, "s3-endpoint", "", "The URL of the S3 endpoint, e.g. of an S3 compatible store. The AWS endpoint if empty.")
+	flag.StringVar(&ctrlConfig.Endpoints.STS, "sts-endpoint", "", "The URL of the STS endpoint. The AWS endpoint if empty.")
+	flag.BoolVar(&ctrlConfig.DryRun, "dry-run", false,
+		"Resolve sources and credentials of objects and report the planned operations without modifying object stores.")
+	flag.DurationVar(&ctrlConfig.DeletionTimeout.Duration, "deletion-timeout", 0,
+		"How long deleting objects from object stores is retried before their finalizer is removed regardless. Zero retries forever.")
+	flag.IntVar(&ctrlConfig.MaxConcurrentReconciles, "max-concurrent-reconciles", 1, "The number of objects reconciled in parallel.")
+	flag.Float64Var(&ctrlConfig.Retry.RequestsPerSecond, "bucket-qps", 50,
+		"The requests per second allowed to a bucket with the same credentials. Zero disables rate limiting.")
+	flag.IntVar(&ctrlConfig.Retry.Burst, "bucket-burst", 100, "The requests allowed above --bucket-qps in bursts.")
+	flag.IntVar(&ctrlConfig.Retry.MaxAttempts, "max-attempts", 3, "The attempts of every call to an object store, including retries.")
+	flag.StringVar(&ctrlConfig.Retry.Mode, "retry-mode", "standard",
+		"The retry mode of calls to object stores: standard or adaptive. Adaptive lowers the request rate while calls are throttled.")
+	flag.Var(featureGatesFlag{&ctrlConfig.FeatureGates}, "feature-gates",
+		"Comma separated features to enable or disable, e.g. CredentialValidation=false.")
+	flag.StringVar(&ctrlConfig.UploaderImage, "uploader-image", "",
+		"The image of the upload jobs of PVC sources, running the manager binary. The image of the controller if empty.")
+	flag.StringVar(&ctrlConfig.ClusterName, "cluster-name", "", "The name of the cluster, available to templates as {{ .Cluster.Name }}.")
+	flag.StringVar(&tracingOpts.Endpoint, "otlp-endpoint", "",
+		"The OTLP gRPC endpoint traces are exported to. Tracing is disabled if empty.")
+	flag.BoolVar(&tracingOpts.Insecure, "otlp-insecure", false, "Disable TLS towards the OTLP endpoint.")
+	flag.Float64Var(&tracingOpts.SampleRatio, "trace-sample-ratio", 1, "The fraction of reconciliations traced, between 0 and 1.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,28 +129,136 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "f13742af.dev.nimak.link",
-	})
+	var err error
+	options := ctrl.Options{
+		Scheme:           scheme,
+		LeaderElection:   enableLeaderElection,
+		LeaderElectionID: leaderElectionID,
+	}
+	if configFile != "" {
+		// the file overrides the flag defaults, and is loaded into the
+		// manager options as well as into the controller configuration
+		options, err = options.AndFrom(ctrl.ConfigFile().AtPath(configFile).OfKind(&ctrlConfig))
+		if err != nil {
+			setupLog.Error(err, "unable to load the config file")
+			os.Exit(1)
+		}
+		// flags given on the command line override the file
+		flag.Parse()
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "leader-elect":
+				options.LeaderElection = enableLeaderElection
+			case "leader-election-id":
+				options.LeaderElectionID = leaderElectionID
+			}
+		})
+	}
+	if ctrlConfig.UploaderImage == "" {
+		ctrlConfig.UploaderImage = defaultUploaderImage
+	}
+	if err := controllers.ValidateConfig(&ctrlConfig); err != nil {
+		setupLog.Error(err, "invalid controller configuration")
+		os.Exit(1)
+	}
+
+	deletionPolicy, err := controllers.ParseDeletionPolicy(ctrlConfig.DefaultDeletionPolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid default deletion policy")
+		os.Exit(1)
+	}
+	scope, err := controllers.ScopeFromConfig(ctrlConfig.Scope)
+	if err != nil {
+		setupLog.Error(err, "invalid controller scope")
+		os.Exit(1)
+	}
+	options.MetricsBindAddress = ctrlConfig.Metrics.BindAddress
+	options.HealthProbeBindAddress = ctrlConfig.Health.HealthProbeBindAddress
+	options.NewCache = scope.NewCache()
+	options.ClientDisableCacheFor = scope.Uncached()
+	if options.Port == 0 {
+		options.Port = 9443
+	}
+	if options.LeaderElectionID == "" {
+		options.LeaderElectionID = defaultLeaderElectionID
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+	shutdownTracing, err := controllers.SetupTracing(ctx, tracingOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.ObjectReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		Recorder:     mgr.GetEventRecorderFor("object-controller"),
-		StoreManager: controllers.NewStoreManager(),
-	}).SetupWithManager(mgr); err != nil {
+	// the server version available to templates is read once, at startup
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	serverVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		setupLog.Error(err, "unable to read the server version")
+		os.Exit(1)
+	}
+
+	objectReconciler := &controllers.ObjectReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("object-controller"),
+		StoreManager:    controllers.NewStoreManager(),
+		DryRun:          ctrlConfig.DryRun,
+		DeletionTimeout: ctrlConfig.DeletionTimeout.Duration,
+
+		MaxConcurrentReconciles: ctrlConfig.MaxConcurrentReconciles,
+		Limits: ctrlapi.Limits{
+			RequestsPerSecond: ctrlConfig.Retry.RequestsPerSecond,
+			Burst:             ctrlConfig.Retry.Burst,
+			MaxAttempts:       ctrlConfig.Retry.MaxAttempts,
+			RetryMode:         ctrlConfig.Retry.Mode,
+		},
+		Scope:                 scope,
+		DefaultRegion:         ctrlConfig.DefaultRegion,
+		DefaultDeletionPolicy: deletionPolicy,
+		Endpoints: ctrlapi.Endpoints{
+			S3:  ctrlConfig.Endpoints.S3,
+			STS: ctrlConfig.Endpoints.STS,
+		},
+		Features:      controllers.Features(ctrlConfig.FeatureGates),
+		UploaderImage: ctrlConfig.UploaderImage,
+		Cluster: controllers.ClusterInfo{
+			Name:    ctrlConfig.ClusterName,
+			Version: serverVersion.GitVersion,
+		},
+	}
+	if err = objectReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Object")
 		os.Exit(1)
 	}
+	if err = (&controllers.ClusterObjectReconciler{ObjectReconciler: objectReconciler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterObject")
+		os.Exit(1)
+	}
+	if err = (&controllers.ScheduledObjectReconciler{ObjectReconciler: objectReconciler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ScheduledObject")
+		os.Exit(1)
+	}
+	if err = (&controllers.NamespaceBackupReconciler{ObjectReconciler: objectReconciler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NamespaceBackup")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&s3awsnimakinfov1beta1.Object{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Object")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -99,8 +271,71 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "problem flushing traces")
+	}
+}
+
+// listFlag is a flag holding a comma separated list
+type listFlag struct {
+	list *[]string
+}
+
+func (f listFlag) String() string {
+	if f.list == nil {
+		return ""
+	}
+	return strings.Join(*f.list, ",")
+}
+
+func (f listFlag) Set(value string) error {
+	*f.list = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f.list = append(*f.list, item)
+		}
+	}
+	return nil
+}
+
+// featureGatesFlag is a flag holding comma separated feature=enabled pairs
+type featureGatesFlag struct {
+	gates *map[string]bool
+}
+
+func (f featureGatesFlag) String() string {
+	if f.gates == nil {
+		return ""
+	}
+	var pairs []string
+	for feature, enabled := range *f.gates {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", feature, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f featureGatesFlag) Set(value string) error {
+	gates := map[string]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("missing value of feature gate %s", pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("invalid value of feature gate %s: %v", pair, err)
+		}
+		gates[strings.TrimSpace(parts[0])] = enabled
+	}
+	*f.gates = gates
+	return nil
 }